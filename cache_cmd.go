@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+// cacheUsage 是 cache 子命令的用法说明。
+const cacheUsage = `用法: %s cache <stats|prune|clear> [选项]
+
+  stats                       显示缓存条目数和占用空间
+  prune --older-than <时长>   删除超过指定时长未使用的条目，例如 720h、30d
+  clear                       删除所有缓存条目
+
+选项:
+`
+
+// runCacheCommand 执行 cache 子命令 (管理翻译缓存) 并返回进程退出码。
+// 缓存目录按 --cache-dir、--config 中的 [cache] dir 的顺序确定。
+func runCacheCommand(args []string) int {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	cacheDir := fs.String("cache-dir", "", "翻译缓存目录")
+	configFile := fs.String("config", "", "TOML 配置文件路径 (读取其中的 [cache] dir)")
+	olderThan := fs.String("older-than", "", "prune: 删除超过该时长未使用的条目 (例如 720h、30d)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), cacheUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return exitFailure
+	}
+	action := args[0]
+	if action != "stats" && action != "prune" && action != "clear" {
+		log.Printf("未知的 cache 子命令 '%s'", action)
+		fs.Usage()
+		return exitFailure
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitFailure
+	}
+
+	if *cacheDir == "" && *configFile != "" {
+		dir, err := config.CacheDirFromFile(*configFile)
+		if err != nil {
+			log.Printf("加载配置文件失败: %v", err)
+			return exitFailure
+		}
+		*cacheDir = dir
+	}
+	if *cacheDir == "" {
+		log.Println("未指定翻译缓存目录 (--cache-dir 或配置文件中的 [cache] dir)。")
+		return exitFailure
+	}
+	cache, err := translator.OpenCache(*cacheDir)
+	if err != nil {
+		log.Printf("打开翻译缓存失败: %v", err)
+		return exitFailure
+	}
+
+	switch action {
+	case "stats":
+		s, err := cache.Stats()
+		if err != nil {
+			log.Printf("统计翻译缓存失败: %v", err)
+			return exitFailure
+		}
+		fmt.Printf("缓存目录:  %s\n", *cacheDir)
+		fmt.Printf("条目数:    %d\n", s.Entries)
+		fmt.Printf("占用空间:  %s\n", formatBytes(s.Bytes))
+		if s.Entries > 0 {
+			fmt.Printf("最早使用:  %s\n", s.Oldest.Format(time.DateTime))
+			fmt.Printf("最近使用:  %s\n", s.Newest.Format(time.DateTime))
+		}
+	case "prune":
+		if *olderThan == "" {
+			log.Println("prune 需要指定 --older-than，例如 --older-than 30d")
+			return exitFailure
+		}
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			log.Printf("无效的 --older-than: %v", err)
+			return exitFailure
+		}
+		removed, freed, err := cache.Prune(time.Now().Add(-age))
+		if err != nil {
+			log.Printf("清理翻译缓存失败: %v", err)
+			return exitFailure
+		}
+		fmt.Printf("已删除 %d 个超过 %s 未使用的条目，释放 %s。\n", removed, *olderThan, formatBytes(freed))
+	case "clear":
+		removed, err := cache.Clear()
+		if err != nil {
+			log.Printf("清空翻译缓存失败: %v", err)
+			return exitFailure
+		}
+		fmt.Printf("已删除 %d 个缓存条目。\n", removed)
+	}
+	return exitOK
+}
+
+// parseAge 解析时长，除 time.ParseDuration 支持的格式外，还支持以天为单位的 "30d"。
+func parseAge(s string) (time.Duration, error) {
+	var age time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("无法解析天数 '%s'", s)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		age = d
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("时长必须大于 0")
+	}
+	return age, nil
+}
+
+// formatBytes 以易读的单位格式化字节数。
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -3,16 +3,81 @@ package config
 import (
 	"flag"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/BurntSushi/toml" // 导入 TOML 解析库
+
+	"Markdown-translator-go/glossary"
+	"Markdown-translator-go/memory"
+	"Markdown-translator-go/utils"
+	"Markdown-translator-go/validator"
 )
 
 // SupportedProviders 列出了当前支持的 LLM 提供商标识符。
-var SupportedProviders = []string{"openai", "claude", "gemini"}
+var SupportedProviders = []string{"openai", "claude", "gemini", "ollama", "local"}
+
+// KeylessProviders 列出了不需要 API Key 的提供商 (本地模型服务)。
+var KeylessProviders = []string{"ollama", "local"}
+
+// DefaultExtensions 列出了默认查找的 Markdown 家族文件扩展名。
+var DefaultExtensions = []string{".md", ".markdown", ".mdown", ".mdx", ".qmd"}
+
+// UsageLedgerFileName 是未指定 --usage-ledger 时，保存在每个目标目录中的用量账本文件名。
+const UsageLedgerFileName = ".mdtranslate-usage.jsonl"
+
+// 任务失败的类别，记录在任务日志中，续跑 (--resume) 时按 --resume-retry 决定是否重试。
+const (
+	FailureTransient  = "transient"  // 限流、服务端错误、网络错误等临时性错误 (已用完重试次数)
+	FailureAPI        = "api"        // 其他 API 错误，例如认证失败、请求被拒绝
+	FailureValidation = "validation" // 译文未通过结构校验 (--validate fail/retry)
+	FailureIO         = "io"         // 读取源文件或写入目标文件失败
+	FailureTruncated  = "truncated"  // LLM 输出达到输出 token 上限 (--max-output-tokens) 被截断
+	FailureOther      = "other"      // 其他错误，例如 LLM 输出缺少标签、占位符无法还原
+)
+
+// FailureClasses 列出了所有任务失败类别。
+var FailureClasses = []string{FailureTransient, FailureAPI, FailureValidation, FailureIO, FailureTruncated, FailureOther}
+
+// MinOutputTokens 是未指定 --max-output-tokens 时按片段大小计算的输出 token 上限的最小值。
+const MinOutputTokens = 4096
+
+// FailureReportFileName 是未指定 --failed-report 时，保存在每个目标目录中的失败报告文件名。
+const FailureReportFileName = ".mdtranslate-failed.json"
+
+// LangPlaceholder 是目标目录模式中代表目标语言代码的占位符，例如 "pages.{lang}"。
+const LangPlaceholder = "{lang}"
+
+// languageNames 将常用的语言代码映射为提供给 Prompt 模板的语言名称，未列出的代码原样使用。
+var languageNames = map[string]string{
+	"en":    "English",
+	"zh":    "Simplified Chinese",
+	"zh_CN": "Simplified Chinese",
+	"zh_TW": "Traditional Chinese",
+	"zh_HK": "Traditional Chinese (Hong Kong)",
+	"ja":    "Japanese",
+	"ko":    "Korean",
+	"fr":    "French",
+	"de":    "German",
+	"es":    "Spanish",
+	"pt":    "Portuguese",
+	"pt_BR": "Brazilian Portuguese",
+	"ru":    "Russian",
+	"it":    "Italian",
+}
+
+// LanguageName 返回语言代码对应的语言名称 (例如 "zh_TW" -> "Traditional Chinese")。
+func LanguageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
 
 // TomlConfig 结构体对应 TOML 配置文件结构
 type TomlConfig struct {
@@ -21,30 +86,232 @@ type TomlConfig struct {
 		Endpoint string `toml:"endpoint"`
 		Key      string `toml:"key"`
 		Model    string `toml:"model"`
+		// 重试设置
+		MaxAttempts int           `toml:"max_attempts"`
+		MaxElapsed  time.Duration `toml:"max_elapsed"`
+		// 客户端限流设置
+		RequestsPerMinute int `toml:"requests_per_minute"`
+		TokensPerMinute   int `toml:"tokens_per_minute"`
+		// 流式响应设置
+		Stream            *bool         `toml:"stream"`
+		StreamIdleTimeout time.Duration `toml:"stream_idle_timeout"`
+		// 本地模型服务 (ollama) 设置
+		KeepAlive     string `toml:"keep_alive"`
+		ContextLength int    `toml:"context_length"`
+		PullModel     *bool  `toml:"pull_model"`
+		// 输出 token 上限 (claude 的 max_tokens)
+		MaxOutputTokens int `toml:"max_output_tokens"`
+		// 生成参数和附加的 HTTP Header ([api.params]、[api.headers])
+		Params  map[string]any    `toml:"params"`
+		Headers map[string]string `toml:"headers"`
+		// 备用提供商链 ([[api.fallback]])
+		Fallback []ProviderProfile `toml:"fallback"`
 	} `toml:"api"`
 	General struct {
-		SourceDir   string `toml:"source_dir"`
-		TargetDir   string `toml:"target_dir"`
-		Concurrency int    `toml:"concurrency"`
-		PromptFile  string `toml:"prompt_file"`
-		Overwrite   bool   `toml:"overwrite"`
+		SourceDir   string   `toml:"source_dir"`
+		TargetDir   string   `toml:"target_dir"`
+		SourceLang  string   `toml:"source_lang"`
+		TargetLangs []string `toml:"target_languages"`
+		Concurrency int      `toml:"concurrency"`
+		PromptFile  string   `toml:"prompt_file"`
+		Overwrite   bool     `toml:"overwrite"`
+		ChangedOnly bool     `toml:"changed_only"`
+		Incremental bool     `toml:"incremental"`
+		ResumeRetry string   `toml:"resume_retry"`
+		// 失败报告路径
+		FailedReport string `toml:"failed_report"`
+		Protect      *bool  `toml:"protect"`
+		Validate     string `toml:"validate"`
+		Profile      string `toml:"profile"`
+		// 收到退出信号后等待进行中文件完成的最长时间
+		DrainTimeout time.Duration `toml:"drain_timeout"`
 	} `toml:"general"`
+	Chunking struct {
+		MaxTokens   int   `toml:"max_tokens"`
+		Concurrency int   `toml:"concurrency"`
+		Context     *bool `toml:"context"`
+	} `toml:"chunking"`
+	Usage struct {
+		Ledger   string           `toml:"ledger"`
+		Currency string           `toml:"currency"`
+		Prices   map[string]Price `toml:"prices"`
+		// 预算上限
+		MaxCost        float64 `toml:"max_cost"`
+		MaxTokensTotal int     `toml:"max_tokens_total"`
+	} `toml:"usage"`
+	Cache struct {
+		Dir string `toml:"dir"`
+	} `toml:"cache"`
+	Discovery struct {
+		Extensions []string `toml:"extensions"`
+		Include    []string `toml:"include"`
+		Exclude    []string `toml:"exclude"`
+		Gitignore  *bool    `toml:"gitignore"`
+	} `toml:"discovery"`
+	FrontMatter struct {
+		Keys []string `toml:"keys"`
+	} `toml:"front_matter"`
+	Glossary struct {
+		File string `toml:"file"`
+	} `toml:"glossary"`
+	TranslationMemory struct {
+		File           string   `toml:"file"`
+		FuzzyThreshold *float64 `toml:"fuzzy_threshold"`
+	} `toml:"translation_memory"`
+
+	// 命名的提供商配置 ([providers.<name>])，通过 --profile 或 general.profile 选择
+	Providers map[string]ProviderProfile `toml:"providers"`
+	// 按路径分派的路由规则 ([[routes]])，按顺序匹配
+	Routes []Route `toml:"routes"`
+}
+
+// Route 是一条路由规则: 将匹配的文件交给指定的 Prompt、提供商配置、temperature 和分片设置翻译。
+// 所有设置的条件都满足时规则匹配 (未设置任何条件的规则匹配所有文件)，按配置顺序使用第一条匹配的规则，
+// 没有规则匹配的文件使用全局设置。未设置的选项同样沿用全局设置。
+type Route struct {
+	Name      string   `toml:"name"`       // 规则名称，用于日志 (为空时为 "routes[序号]")
+	Match     []string `toml:"match"`      // 相对路径的 glob 模式 (支持 **)，匹配任意一个即可; 为空表示不限路径
+	MinTokens int      `toml:"min_tokens"` // 文件估算 token 数下限 (含)，0 表示不限
+	MaxTokens int      `toml:"max_tokens"` // 文件估算 token 数上限 (含)，0 表示不限
+
+	PromptFile       string   `toml:"prompt_file"`       // Prompt 模板文件
+	Profile          string   `toml:"profile"`           // 提供商配置名称 ([providers.<name>])
+	Temperature      *float64 `toml:"temperature"`       // 生成参数 temperature
+	ChunkSize        *int     `toml:"chunk_size"`        // 片段大小 (0 表示不切分)
+	ChunkConcurrency int      `toml:"chunk_concurrency"` // 片段并发数
+	ChunkContext     *bool    `toml:"chunk_context"`     // 是否提供上一个片段作为上下文
+
+	promptTemplate *template.Template // 已解析的 Prompt 模板 (PromptFile 非空时)
+	promptHash     string             // Prompt 模板内容的 SHA-256
+	config         *Config            // 应用了该规则的配置 (见 ForRoute)
+}
+
+// Matches 报告规则是否匹配相对路径为 relPath (以 "/" 分隔)、估算 token 数为 tokens 的文件。
+func (r *Route) Matches(relPath string, tokens int) bool {
+	if len(r.Match) > 0 && !slices.ContainsFunc(r.Match, func(p string) bool { return utils.MatchGlob(p, relPath) }) {
+		return false
+	}
+	if r.MinTokens > 0 && tokens < r.MinTokens {
+		return false
+	}
+	return r.MaxTokens <= 0 || tokens <= r.MaxTokens
+}
+
+// NeedsTranslator 报告规则是否改变了提供商或生成参数，因而需要独立的 Translator。
+func (r *Route) NeedsTranslator() bool {
+	return r.Profile != "" || r.Temperature != nil
+}
+
+// ProviderProfile 描述一个 LLM 提供商端点: 提供商类型、API 端点、API Key、模型以及附加的生成参数和 Header。
+// 主提供商由 [api] 中的设置或选中的 [providers.<name>] 构成，备用提供商在 [[api.fallback]] 中按顺序列出，
+// 或在所选配置的 fallback 中按名称引用其他 [providers.<name>]。
+type ProviderProfile struct {
+	Name     string            `toml:"-"`        // 配置名称 ([providers.<name>] 中的 name)，内联配置时为空
+	Provider string            `toml:"provider"` // 提供商类型 (见 SupportedProviders)
+	Endpoint string            `toml:"endpoint"` // API 端点 URL，为空时使用提供商默认值
+	Key      string            `toml:"key"`      // API Key
+	KeyEnv   string            `toml:"key_env"`  // 读取 API Key 的环境变量名 (Key 为空时使用)
+	Model    string            `toml:"model"`    // 模型名称，为空时使用提供商默认模型
+	Params   map[string]any    `toml:"params"`   // 生成参数 (如 temperature、top_p、max_tokens)，原样合并到请求体中
+	Headers  map[string]string `toml:"headers"`  // 附加到每个请求的 HTTP Header
+	Fallback []string          `toml:"fallback"` // 备用提供商链: 按顺序引用的其他 [providers.<name>] (仅命名配置)
+}
+
+// resolveKey 在未直接设置 Key 时从 KeyEnv 指定的环境变量读取 API Key。
+func (p *ProviderProfile) resolveKey() {
+	if p.Key == "" && p.KeyEnv != "" {
+		p.Key = os.Getenv(p.KeyEnv)
+	}
+}
+
+// Label 返回用于日志和报告的 "提供商/模型" 标识 (未指定模型时只有提供商)。
+func (p ProviderProfile) Label() string {
+	if p.Model == "" {
+		return p.Provider
+	}
+	return p.Provider + "/" + p.Model
+}
+
+// Price 是某个模型每百万 token 的价格 (货币单位见 Config.Currency)。
+type Price struct {
+	Input       float64 `toml:"input"`        // 输入 token 单价
+	CachedInput float64 `toml:"cached_input"` // 命中提示缓存的输入 token 单价，0 表示与 Input 相同
+	Output      float64 `toml:"output"`       // 输出 token 单价
+}
+
+// Cost 按价格计算给定 token 数的费用。cached 是 input 中命中提示缓存的部分。
+func (p Price) Cost(input, cached, output int) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	return (float64(input-cached)*p.Input + float64(cached)*cachedPrice + float64(output)*p.Output) / 1e6
 }
 
 // Config 结构体保存所有应用程序的配置项。
 type Config struct {
-	SourceDir      string             // 源目录: 包含待翻译的英文 Markdown 文件。
-	TargetDir      string             // 目标目录: 用于存放翻译后的 Markdown 文件。
-	Concurrency    int                // 并发数: 同时运行的翻译 Worker (Goroutine) 数量。
-	LLMProvider    string             // LLM提供商: 指定使用哪个 LLM 服务 (例如 "openai", "claude", "gemini")。
-	LLMAPIEndpoint string             // LLM API 端点: 对应提供商的 API URL (对于某些提供商可能是基础URL)。
-	LLMAPIKey      string             // LLM API 密钥: 通过环境变量 MK_TRANSLATOR_API_KEY 获取。
-	LLMModel       string             // LLM 模型: 指定使用的具体模型名称 (可选, 取决于提供商默认值)。
-	PromptFile     string             // Prompt 文件路径: 自定义 Prompt 模板文件的路径。
-	PromptTemplate *template.Template // Prompt 模板: 已解析的 Prompt 模板对象。
-	Overwrite      bool               // 覆盖模式: 是否覆盖目标目录中已存在的同名文件。
-	DryRun         bool               // 空跑模式: 若为 true, 则不实际调用 API 或写入文件, 仅日志记录。
-	ConfigFile     string             // TOML 配置文件路径
+	SourceDir         string             // 源目录: 包含待翻译的英文 Markdown 文件。
+	TargetDir         string             // 目标目录模式: 用于存放翻译后的 Markdown 文件, 其中的 {lang} 会被替换为目标语言代码。
+	SourceLang        string             // 源语言代码: 例如 "en"。
+	TargetLanguages   []string           // 目标语言代码列表: 例如 ["zh", "zh_TW", "ja", "ko"], 每个文件会被翻译为所有目标语言。
+	Concurrency       int                // 并发数: 同时运行的翻译 Worker (Goroutine) 数量。
+	LLMProvider       string             // LLM提供商: 指定使用哪个 LLM 服务 (例如 "openai", "claude", "gemini")。
+	LLMAPIEndpoint    string             // LLM API 端点: 对应提供商的 API URL (对于某些提供商可能是基础URL)。
+	LLMAPIKey         string             // LLM API 密钥: 通过环境变量 MK_TRANSLATOR_API_KEY 获取。
+	LLMModel          string             // LLM 模型: 指定使用的具体模型名称 (可选, 取决于提供商默认值)。
+	RetryMaxAttempts  int                // 最大尝试次数: 翻译请求遇到限流、服务端错误或网络错误时的最多尝试次数 (含首次)。
+	RetryMaxElapsed   time.Duration      // 最长重试时间: 单个文件从首次请求起允许重试的最长总耗时, 0 表示不限制。
+	RequestsPerMinute int                // 每分钟请求数上限 (RPM): 所有 Worker 共享的客户端限流, 0 表示不限制。
+	TokensPerMinute   int                // 每分钟 token 数上限 (TPM): 按 Prompt 长度预估并根据响应中的 usage 修正, 0 表示不限制。
+	Stream            bool               // 流式响应: 使用 SSE 流式接口, 以空闲超时代替整体超时, 收到 </translate> 后提前结束请求。
+	StreamIdleTimeout time.Duration      // 流式空闲超时: 流式响应中两次收到数据之间允许的最长间隔。
+	KeepAlive         string             // 模型保留时间 (仅 ollama): 请求结束后模型在内存中保留的时间, 如 "10m"、"-1"; 空表示使用服务端默认值。
+	ContextLength     int                // 上下文长度 (仅 ollama): 即 num_ctx, 0 表示使用模型默认值。
+	MaxOutputTokens   int                // 输出 token 上限 (仅 claude): 即请求的 max_tokens, 0 表示按片段大小计算 (见 OutputTokenLimit)。
+	PullModel         bool               // 自动拉取模型 (仅 ollama): 启动时模型不存在则自动拉取。
+	LLMParams         map[string]any     // 生成参数: 合并到每个请求体中的参数, 如 temperature、top_p、max_tokens。
+	LLMHeaders        map[string]string  // 附加 Header: 添加到每个请求的 HTTP Header。
+	Fallbacks         []ProviderProfile  // 备用提供商链: 主提供商 (及其重试) 最终失败时按顺序尝试的提供商。
+	Profile           string             // 提供商配置名称: 选中的 [providers.<name>], 为空表示使用 [api] 中的设置。
+	PromptFile        string             // Prompt 文件路径: 自定义 Prompt 模板文件的路径。
+	PromptTemplate    *template.Template // Prompt 模板: 已解析的 Prompt 模板对象。
+	PromptHash        string             // Prompt 哈希: Prompt 模板内容的 SHA-256, 记录在翻译清单中用于检测 Prompt 变化。
+	Overwrite         bool               // 覆盖模式: 是否覆盖目标目录中已存在的同名文件。
+	ChangedOnly       bool               // 变更模式: 根据翻译清单只重新翻译源内容或 Prompt 发生变化的文件, 跳过其余文件。
+	Incremental       bool               // 增量模式: 在变更模式的基础上, 只翻译与上次翻译时的原文相比新增或修改的段落, 其余段落沿用现有译文 (包括人工修改)。
+	FailedReport      string             // 失败报告路径: 运行结束时将失败的文件及原因 (阶段、类别、提供商错误信息、尝试次数) 写入该 JSON 文件; 为空时写入各目标目录中的默认报告。
+	FilesFrom         string             // 文件列表: 只处理该文件 (失败报告或每行一个相对路径的文本文件) 中列出的文件, 为空表示处理所有发现的文件。
+	Resume            bool               // 续跑: 根据目标目录中的任务日志跳过上次运行已完成的任务, 只处理未完成的任务和按重试策略需要重试的失败任务。
+	ResumeRetry       []string           // 续跑重试策略: 续跑时重试的失败类别 (见 FailureClasses), 为空表示不重试上次失败的任务。
+	Protect           bool               // 内容保护: 翻译前将代码、URL 和 {{placeholder}} 替换为占位符, 翻译后还原并校验。
+	ValidatePolicy    validator.Policy   // 结构校验策略: 比较原文与译文的标题、列表、代码块、链接等结构, 不一致时按策略 (off/warn/fail/retry) 处理。
+	FrontMatterKeys   []string           // front matter 翻译键: 只翻译 front matter 中这些顶层键的字符串值, 其余内容保持不变。
+	GlossaryFile      string             // 术语表文件路径: CSV 或 TOML 格式的术语表, 为空表示不使用术语表。
+	Glossary          *glossary.Glossary // 术语表: 已加载的术语表, 文件中出现的术语注入 Prompt ({{.Glossary}}), 并检查译文是否使用了规定的译法。
+	MemoryFile        string             // 翻译记忆文件路径: 段落级翻译记忆 (JSON Lines), 为空表示不使用翻译记忆。
+	MemoryFuzzy       float64            // 模糊匹配阈值: 相似度不低于该值的翻译记忆条目作为参考译文注入 Prompt ({{.References}}), 0 表示不提供参考译文。
+	Memory            *memory.Memory     // 翻译记忆: 精确匹配的段落直接复用译文, 不再由 LLM 翻译; 翻译成功的文件按段落对齐后写入。
+	ChunkMaxTokens    int                // 片段大小: 超过该估算 token 数的文档会在标题/段落边界切分为多个片段分别翻译, 0 表示不切分。
+	ChunkConcurrency  int                // 片段并发数: 同一文件的多个片段同时翻译的数量。
+	ChunkContext      bool               // 片段上下文: 是否将上一个片段的原文作为上下文提供给 LLM。
+	DrainTimeout      time.Duration      // 排空时间: 收到 SIGINT/SIGTERM 后等待进行中文件完成的最长时间, 超时后取消其 API 调用。
+	UsageLedger       string             // 用量账本路径: 每个文件的 token 用量和费用以 JSON Lines 追加写入该文件; 为空时写入各目标目录中的默认账本。
+	Currency          string             // 货币单位: 价格表和费用报告使用的货币, 仅用于显示。
+	Prices            map[string]Price   // 价格表: 键为 "提供商/模型"、"模型" 或 "提供商", 值为每百万 token 的价格。
+	MaxCost           float64            // 费用上限: 剩余预算不足以覆盖下一个文件的预估费用时停止分发新文件, 0 表示不限制。
+	MaxTokensTotal    int                // token 总数上限: 剩余预算不足以覆盖下一个文件的预估 token 数时停止分发新文件, 0 表示不限制。
+	CacheDir          string             // 翻译缓存目录: 按 Prompt、提供商、模型和生成参数缓存 LLM 的原始输出, 命中时不调用 API; 为空表示不启用缓存。
+	Extensions        []string           // 文件扩展名: 需要翻译的文件扩展名 (小写, 含 "."), 例如 [".md", ".mdx", ".qmd"]。
+	Include           []string           // 包含模式: 相对路径的 glob 模式 (支持 **), 非空时只翻译匹配其中任意一个的文件。
+	Exclude           []string           // 排除模式: 相对路径的 glob 模式 (支持 **), 匹配其中任意一个的文件不翻译。
+	UseGitignore      bool               // 遵循 .gitignore: 查找文件时除 .mdtranslateignore 外同时遵循各级目录中的 .gitignore。
+	DryRun            bool               // 空跑模式: 若为 true, 则不实际调用 API 或写入文件, 仅日志记录。
+	ConfigFile        string             // TOML 配置文件路径
+
+	// 命名的提供商配置: 可被 --profile、备用提供商链和路由规则按名称引用。
+	Providers map[string]ProviderProfile
+	// 路由规则: 按相对路径或文件大小为文件选择 Prompt、提供商配置、temperature 和分片设置。
+	Routes []Route
 }
 
 // LoadConfig 函数解析命令行标志和环境变量来填充 Config 结构体, 并进行校验。
@@ -53,13 +320,50 @@ func LoadConfig() (*Config, error) {
 
 	// 定义命令行参数及其描述 (中文)
 	flag.StringVar(&cfg.SourceDir, "source", "pages", "源目录 (包含英文 md 文件)")
-	flag.StringVar(&cfg.TargetDir, "target", "pages.zh", "目标目录 (用于输出翻译文件)")
+	flag.StringVar(&cfg.TargetDir, "target", "pages."+LangPlaceholder, "目标目录 (用于输出翻译文件), 其中的 {lang} 会被替换为目标语言代码")
+	flag.StringVar(&cfg.SourceLang, "source-lang", "en", "源语言代码")
+	targetLangs := flag.String("target-langs", "zh", "目标语言代码列表, 以逗号分隔 (例如 zh,zh_TW,ja,ko)")
 	flag.IntVar(&cfg.Concurrency, "concurrency", 5, "并发 Worker 数量")
 	flag.StringVar(&cfg.LLMProvider, "provider", "openai", fmt.Sprintf("使用的 LLM 提供商 (%s)", strings.Join(SupportedProviders, ", ")))
 	flag.StringVar(&cfg.LLMAPIEndpoint, "api-url", "", "LLM API 端点 URL (对于某些提供商可能是基础 URL)")
 	flag.StringVar(&cfg.LLMModel, "model", "", "使用的 LLM 模型名称 (可选, 取决于提供商默认值)")
+	flag.StringVar(&cfg.Profile, "profile", "", "使用配置文件中的命名提供商配置 [providers.<name>] (代替 [api] 中的设置)")
+	flag.IntVar(&cfg.RetryMaxAttempts, "max-attempts", 4, "遇到限流/服务端错误时的最多尝试次数 (含首次, 1 表示不重试)")
+	flag.DurationVar(&cfg.RetryMaxElapsed, "max-elapsed", 5*time.Minute, "单个文件允许重试的最长总耗时 (0 表示不限制)")
+	flag.IntVar(&cfg.RequestsPerMinute, "rpm", 0, "每分钟最多发送的请求数 (所有 Worker 共享, 0 表示不限制)")
+	flag.IntVar(&cfg.TokensPerMinute, "tpm", 0, "每分钟最多消耗的 token 数 (所有 Worker 共享, 0 表示不限制)")
+	flag.BoolVar(&cfg.Stream, "stream", false, "使用 SSE 流式响应 (openai, claude, gemini, local), 以空闲超时代替 120s 的整体超时")
+	flag.DurationVar(&cfg.StreamIdleTimeout, "stream-idle-timeout", 60*time.Second, "流式响应中两次收到数据之间允许的最长间隔")
+	flag.StringVar(&cfg.KeepAlive, "keep-alive", "", "请求结束后模型在内存中保留的时间, 例如 10m、-1 (仅 ollama, 默认使用服务端设置)")
+	flag.IntVar(&cfg.ContextLength, "num-ctx", 0, "模型上下文长度 num_ctx (仅 ollama, 0 表示使用模型默认值)")
+	flag.IntVar(&cfg.MaxOutputTokens, "max-output-tokens", 0, "每次请求的输出 token 上限 max_tokens (仅 claude, 0 表示按片段大小计算)")
+	flag.BoolVar(&cfg.PullModel, "pull", true, "启动时模型不存在则自动拉取 (仅 ollama)")
 	flag.StringVar(&cfg.PromptFile, "prompt-file", "prompt.template", "LLM Prompt 模板文件路径")
 	flag.BoolVar(&cfg.Overwrite, "overwrite", false, "覆盖已存在的目标文件")
+	flag.BoolVar(&cfg.ChangedOnly, "changed-only", false, "根据目标目录中的翻译清单, 只重新翻译源内容或 Prompt 发生变化的文件")
+	flag.BoolVar(&cfg.Incremental, "incremental", false, "增量翻译: 只翻译与上次翻译时的原文相比新增或修改的段落, 其余段落保留现有译文 (隐含 --changed-only)")
+	flag.StringVar(&cfg.FailedReport, "failed-report", "", "失败报告 (JSON) 的路径, 默认写入各目标目录中的 "+FailureReportFileName)
+	flag.StringVar(&cfg.FilesFrom, "files-from", "", "只处理该文件中列出的文件: 失败报告 (JSON) 或每行一个相对路径的文本文件")
+	flag.BoolVar(&cfg.Resume, "resume", false, "续跑: 根据目标目录中的任务日志跳过上次运行已完成的任务, 继续处理未完成和失败的任务")
+	resumeRetry := flag.String("resume-retry", "all", "续跑时重试哪些上次失败的任务: all、none 或以逗号分隔的失败类别 ("+strings.Join(FailureClasses, ", ")+")")
+	flag.BoolVar(&cfg.Protect, "protect", true, "翻译前将代码块、行内代码、URL 和 {{placeholder}} 替换为占位符, 翻译后还原并校验")
+	validatePolicy := flag.String("validate", string(validator.PolicyWarn), "译文结构校验策略 (off, warn, fail, retry)")
+	frontMatterKeys := flag.String("front-matter-keys", "title,description,summary", "需要翻译的 front matter 顶层键, 以逗号分隔 (其余键保持不变, 为空表示不翻译 front matter)")
+	flag.StringVar(&cfg.GlossaryFile, "glossary", "", "术语表文件路径 (.csv 或 .toml), 文件中出现的术语会注入 Prompt, 译文未使用规定译法时按结构校验策略处理")
+	flag.StringVar(&cfg.MemoryFile, "tm", "", "翻译记忆文件路径 (JSON Lines), 精确匹配的段落直接复用译文, 相似的段落作为参考译文提供给 LLM (为空表示不使用)")
+	flag.Float64Var(&cfg.MemoryFuzzy, "tm-fuzzy", 0.75, "翻译记忆模糊匹配的相似度阈值 (0~1, 0 表示不提供参考译文)")
+	flag.IntVar(&cfg.ChunkMaxTokens, "chunk-size", 3000, "超过该估算 token 数的文档会按标题/段落切分后分片翻译 (0 表示不切分)")
+	flag.IntVar(&cfg.ChunkConcurrency, "chunk-concurrency", 1, "同一文件的片段并行翻译的数量")
+	flag.BoolVar(&cfg.ChunkContext, "chunk-context", true, "将上一个片段的原文作为上下文提供给 LLM")
+	flag.DurationVar(&cfg.DrainTimeout, "drain-timeout", 30*time.Second, "收到退出信号后等待进行中文件完成的最长时间")
+	flag.StringVar(&cfg.UsageLedger, "usage-ledger", "", "token 用量和费用账本 (JSON Lines) 的路径, 默认写入各目标目录中的 "+UsageLedgerFileName)
+	flag.Float64Var(&cfg.MaxCost, "max-cost", 0, "本次运行的费用上限 (按价格表计算, 0 表示不限制)")
+	flag.IntVar(&cfg.MaxTokensTotal, "max-tokens", 0, "本次运行的 token 总数上限 (0 表示不限制)")
+	flag.StringVar(&cfg.CacheDir, "cache-dir", "", "翻译缓存目录, 命中缓存的请求不调用 API (为空表示不启用缓存)")
+	extensions := flag.String("extensions", strings.Join(DefaultExtensions, ","), "需要翻译的文件扩展名, 以逗号分隔 (.mdx 和 .qmd 会额外保护 JSX、YAML 头等格式特有的语法)")
+	include := flag.String("include", "", "只翻译匹配这些 glob 模式 (支持 **) 的文件, 以逗号分隔 (例如 common/**,guides/**)")
+	exclude := flag.String("exclude", "", "不翻译匹配这些 glob 模式 (支持 **) 的文件, 以逗号分隔 (例如 **/README.md,vendor/**)")
+	flag.BoolVar(&cfg.UseGitignore, "gitignore", false, "查找文件时同时遵循各级目录中的 .gitignore")
 	flag.BoolVar(&cfg.DryRun, "dry-run", false, "空跑模式 (不调用 API, 不写入文件)")
 	flag.StringVar(&cfg.ConfigFile, "config", "", "TOML 配置文件路径 (优先级高于环境变量)")
 
@@ -69,6 +373,14 @@ func LoadConfig() (*Config, error) {
 
 	flag.Parse() // 解析注册的命令行参数
 
+	cfg.ValidatePolicy = validator.Policy(*validatePolicy)
+	cfg.ResumeRetry = splitList(*resumeRetry)
+	cfg.TargetLanguages = splitList(*targetLangs)
+	cfg.Extensions = splitList(*extensions)
+	cfg.FrontMatterKeys = splitList(*frontMatterKeys)
+	cfg.Include = splitList(*include)
+	cfg.Exclude = splitList(*exclude)
+
 	// 如果指定了配置文件，从配置文件加载设置
 	if cfg.ConfigFile != "" {
 		if err := loadTomlConfig(cfg); err != nil {
@@ -76,6 +388,13 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	if cfg.Profile != "" {
+		if err := cfg.applyProfile(); err != nil {
+			return nil, err
+		}
+		fmt.Printf("使用提供商配置 '%s': %s\n", cfg.Profile, cfg.Profiles()[0].Label())
+	}
+
 	cfg.PromptFile = filepath.Clean(cfg.PromptFile)
 
 	// --- 配置项校验 ---
@@ -91,13 +410,111 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("不支持的 LLM 提供商 '%s'. 支持的提供商: %s", cfg.LLMProvider, strings.Join(SupportedProviders, ", "))
 	}
 
+	if len(cfg.TargetLanguages) == 0 {
+		return nil, fmt.Errorf("必须至少指定一个目标语言 (--target-langs)")
+	}
+	for i, lang := range cfg.TargetLanguages {
+		if slices.Contains(cfg.TargetLanguages[:i], lang) {
+			return nil, fmt.Errorf("目标语言 '%s' 重复", lang)
+		}
+	}
+	if len(cfg.TargetLanguages) > 1 && !strings.Contains(cfg.TargetDir, LangPlaceholder) {
+		return nil, fmt.Errorf("指定多个目标语言时, 目标目录 (--target) 必须包含 %s 占位符, 例如 pages.%s", LangPlaceholder, LangPlaceholder)
+	}
+
 	// 在非空跑模式下, API Key 是必需的
-	if cfg.LLMAPIKey == "" && !cfg.DryRun {
+	if cfg.LLMAPIKey == "" && !cfg.DryRun && !slices.Contains(KeylessProviders, cfg.LLMProvider) {
 		return nil, fmt.Errorf("必须设置 API Key (通过环境变量 %s 或配置文件) (除非使用 --dry-run)", apiKeyEnv)
 	}
 	if cfg.Concurrency <= 0 {
 		return nil, fmt.Errorf("并发数 (--concurrency) 必须大于 0")
 	}
+	if cfg.RetryMaxAttempts <= 0 {
+		return nil, fmt.Errorf("最多尝试次数 (--max-attempts) 必须大于 0")
+	}
+	if cfg.RetryMaxElapsed < 0 {
+		return nil, fmt.Errorf("最长重试时间 (--max-elapsed) 不能为负数")
+	}
+	// 增量模式依赖翻译清单判断文件是否变化
+	if cfg.Incremental {
+		cfg.ChangedOnly = true
+	}
+	retry, err := parseResumeRetry(cfg.ResumeRetry)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ResumeRetry = retry
+	if cfg.ChunkMaxTokens < 0 {
+		return nil, fmt.Errorf("片段大小 (--chunk-size) 不能为负数")
+	}
+	if cfg.ChunkConcurrency <= 0 {
+		return nil, fmt.Errorf("片段并发数 (--chunk-concurrency) 必须大于 0")
+	}
+	if cfg.MemoryFuzzy < 0 || cfg.MemoryFuzzy > 1 {
+		return nil, fmt.Errorf("模糊匹配阈值 (--tm-fuzzy) 必须在 0 到 1 之间")
+	}
+	if cfg.DrainTimeout < 0 {
+		return nil, fmt.Errorf("排空时间 (--drain-timeout) 不能为负数")
+	}
+	if len(cfg.Extensions) == 0 {
+		return nil, fmt.Errorf("文件扩展名列表 (--extensions) 不能为空")
+	}
+	for i, ext := range cfg.Extensions {
+		cfg.Extensions[i] = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+	}
+	for _, pattern := range slices.Concat(cfg.Include, cfg.Exclude) {
+		if err := utils.ValidateGlob(pattern); err != nil {
+			return nil, fmt.Errorf("文件过滤规则 (--include / --exclude): %w", err)
+		}
+	}
+	policy, err := validator.ParsePolicy(string(cfg.ValidatePolicy))
+	if err != nil {
+		return nil, err
+	}
+	cfg.ValidatePolicy = policy
+	if cfg.StreamIdleTimeout < 0 {
+		return nil, fmt.Errorf("流式空闲超时 (--stream-idle-timeout) 不能为负数")
+	}
+	if cfg.ContextLength < 0 {
+		return nil, fmt.Errorf("上下文长度 (--num-ctx) 不能为负数")
+	}
+	if cfg.MaxOutputTokens < 0 {
+		return nil, fmt.Errorf("输出 token 上限 (--max-output-tokens) 不能为负数")
+	}
+	// 每次请求包含 Prompt 模板、片段、上一片段的上下文以及输出，大致需要片段大小的 3 倍
+	if cfg.ContextLength > 0 && cfg.ChunkMaxTokens*3 > cfg.ContextLength {
+		fmt.Printf("警告: 片段大小 (%d) 相对于上下文长度 (%d) 过大，输出可能被截断。建议将 --chunk-size 设为不超过 %d。\n",
+			cfg.ChunkMaxTokens, cfg.ContextLength, cfg.ContextLength/3)
+	}
+	if cfg.RequestsPerMinute < 0 || cfg.TokensPerMinute < 0 {
+		return nil, fmt.Errorf("限流配额 (--rpm, --tpm) 不能为负数")
+	}
+	for key, price := range cfg.Prices {
+		if price.Input < 0 || price.CachedInput < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("价格表中 '%s' 的价格不能为负数", key)
+		}
+	}
+	if cfg.MaxCost < 0 || cfg.MaxTokensTotal < 0 {
+		return nil, fmt.Errorf("预算上限 (--max-cost, --max-tokens) 不能为负数")
+	}
+	for i := range cfg.Fallbacks {
+		fb := &cfg.Fallbacks[i]
+		fb.Provider = strings.ToLower(fb.Provider)
+		if !slices.Contains(SupportedProviders, fb.Provider) {
+			return nil, fmt.Errorf("备用提供商 #%d: 不支持的 LLM 提供商 '%s'. 支持的提供商: %s", i+1, fb.Provider, strings.Join(SupportedProviders, ", "))
+		}
+		fb.resolveKey()
+		if fb.Key == "" && !cfg.DryRun && !slices.Contains(KeylessProviders, fb.Provider) {
+			return nil, fmt.Errorf("备用提供商 #%d (%s) 缺少 API Key (通过 key 或 key_env 设置)", i+1, fb.Label())
+		}
+	}
+	for _, p := range cfg.Profiles() {
+		if _, ok := cfg.PriceFor(p.Provider, p.Model); !ok && cfg.MaxCost > 0 {
+			return nil, fmt.Errorf("设置了费用上限 (--max-cost), 但价格表中没有提供商 '%s' 模型 '%s' 的价格", p.Provider, p.Model)
+		} else if !ok && len(cfg.Prices) > 0 {
+			fmt.Printf("警告: 价格表中没有提供商 '%s' 模型 '%s' 的价格，费用将不被计算。\n", p.Provider, p.Model)
+		}
+	}
 	// 检查源目录是否存在
 	if _, err := os.Stat(cfg.SourceDir); os.IsNotExist(err) {
 		return nil, fmt.Errorf("源目录 '%s' 不存在", cfg.SourceDir)
@@ -125,17 +542,316 @@ func LoadConfig() (*Config, error) {
 	}
 	cfg.PromptTemplate = tmpl // 保存已解析的模板对象
 
-	// 在非空跑模式下, 确保目标目录存在
+	// 加载术语表 (在计算 Prompt 哈希之前，术语表的哈希计入 Prompt 哈希)
+	if cfg.GlossaryFile != "" {
+		if cfg.Glossary, err = glossary.Load(cfg.GlossaryFile); err != nil {
+			return nil, err
+		}
+		fmt.Printf("成功加载术语表: %s (%d 个术语)\n", cfg.GlossaryFile, cfg.Glossary.Len())
+	}
+	cfg.PromptHash = cfg.hashPrompt(promptTemplateContent)
+
+	// 加载翻译记忆 (文件不存在时为空，第一次写入时创建)
+	if cfg.MemoryFile != "" {
+		if cfg.Memory, err = memory.Open(cfg.MemoryFile); err != nil {
+			return nil, err
+		}
+		fmt.Printf("成功加载翻译记忆: %s (%d 个条目)\n", cfg.MemoryFile, cfg.Memory.Len())
+	}
+
+	if err := cfg.validateRoutes(); err != nil {
+		return nil, err
+	}
+
+	// 在非空跑模式下, 确保每个目标语言的目标目录存在
 	if !cfg.DryRun {
-		if err := os.MkdirAll(cfg.TargetDir, 0755); err != nil {
-			return nil, fmt.Errorf("创建目标目录 '%s' 失败: %w", cfg.TargetDir, err)
+		for _, lang := range cfg.TargetLanguages {
+			dir := cfg.TargetDirFor(lang)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建目标目录 '%s' 失败: %w", dir, err)
+			}
+			fmt.Printf("已确保目标目录 '%s' 存在。\n", dir)
 		}
-		fmt.Printf("已确保目标目录 '%s' 存在。\n", cfg.TargetDir)
 	}
 
 	return cfg, nil
 }
 
+// Profiles 返回按尝试顺序排列的提供商链: 第一个是由 [api] 设置 (或所选配置) 构成的主提供商，其后是备用提供商。
+func (c *Config) Profiles() []ProviderProfile {
+	primary := ProviderProfile{
+		Name:     c.Profile,
+		Provider: c.LLMProvider,
+		Endpoint: c.LLMAPIEndpoint,
+		Key:      c.LLMAPIKey,
+		Model:    c.LLMModel,
+		Params:   c.LLMParams,
+		Headers:  c.LLMHeaders,
+	}
+	return append([]ProviderProfile{primary}, c.Fallbacks...)
+}
+
+// NamedProfile 返回名为 name 的提供商配置 ([providers.<name>])，并从 key_env 解析 API Key。
+func (c *Config) NamedProfile(name string) (ProviderProfile, error) {
+	p, ok := c.Providers[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(c.Providers))
+		if len(names) == 0 {
+			return ProviderProfile{}, fmt.Errorf("提供商配置 '%s' 不存在: 配置文件中没有定义任何 [providers.<name>]", name)
+		}
+		return ProviderProfile{}, fmt.Errorf("提供商配置 '%s' 不存在. 可用的配置: %s", name, strings.Join(names, ", "))
+	}
+	p.Name = name
+	p.Provider = strings.ToLower(p.Provider)
+	p.resolveKey()
+	return p, nil
+}
+
+// ProfileChain 返回以命名配置 name 为主提供商、按其 fallback 依次引用其他命名配置的提供商链。
+func (c *Config) ProfileChain(name string) ([]ProviderProfile, error) {
+	primary, err := c.NamedProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	chain := []ProviderProfile{primary}
+	for _, ref := range primary.Fallback {
+		if ref == name || slices.ContainsFunc(chain, func(p ProviderProfile) bool { return p.Name == ref }) {
+			return nil, fmt.Errorf("提供商配置 '%s' 的备用提供商 '%s' 重复或引用了自身", name, ref)
+		}
+		fb, err := c.NamedProfile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("提供商配置 '%s' 的备用提供商: %w", name, err)
+		}
+		chain = append(chain, fb)
+	}
+	return chain, nil
+}
+
+// applyProfile 用选中的命名配置 (及其备用提供商链) 替换 [api] 中的提供商设置。
+// 命名配置未设置 API Key 时仍使用环境变量 MK_TRANSLATOR_API_KEY 中的密钥。
+func (c *Config) applyProfile() error {
+	chain, err := c.ProfileChain(c.Profile)
+	if err != nil {
+		return err
+	}
+	p := chain[0]
+	c.LLMProvider = p.Provider
+	c.LLMAPIEndpoint = p.Endpoint
+	c.LLMModel = p.Model
+	if p.Key != "" {
+		c.LLMAPIKey = p.Key
+	}
+	c.LLMParams = p.Params
+	c.LLMHeaders = p.Headers
+	c.Fallbacks = chain[1:]
+	return nil
+}
+
+// validateRoutes 校验路由规则: 检查匹配模式、引用的提供商配置和数值，并加载各规则的 Prompt 模板。
+func (c *Config) validateRoutes() error {
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("routes[%d]", i)
+		}
+		for _, pattern := range r.Match {
+			if err := utils.ValidateGlob(pattern); err != nil {
+				return fmt.Errorf("路由规则 %s: %w", r.Name, err)
+			}
+		}
+		if r.MinTokens < 0 || r.MaxTokens < 0 || (r.MaxTokens > 0 && r.MinTokens > r.MaxTokens) {
+			return fmt.Errorf("路由规则 %s: min_tokens / max_tokens 无效", r.Name)
+		}
+		if (r.ChunkSize != nil && *r.ChunkSize < 0) || r.ChunkConcurrency < 0 {
+			return fmt.Errorf("路由规则 %s: 分片设置不能为负数", r.Name)
+		}
+		if r.Profile != "" {
+			chain, err := c.ProfileChain(r.Profile)
+			if err != nil {
+				return fmt.Errorf("路由规则 %s: %w", r.Name, err)
+			}
+			for _, p := range chain {
+				if !slices.Contains(SupportedProviders, p.Provider) {
+					return fmt.Errorf("路由规则 %s: 提供商配置 '%s' 的提供商 '%s' 不受支持", r.Name, p.Name, p.Provider)
+				}
+				if p.Key == "" && !c.DryRun && !slices.Contains(KeylessProviders, p.Provider) && (p.Name != r.Profile || c.LLMAPIKey == "") {
+					return fmt.Errorf("路由规则 %s: 提供商配置 '%s' 缺少 API Key (通过 key 或 key_env 设置)", r.Name, p.Name)
+				}
+				if _, ok := c.PriceFor(p.Provider, p.Model); !ok && c.MaxCost > 0 {
+					return fmt.Errorf("路由规则 %s: 设置了费用上限 (--max-cost), 但价格表中没有 %s 的价格", r.Name, p.Label())
+				}
+			}
+		}
+		if r.PromptFile != "" {
+			content, err := os.ReadFile(r.PromptFile)
+			if err != nil {
+				return fmt.Errorf("路由规则 %s: 读取 Prompt 文件失败: %w", r.Name, err)
+			}
+			tmpl, err := template.New("prompt").Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("路由规则 %s: 解析 Prompt 模板失败: %w", r.Name, err)
+			}
+			r.promptTemplate = tmpl
+			r.promptHash = c.hashPrompt(string(content))
+		}
+	}
+	// 全局设置校验完成后再生成各规则的配置副本
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		rc, err := c.applyRoute(r)
+		if err != nil {
+			return fmt.Errorf("路由规则 %s: %w", r.Name, err)
+		}
+		r.config = rc
+	}
+	return nil
+}
+
+// hashPrompt 返回 Prompt 模板内容的哈希。使用术语表时计入术语表的哈希，术语表变化后 --changed-only 会重新翻译所有文件。
+func (c *Config) hashPrompt(content string) string {
+	return utils.HashString(content + c.Glossary.Hash())
+}
+
+// RouteFor 返回第一条匹配相对路径 relPath、估算 token 数为 tokens 的文件的路由规则，没有匹配时返回 nil。
+func (c *Config) RouteFor(relPath string, tokens int) *Route {
+	relPath = filepath.ToSlash(relPath)
+	for i := range c.Routes {
+		if c.Routes[i].Matches(relPath, tokens) {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
+
+// ForRoute 返回应用了路由规则 r 的配置 (加载配置时生成)。r 为 nil 时返回 c 本身。
+func (c *Config) ForRoute(r *Route) *Config {
+	if r == nil || r.config == nil {
+		return c
+	}
+	return r.config
+}
+
+// OutputTokenLimit 返回每次请求的输出 token 上限 (claude 的 max_tokens)。
+// 未指定 MaxOutputTokens 时按片段大小计算: 译文的 token 数通常不超过原文的两倍。
+// 路由规则可能与默认配置共用同一个 Translator，因此取所有路由规则中最大的片段大小; 不切分时使用 MinOutputTokens 的两倍。
+func (c *Config) OutputTokenLimit() int {
+	if c.MaxOutputTokens > 0 {
+		return c.MaxOutputTokens
+	}
+	chunk, unsplit := c.ChunkMaxTokens, c.ChunkMaxTokens == 0
+	for _, r := range c.Routes {
+		if r.ChunkSize != nil {
+			chunk, unsplit = max(chunk, *r.ChunkSize), unsplit || *r.ChunkSize == 0
+		}
+	}
+	if unsplit {
+		return 2 * MinOutputTokens
+	}
+	return max(MinOutputTokens, 2*chunk)
+}
+
+// applyRoute 返回应用了路由规则 r 的配置副本: 替换 Prompt 模板、分片设置，
+// 以及规则指定的提供商配置 (含其备用提供商链) 和 temperature。
+func (c *Config) applyRoute(r *Route) (*Config, error) {
+	rc := *c
+	if r.promptTemplate != nil {
+		rc.PromptFile = r.PromptFile
+		rc.PromptTemplate = r.promptTemplate
+		rc.PromptHash = r.promptHash
+	}
+	if r.ChunkSize != nil {
+		rc.ChunkMaxTokens = *r.ChunkSize
+	}
+	if r.ChunkConcurrency > 0 {
+		rc.ChunkConcurrency = r.ChunkConcurrency
+	}
+	if r.ChunkContext != nil {
+		rc.ChunkContext = *r.ChunkContext
+	}
+	if r.Profile != "" {
+		rc.Profile = r.Profile
+		if err := rc.applyProfile(); err != nil {
+			return nil, err
+		}
+	}
+	if r.Temperature != nil {
+		// 复制生成参数，避免修改全局配置或提供商配置中的 map
+		params := maps.Clone(rc.LLMParams)
+		if params == nil {
+			params = make(map[string]any)
+		}
+		params["temperature"] = *r.Temperature
+		rc.LLMParams = params
+	}
+	return &rc, nil
+}
+
+// PriceFor 返回指定提供商和模型的价格。依次查找 "提供商/模型"、"模型" 和 "提供商" (模型未指定时使用提供商的价格)。
+func (c *Config) PriceFor(provider, model string) (Price, bool) {
+	keys := []string{provider}
+	if model != "" {
+		keys = []string{provider + "/" + model, model, provider}
+	}
+	for _, key := range keys {
+		if price, ok := c.Prices[key]; ok {
+			return price, true
+		}
+	}
+	return Price{}, false
+}
+
+// CacheDirFromFile 从 TOML 配置文件中读取翻译缓存目录 ([cache] dir)，供 cache 子命令使用。
+func CacheDirFromFile(path string) (string, error) {
+	var tomlCfg TomlConfig
+	if _, err := toml.DecodeFile(path, &tomlCfg); err != nil {
+		return "", fmt.Errorf("解析 TOML 文件错误: %w", err)
+	}
+	return tomlCfg.Cache.Dir, nil
+}
+
+// MemoryFileFromFile 从 TOML 配置文件中读取翻译记忆文件路径 ([translation_memory] file)，供 tm 子命令使用。
+func MemoryFileFromFile(path string) (string, error) {
+	var tomlCfg TomlConfig
+	if _, err := toml.DecodeFile(path, &tomlCfg); err != nil {
+		return "", fmt.Errorf("解析 TOML 文件错误: %w", err)
+	}
+	return tomlCfg.TranslationMemory.File, nil
+}
+
+// TargetDirFor 返回指定目标语言的目标目录 (将目标目录模式中的 {lang} 替换为语言代码)。
+func (c *Config) TargetDirFor(lang string) string {
+	return strings.ReplaceAll(c.TargetDir, LangPlaceholder, lang)
+}
+
+// parseResumeRetry 校验续跑重试策略并展开 all / none，返回需要重试的失败类别。
+func parseResumeRetry(items []string) ([]string, error) {
+	var classes []string
+	for _, item := range items {
+		switch item = strings.ToLower(item); {
+		case item == "all":
+			classes = append(classes, FailureClasses...)
+		case item == "none":
+		case slices.Contains(FailureClasses, item):
+			classes = append(classes, item)
+		default:
+			return nil, fmt.Errorf("无效的续跑重试策略 '%s' (--resume-retry), 可选值: all, none, %s", item, strings.Join(FailureClasses, ", "))
+		}
+	}
+	slices.Sort(classes)
+	return slices.Compact(classes), nil
+}
+
+// splitList 将逗号分隔的列表拆分为去除空白后的非空项。
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // loadTomlConfig 从 TOML 文件加载配置
 func loadTomlConfig(cfg *Config) error {
 	var tomlCfg TomlConfig
@@ -165,6 +881,77 @@ func loadTomlConfig(cfg *Config) error {
 		cfg.LLMModel = tomlCfg.API.Model
 		fmt.Printf("从配置文件设置模型: %s\n", cfg.LLMModel)
 	}
+	if tomlCfg.API.MaxAttempts > 0 {
+		cfg.RetryMaxAttempts = tomlCfg.API.MaxAttempts
+		fmt.Printf("从配置文件设置最多尝试次数: %d\n", cfg.RetryMaxAttempts)
+	}
+	if tomlCfg.API.MaxElapsed > 0 {
+		cfg.RetryMaxElapsed = tomlCfg.API.MaxElapsed
+		fmt.Printf("从配置文件设置最长重试时间: %v\n", cfg.RetryMaxElapsed)
+	}
+	if tomlCfg.API.RequestsPerMinute > 0 {
+		cfg.RequestsPerMinute = tomlCfg.API.RequestsPerMinute
+		fmt.Printf("从配置文件设置每分钟请求数上限: %d\n", cfg.RequestsPerMinute)
+	}
+	if tomlCfg.API.TokensPerMinute > 0 {
+		cfg.TokensPerMinute = tomlCfg.API.TokensPerMinute
+		fmt.Printf("从配置文件设置每分钟 token 数上限: %d\n", cfg.TokensPerMinute)
+	}
+
+	if tomlCfg.API.Stream != nil {
+		cfg.Stream = *tomlCfg.API.Stream
+		fmt.Printf("从配置文件设置流式响应: %t\n", cfg.Stream)
+	}
+	if tomlCfg.API.StreamIdleTimeout > 0 {
+		cfg.StreamIdleTimeout = tomlCfg.API.StreamIdleTimeout
+		fmt.Printf("从配置文件设置流式空闲超时: %v\n", cfg.StreamIdleTimeout)
+	}
+	if tomlCfg.API.KeepAlive != "" {
+		cfg.KeepAlive = tomlCfg.API.KeepAlive
+		fmt.Printf("从配置文件设置模型保留时间: %s\n", cfg.KeepAlive)
+	}
+	if tomlCfg.API.ContextLength > 0 {
+		cfg.ContextLength = tomlCfg.API.ContextLength
+		fmt.Printf("从配置文件设置上下文长度: %d\n", cfg.ContextLength)
+	}
+	if tomlCfg.API.MaxOutputTokens > 0 {
+		cfg.MaxOutputTokens = tomlCfg.API.MaxOutputTokens
+		fmt.Printf("从配置文件设置输出 token 上限: %d\n", cfg.MaxOutputTokens)
+	}
+	if tomlCfg.API.PullModel != nil {
+		cfg.PullModel = *tomlCfg.API.PullModel
+		fmt.Printf("从配置文件设置自动拉取模型: %t\n", cfg.PullModel)
+	}
+
+	if len(tomlCfg.API.Params) > 0 {
+		cfg.LLMParams = tomlCfg.API.Params
+		fmt.Printf("从配置文件设置生成参数: %d 项\n", len(cfg.LLMParams))
+	}
+	if len(tomlCfg.API.Headers) > 0 {
+		cfg.LLMHeaders = tomlCfg.API.Headers
+		fmt.Printf("从配置文件设置附加 Header: %d 项\n", len(cfg.LLMHeaders))
+	}
+	if len(tomlCfg.Providers) > 0 {
+		cfg.Providers = tomlCfg.Providers
+		fmt.Printf("从配置文件加载提供商配置: %s\n", strings.Join(slices.Sorted(maps.Keys(cfg.Providers)), ", "))
+	}
+	// 命令行的 --profile 优先于 general.profile，便于在不修改配置文件的情况下切换提供商
+	if tomlCfg.General.Profile != "" && cfg.Profile == "" {
+		cfg.Profile = tomlCfg.General.Profile
+		fmt.Printf("从配置文件设置提供商配置: %s\n", cfg.Profile)
+	}
+	if len(tomlCfg.Routes) > 0 {
+		cfg.Routes = tomlCfg.Routes
+		fmt.Printf("从配置文件加载路由规则: %d 条\n", len(cfg.Routes))
+	}
+	if len(tomlCfg.API.Fallback) > 0 {
+		cfg.Fallbacks = tomlCfg.API.Fallback
+		labels := make([]string, len(cfg.Fallbacks))
+		for i, fb := range cfg.Fallbacks {
+			labels[i] = fb.Label()
+		}
+		fmt.Printf("从配置文件设置备用提供商: %s\n", strings.Join(labels, " -> "))
+	}
 
 	// 常规设置
 	if tomlCfg.General.SourceDir != "" {
@@ -175,6 +962,14 @@ func loadTomlConfig(cfg *Config) error {
 		cfg.TargetDir = tomlCfg.General.TargetDir
 		fmt.Printf("从配置文件设置目标目录: %s\n", cfg.TargetDir)
 	}
+	if tomlCfg.General.SourceLang != "" {
+		cfg.SourceLang = tomlCfg.General.SourceLang
+		fmt.Printf("从配置文件设置源语言: %s\n", cfg.SourceLang)
+	}
+	if len(tomlCfg.General.TargetLangs) > 0 {
+		cfg.TargetLanguages = tomlCfg.General.TargetLangs
+		fmt.Printf("从配置文件设置目标语言: %s\n", strings.Join(cfg.TargetLanguages, ", "))
+	}
 	if tomlCfg.General.Concurrency > 0 {
 		cfg.Concurrency = tomlCfg.General.Concurrency
 		fmt.Printf("从配置文件设置并发数: %d\n", cfg.Concurrency)
@@ -184,12 +979,124 @@ func loadTomlConfig(cfg *Config) error {
 		fmt.Printf("从配置文件设置 Prompt 文件: %s\n", cfg.PromptFile)
 	}
 
+	if tomlCfg.General.DrainTimeout > 0 {
+		cfg.DrainTimeout = tomlCfg.General.DrainTimeout
+		fmt.Printf("从配置文件设置排空时间: %v\n", cfg.DrainTimeout)
+	}
+
+	if tomlCfg.General.Validate != "" {
+		cfg.ValidatePolicy = validator.Policy(tomlCfg.General.Validate)
+		fmt.Printf("从配置文件设置结构校验策略: %s\n", cfg.ValidatePolicy)
+	}
+
+	if tomlCfg.General.Protect != nil {
+		cfg.Protect = *tomlCfg.General.Protect
+		fmt.Printf("从配置文件设置内容保护: %t\n", cfg.Protect)
+	}
+
+	// 分片设置
+	if tomlCfg.Chunking.MaxTokens > 0 {
+		cfg.ChunkMaxTokens = tomlCfg.Chunking.MaxTokens
+		fmt.Printf("从配置文件设置片段大小: %d\n", cfg.ChunkMaxTokens)
+	}
+	if tomlCfg.Chunking.Concurrency > 0 {
+		cfg.ChunkConcurrency = tomlCfg.Chunking.Concurrency
+		fmt.Printf("从配置文件设置片段并发数: %d\n", cfg.ChunkConcurrency)
+	}
+	if tomlCfg.Chunking.Context != nil {
+		cfg.ChunkContext = *tomlCfg.Chunking.Context
+		fmt.Printf("从配置文件设置片段上下文: %t\n", cfg.ChunkContext)
+	}
+
+	// 用量与费用设置
+	if tomlCfg.Usage.Ledger != "" {
+		cfg.UsageLedger = tomlCfg.Usage.Ledger
+		fmt.Printf("从配置文件设置用量账本: %s\n", cfg.UsageLedger)
+	}
+	if tomlCfg.Usage.Currency != "" {
+		cfg.Currency = tomlCfg.Usage.Currency
+	}
+	if len(tomlCfg.Usage.Prices) > 0 {
+		cfg.Prices = tomlCfg.Usage.Prices
+		fmt.Printf("从配置文件加载价格表: %d 项\n", len(cfg.Prices))
+	}
+	if tomlCfg.Usage.MaxCost > 0 {
+		cfg.MaxCost = tomlCfg.Usage.MaxCost
+		fmt.Printf("从配置文件设置费用上限: %.4f\n", cfg.MaxCost)
+	}
+	if tomlCfg.Usage.MaxTokensTotal > 0 {
+		cfg.MaxTokensTotal = tomlCfg.Usage.MaxTokensTotal
+		fmt.Printf("从配置文件设置 token 总数上限: %d\n", cfg.MaxTokensTotal)
+	}
+
+	// 翻译缓存设置
+	if tomlCfg.Cache.Dir != "" {
+		cfg.CacheDir = tomlCfg.Cache.Dir
+		fmt.Printf("从配置文件设置翻译缓存目录: %s\n", cfg.CacheDir)
+	}
+
+	// front matter 设置 (keys = [] 表示不翻译 front matter)
+	if tomlCfg.FrontMatter.Keys != nil {
+		cfg.FrontMatterKeys = tomlCfg.FrontMatter.Keys
+		fmt.Printf("从配置文件设置 front matter 翻译键: %s\n", strings.Join(cfg.FrontMatterKeys, ", "))
+	}
+
+	// 术语表设置
+	if tomlCfg.Glossary.File != "" {
+		cfg.GlossaryFile = tomlCfg.Glossary.File
+		fmt.Printf("从配置文件设置术语表: %s\n", cfg.GlossaryFile)
+	}
+
+	// 翻译记忆设置
+	if tomlCfg.TranslationMemory.File != "" {
+		cfg.MemoryFile = tomlCfg.TranslationMemory.File
+		fmt.Printf("从配置文件设置翻译记忆: %s\n", cfg.MemoryFile)
+	}
+	if tomlCfg.TranslationMemory.FuzzyThreshold != nil {
+		cfg.MemoryFuzzy = *tomlCfg.TranslationMemory.FuzzyThreshold
+		fmt.Printf("从配置文件设置翻译记忆模糊匹配阈值: %.2f\n", cfg.MemoryFuzzy)
+	}
+
+	// 文件发现设置
+	if len(tomlCfg.Discovery.Extensions) > 0 {
+		cfg.Extensions = tomlCfg.Discovery.Extensions
+		fmt.Printf("从配置文件设置文件扩展名: %s\n", strings.Join(cfg.Extensions, ", "))
+	}
+	if len(tomlCfg.Discovery.Include) > 0 {
+		cfg.Include = tomlCfg.Discovery.Include
+		fmt.Printf("从配置文件设置包含模式: %s\n", strings.Join(cfg.Include, ", "))
+	}
+	if len(tomlCfg.Discovery.Exclude) > 0 {
+		cfg.Exclude = tomlCfg.Discovery.Exclude
+		fmt.Printf("从配置文件设置排除模式: %s\n", strings.Join(cfg.Exclude, ", "))
+	}
+	if tomlCfg.Discovery.Gitignore != nil {
+		cfg.UseGitignore = *tomlCfg.Discovery.Gitignore
+		fmt.Printf("从配置文件设置是否遵循 .gitignore: %v\n", cfg.UseGitignore)
+	}
+
 	// 覆盖模式需要特殊处理，因为它是布尔值
 	// 只有当配置文件中明确指定时才应用
 	cfg.Overwrite = tomlCfg.General.Overwrite
 	if tomlCfg.General.Overwrite {
 		fmt.Println("从配置文件启用覆盖模式")
 	}
+	if tomlCfg.General.ChangedOnly {
+		cfg.ChangedOnly = true
+		fmt.Println("从配置文件启用变更模式 (只翻译有变化的文件)")
+	}
+	if tomlCfg.General.Incremental {
+		cfg.Incremental = true
+		fmt.Println("从配置文件启用增量模式 (只翻译有变化的段落)")
+	}
+	if tomlCfg.General.FailedReport != "" {
+		cfg.FailedReport = tomlCfg.General.FailedReport
+		fmt.Printf("从配置文件设置失败报告: %s\n", cfg.FailedReport)
+	}
+	if tomlCfg.General.ResumeRetry != "" {
+		cfg.ResumeRetry = splitList(tomlCfg.General.ResumeRetry)
+		fmt.Printf("从配置文件设置续跑重试策略: %s\n", tomlCfg.General.ResumeRetry)
+	}
 
 	return nil
 }
@@ -198,18 +1105,30 @@ func loadTomlConfig(cfg *Config) error {
 // 这个模板是给 LLM 的指令，保持英文可能更通用。
 func getDefaultPromptTemplate() string {
 	return `You are a translation assistant specialized in command-line tool documentation (like tldr pages).
-Translate the following Markdown content from English to Simplified Chinese.
+Translate the following Markdown content from {{.SourceLang}} to {{.TargetLang}}.
 
 **Crucial Instructions:**
-1.  Preserve the original Markdown formatting EXACTLY (code blocks with backticks ` + "``" + `, {{placeholders}}, links, headers, lists, etc.).
+1.  Preserve the original Markdown formatting EXACTLY (code blocks with backticks ` + "``" + `, {{"{{"}}placeholders{{"}}"}}, links, headers, lists, etc.).
 2.  Ensure technical terms are translated accurately and consistently in the context of command-line usage.
 3.  ONLY output the translated Markdown content. Do NOT include any other explanatory text before or after.
 4.  Wrap your ENTIRE translated Markdown output within <translate> tags. Example: <translate># translated content...</translate>
+{{if .Protected}}5.  Tokens like @@MT001@@ stand for protected content (code, URLs, placeholders, already translated paragraphs). Keep every token EXACTLY as is, exactly once, and do not add new ones.
+{{end}}
+{{if .Glossary}}**Glossary** (translate these terms exactly as given; terms marked "do not translate" must stay unchanged):
+{{.Glossary}}
+
+{{end}}{{if .References}}**Reference translations** of similar paragraphs from earlier documents (reuse their wording where it fits; do not output them):
+{{.References}}
+
+{{end}}{{if .Context}}For context only, this is the preceding part of the same document. Do NOT translate or output it:
+---
+{{.Context}}
+---
 
-Original English Markdown:
+{{end}}Original {{.SourceLang}} Markdown:
 ---
 {{.Content}}
 ---
 
-Translated Chinese Markdown (within <translate> tags):`
+Translated {{.TargetLang}} Markdown (within <translate> tags):`
 }
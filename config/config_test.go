@@ -0,0 +1,195 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseResumeRetry(t *testing.T) {
+	all := slices.Sorted(slices.Values(FailureClasses))
+	tests := []struct {
+		name    string
+		items   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", items: []string{"all"}, want: all},
+		{name: "none", items: []string{"none"}, want: nil},
+		{name: "没有指定", items: nil, want: nil},
+		{name: "单个类别", items: []string{"transient"}, want: []string{"transient"}},
+		{name: "排序并去重", items: []string{"validation", "api", "validation"}, want: []string{"api", "validation"}},
+		{name: "不区分大小写", items: []string{"IO", "Truncated"}, want: []string{"io", "truncated"}},
+		{name: "all 与其他类别合并", items: []string{"api", "all"}, want: all},
+		{name: "none 与其他类别合并", items: []string{"none", "io"}, want: []string{"io"}},
+		{name: "无效的类别", items: []string{"transient", "timeout"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResumeRetry(tt.items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResumeRetry(%q) 错误 = %v, 期望错误: %t", tt.items, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseResumeRetry(%q) = %q, 期望 %q", tt.items, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"all", []string{"all"}},
+		{" api , io ,, ", []string{"api", "io"}},
+		{",", nil},
+	}
+	for _, tt := range tests {
+		if got := splitList(tt.s); !slices.Equal(got, tt.want) {
+			t.Errorf("splitList(%q) = %q, 期望 %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestOutputTokenLimit(t *testing.T) {
+	size := func(n int) *int { return &n }
+	tests := []struct {
+		name string
+		cfg  Config
+		want int
+	}{
+		{name: "指定上限", cfg: Config{MaxOutputTokens: 1000, ChunkMaxTokens: 3000}, want: 1000},
+		{name: "片段大小的两倍", cfg: Config{ChunkMaxTokens: 3000}, want: 6000},
+		{name: "不低于最小值", cfg: Config{ChunkMaxTokens: 500}, want: MinOutputTokens},
+		{name: "不切分", cfg: Config{}, want: 2 * MinOutputTokens},
+		{
+			name: "取路由规则中最大的片段大小",
+			cfg:  Config{ChunkMaxTokens: 3000, Routes: []Route{{ChunkSize: size(5000)}, {ChunkSize: size(1000)}, {}}},
+			want: 10000,
+		},
+		{
+			name: "路由规则不切分",
+			cfg:  Config{ChunkMaxTokens: 3000, Routes: []Route{{ChunkSize: size(0)}}},
+			want: 2 * MinOutputTokens,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.OutputTokenLimit(); got != tt.want {
+				t.Errorf("OutputTokenLimit() = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteFor(t *testing.T) {
+	cfg := Config{Routes: []Route{
+		{Name: "api", Match: []string{"api/**"}},
+		{Name: "large", Match: []string{"guide/*.md", "**/README.md"}, MinTokens: 1000},
+		{Name: "small", MaxTokens: 100},
+		{Name: "medium", MinTokens: 200, MaxTokens: 500},
+	}}
+	tests := []struct {
+		name    string
+		relPath string
+		tokens  int
+		want    string
+	}{
+		{name: "** 匹配任意层级目录", relPath: "api/v1/users.md", tokens: 5000, want: "api"},
+		{name: "** 匹配零层目录", relPath: "api/index.md", tokens: 5000, want: "api"},
+		{name: "第一条匹配的规则优先", relPath: "api/a.md", tokens: 50, want: "api"},
+		{name: "任一模式匹配即可", relPath: "docs/README.md", tokens: 1000, want: "large"},
+		{name: "下限包含边界", relPath: "guide/intro.md", tokens: 1000, want: "large"},
+		{name: "低于下限", relPath: "guide/intro.md", tokens: 999, want: ""},
+		{name: "* 不跨越目录", relPath: "guide/sub/intro.md", tokens: 5000, want: ""},
+		{name: "没有匹配模式时只按大小匹配", relPath: "other/a.md", tokens: 100, want: "small"},
+		{name: "上限包含边界", relPath: "other/a.md", tokens: 500, want: "medium"},
+		{name: "区间之间", relPath: "other/a.md", tokens: 150, want: ""},
+		{name: "超过上限", relPath: "other/a.md", tokens: 501, want: ""},
+		{name: "转换路径分隔符", relPath: filepath.Join("api", "v1", "a.md"), tokens: 5000, want: "api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := cfg.RouteFor(tt.relPath, tt.tokens)
+			got := ""
+			if r != nil {
+				got = r.Name
+			}
+			if got != tt.want {
+				t.Errorf("RouteFor(%q, %d) = %q, 期望 %q", tt.relPath, tt.tokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	size := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		routes  []Route
+		wantErr bool
+	}{
+		{name: "有效的规则", routes: []Route{{Match: []string{"api/**", "*.md"}, MinTokens: 10, MaxTokens: 10, ChunkSize: size(0)}}},
+		{name: "无效的匹配模式", routes: []Route{{Match: []string{"api/[a"}}}, wantErr: true},
+		{name: "下限大于上限", routes: []Route{{MinTokens: 200, MaxTokens: 100}}, wantErr: true},
+		{name: "负数的下限", routes: []Route{{MinTokens: -1}}, wantErr: true},
+		{name: "负数的片段大小", routes: []Route{{ChunkSize: size(-1)}}, wantErr: true},
+		{name: "负数的分片并发数", routes: []Route{{ChunkConcurrency: -1}}, wantErr: true},
+		{name: "引用的提供商配置不存在", routes: []Route{{Profile: "missing"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Routes: tt.routes}
+			if err := cfg.validateRoutes(); (err != nil) != tt.wantErr {
+				t.Errorf("validateRoutes() 错误 = %v, 期望错误: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForRoute(t *testing.T) {
+	size := func(n int) *int { return &n }
+	temperature := 0.2
+	cfg := Config{
+		LLMProvider:    "openai",
+		LLMModel:       "global",
+		LLMParams:      map[string]any{"top_p": 0.9},
+		ChunkMaxTokens: 3000,
+		Providers: map[string]ProviderProfile{
+			"fast": {Provider: "gemini", Key: "k", Model: "flash", Params: map[string]any{"top_k": 40}},
+		},
+		Routes: []Route{
+			{Name: "chunk", ChunkSize: size(0)},
+			{Name: "temperature", Temperature: &temperature},
+			{Name: "profile", Profile: "fast", Temperature: &temperature},
+		},
+	}
+	if err := cfg.validateRoutes(); err != nil {
+		t.Fatalf("validateRoutes() 错误: %v", err)
+	}
+
+	if got := cfg.ForRoute(nil); got != &cfg {
+		t.Error("ForRoute(nil) 应返回配置本身")
+	}
+	if got := cfg.ForRoute(&cfg.Routes[0]); got.ChunkMaxTokens != 0 || got.LLMModel != "global" {
+		t.Errorf("片段大小 = %d, 模型 = %q, 期望 0 和 global", got.ChunkMaxTokens, got.LLMModel)
+	}
+	if got := cfg.ForRoute(&cfg.Routes[1]); got.LLMParams["temperature"] != 0.2 || got.LLMParams["top_p"] != 0.9 {
+		t.Errorf("生成参数 = %v, 期望在全局参数上设置 temperature", got.LLMParams)
+	}
+	got := cfg.ForRoute(&cfg.Routes[2])
+	if got.LLMProvider != "gemini" || got.LLMModel != "flash" || got.LLMParams["temperature"] != 0.2 || got.LLMParams["top_k"] != 40 {
+		t.Errorf("提供商 = %s/%s, 生成参数 = %v, 期望使用提供商配置 fast 并设置 temperature", got.LLMProvider, got.LLMModel, got.LLMParams)
+	}
+
+	// 规则的 temperature 不应修改全局配置或提供商配置中的参数
+	if _, ok := cfg.LLMParams["temperature"]; ok || cfg.ChunkMaxTokens != 3000 {
+		t.Errorf("全局配置被修改: 生成参数 = %v, 片段大小 = %d", cfg.LLMParams, cfg.ChunkMaxTokens)
+	}
+	if _, ok := cfg.Providers["fast"].Params["temperature"]; ok {
+		t.Errorf("提供商配置被修改: %v", cfg.Providers["fast"].Params)
+	}
+}
@@ -0,0 +1,4 @@
+package config
+
+// DefaultPromptTemplate 导出默认 Prompt 模板，供外部测试包 (需要 translator.PromptData) 使用。
+var DefaultPromptTemplate = getDefaultPromptTemplate
@@ -0,0 +1,61 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+func TestDefaultPromptTemplate(t *testing.T) {
+	tmpl, err := template.New("prompt").Parse(config.DefaultPromptTemplate())
+	if err != nil {
+		t.Fatalf("解析默认 Prompt 模板失败: %v", err)
+	}
+	tests := []struct {
+		name    string
+		data    translator.PromptData
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "零值",
+			data:    translator.PromptData{},
+			want:    []string{"{{placeholders}}", "<translate>"},
+			notWant: []string{"@@MT001@@", "**Glossary**", "**Reference translations**", "For context only"},
+		},
+		{
+			name: "所有可选内容",
+			data: translator.PromptData{
+				Content:    "# Hello",
+				Context:    "previous chunk",
+				Protected:  true,
+				Glossary:   "- API → API",
+				References: "- \"a\" → \"甲\"",
+				SourceLang: "English",
+				TargetLang: "Simplified Chinese",
+			},
+			want: []string{"from English to Simplified Chinese", "# Hello", "previous chunk", "@@MT001@@", "- API → API", "\"甲\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := translator.RenderPrompt(tmpl, tt.data)
+			if err != nil {
+				t.Fatalf("RenderPrompt() 错误: %v", err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(prompt, s) {
+					t.Errorf("Prompt 中缺少 %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(prompt, s) {
+					t.Errorf("Prompt 中不应包含 %q", s)
+				}
+			}
+		})
+	}
+}
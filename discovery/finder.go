@@ -6,14 +6,41 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"Markdown-translator-go/utils"
 )
 
-// FindMarkdownFiles 递归地查找指定源目录下的所有 `.md` 文件。
+// Options 是查找文件时的过滤规则。
+type Options struct {
+	Extensions []string // 需要查找的文件扩展名 (小写，含 ".")，为空时只查找 .md
+	Include    []string // 相对路径的 glob 模式 (支持 **)，非空时只包含匹配其中任意一个的文件
+	Exclude    []string // 相对路径的 glob 模式 (支持 **)，排除匹配其中任意一个的文件
+	Gitignore  bool     // 是否同时遵循各级目录中的 .gitignore (.mdtranslateignore 总是生效)
+	Explain    bool     // 是否在日志中逐一说明被排除的文件和目录及其原因 (空跑模式)
+}
+
+// FindMarkdownFiles 递归地查找指定源目录下扩展名属于 opts.Extensions (默认 `.md`) 的所有文件。
 // 它返回一个包含相对于源目录的文件路径的字符串切片。
-func FindMarkdownFiles(sourceDir string) ([]string, error) {
+// 各级目录中的 .mdtranslateignore (以及启用时的 .gitignore) 按 gitignore 的语义排除文件和目录，
+// 之后再按 opts 中的 exclude / include 模式过滤。
+func FindMarkdownFiles(sourceDir string, opts Options) ([]string, error) {
 	var files []string
 	log.Printf("开始在目录中查找文件: %s\n", sourceDir)
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = []string{".md"}
+	}
+
+	// 每个已访问目录 (相对路径，以 "/" 分隔) 生效的忽略规则
+	rulesByDir := map[string]ignoreRules{}
+	excludedFiles, excludedDirs := 0, 0
+	exclude := func(rel, kind, reason string) {
+		if opts.Explain {
+			log.Printf("[空跑模式] 排除%s %s: %s\n", kind, rel, reason)
+		}
+	}
 
 	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, walkErr error) error {
 		// 首先处理 WalkDir 本身可能遇到的错误
@@ -37,8 +64,40 @@ func FindMarkdownFiles(sourceDir string) ([]string, error) {
 			return nil // 跳过出错的单个文件条目
 		}
 
-		// 检查是否是文件并且后缀是 .md (不区分大小写)
-		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+		// 目录: 检查是否被上级目录的规则忽略，然后加载该目录中的忽略文件
+		if d.IsDir() {
+			rel, err := relSlash(sourceDir, path)
+			if err != nil {
+				log.Printf("警告: 无法获取 %q 相对于 %q 的路径: %v\n", path, sourceDir, err)
+				return fs.SkipDir
+			}
+			parent := rulesByDir[parentDir(rel)]
+			if rel != "" {
+				if rule, ignored := parent.match(rel, true); ignored {
+					excludedDirs++
+					exclude(rel+"/", "目录", fmt.Sprintf("被 %s 的规则 '%s' 忽略", rule.source, rule.text))
+					return fs.SkipDir
+				}
+			}
+			rules := parent
+			names := []string{IgnoreFileName}
+			if opts.Gitignore {
+				// .mdtranslateignore 在后，同一目录中它的规则优先
+				names = []string{".gitignore", IgnoreFileName}
+			}
+			for _, name := range names {
+				own, err := loadIgnoreFile(path, rel, name)
+				if err != nil {
+					return err
+				}
+				rules = append(slices.Clip(rules), own...)
+			}
+			rulesByDir[rel] = rules
+			return nil
+		}
+
+		// 检查是否是文件并且扩展名在列表中 (不区分大小写)
+		if slices.Contains(extensions, strings.ToLower(filepath.Ext(d.Name()))) {
 			// 计算相对于 sourceDir 的路径
 			relPath, err := filepath.Rel(sourceDir, path)
 			if err != nil {
@@ -47,6 +106,12 @@ func FindMarkdownFiles(sourceDir string) ([]string, error) {
 				log.Printf("警告: 无法获取 %q 相对于 %q 的路径: %v\n", path, sourceDir, err)
 				return nil // 跳过这个文件
 			}
+			rel := filepath.ToSlash(relPath)
+			if reason, excluded := opts.excludeReason(rel, rulesByDir[parentDir(rel)]); excluded {
+				excludedFiles++
+				exclude(rel, "文件", reason)
+				return nil
+			}
 			files = append(files, relPath)
 			// log.Printf("发现文件: %s\n", relPath) // 如果需要详细日志，取消此行注释
 		}
@@ -59,6 +124,45 @@ func FindMarkdownFiles(sourceDir string) ([]string, error) {
 		return nil, fmt.Errorf("文件发现失败: %w", err)
 	}
 
+	if excludedFiles > 0 || excludedDirs > 0 {
+		log.Printf("已按忽略文件和 include/exclude 规则排除 %d 个文件、%d 个目录。\n", excludedFiles, excludedDirs)
+	}
 	log.Printf("文件查找完成。共发现 %d 个 markdown 文件。\n", len(files))
 	return files, nil
 }
+
+// excludeReason 依次按忽略规则、exclude 和 include 模式检查文件 rel，返回是否排除及原因。
+func (opts Options) excludeReason(rel string, rules ignoreRules) (string, bool) {
+	if rule, ignored := rules.match(rel, false); ignored {
+		return fmt.Sprintf("被 %s 的规则 '%s' 忽略", rule.source, rule.text), true
+	}
+	for _, pattern := range opts.Exclude {
+		if utils.MatchGlob(pattern, rel) {
+			return fmt.Sprintf("匹配 exclude 模式 '%s'", pattern), true
+		}
+	}
+	if len(opts.Include) > 0 && !slices.ContainsFunc(opts.Include, func(p string) bool { return utils.MatchGlob(p, rel) }) {
+		return "不匹配任何 include 模式", true
+	}
+	return "", false
+}
+
+// relSlash 返回 path 相对于 sourceDir 的路径 (以 "/" 分隔，sourceDir 本身为 "")。
+func relSlash(sourceDir, path string) (string, error) {
+	rel, err := filepath.Rel(sourceDir, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// parentDir 返回以 "/" 分隔的相对路径 rel 的上级目录 (源目录本身为 "")。
+func parentDir(rel string) string {
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTree 在临时目录中创建 files (以 "/" 分隔的相对路径 → 内容) 并返回该目录。
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFindMarkdownFiles(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		".gitignore":              "generated/\n*.gen.md\n",
+		IgnoreFileName:            "# 草稿\ndrafts/\n!keep.gen.md\n",
+		"a.md":                    "",
+		"b.MD":                    "",
+		"c.txt":                   "",
+		"x.gen.md":                "",
+		"keep.gen.md":             "",
+		"drafts/d.md":             "",
+		"generated/g.md":          "",
+		"guide/" + IgnoreFileName: "/local.md\n",
+		"guide/local.md":          "",
+		"guide/intro.md":          "",
+		"guide/sub/local.md":      "",
+	})
+
+	tests := []struct {
+		name        string
+		opts        Options
+		want        []string
+		wantReasons []string
+	}{
+		{
+			name: "遵循 .gitignore",
+			opts: Options{Gitignore: true, Exclude: []string{"guide/intro.md"}, Explain: true},
+			want: []string{"a.md", "b.MD", "guide/sub/local.md", "keep.gen.md"},
+			wantReasons: []string{
+				"排除目录 drafts/: 被 .mdtranslateignore:2 的规则 'drafts/' 忽略",
+				"排除目录 generated/: 被 .gitignore:1 的规则 'generated/' 忽略",
+				"排除文件 guide/intro.md: 匹配 exclude 模式 'guide/intro.md'",
+				"排除文件 guide/local.md: 被 guide/.mdtranslateignore:1 的规则 '/local.md' 忽略",
+				"排除文件 x.gen.md: 被 .gitignore:2 的规则 '*.gen.md' 忽略",
+			},
+		},
+		{
+			name: "不遵循 .gitignore",
+			opts: Options{Explain: true},
+			want: []string{"a.md", "b.MD", "generated/g.md", "guide/intro.md", "guide/sub/local.md", "keep.gen.md", "x.gen.md"},
+			wantReasons: []string{
+				"排除目录 drafts/: 被 .mdtranslateignore:2 的规则 'drafts/' 忽略",
+				"排除文件 guide/local.md: 被 guide/.mdtranslateignore:1 的规则 '/local.md' 忽略",
+			},
+		},
+		{
+			name: "include 模式和扩展名",
+			opts: Options{Extensions: []string{".md", ".txt"}, Include: []string{"*.txt", "guide/**"}, Explain: true},
+			want: []string{"c.txt", "guide/intro.md", "guide/sub/local.md"},
+			wantReasons: []string{
+				"排除文件 a.md: 不匹配任何 include 模式",
+				"排除文件 generated/g.md: 不匹配任何 include 模式",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			files, err := FindMarkdownFiles(dir, tt.opts)
+			if err != nil {
+				t.Fatalf("FindMarkdownFiles() 错误: %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				got = append(got, filepath.ToSlash(f))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindMarkdownFiles() = %q, 期望 %q", got, tt.want)
+			}
+			for _, reason := range tt.wantReasons {
+				if !strings.Contains(buf.String(), "[空跑模式] "+reason+"\n") {
+					t.Errorf("日志中缺少排除原因 %q:\n%s", reason, buf.String())
+				}
+			}
+		})
+	}
+}
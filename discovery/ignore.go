@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"Markdown-translator-go/utils"
+)
+
+// IgnoreFileName 是忽略文件的名称，可以放在源目录的任意层级，语法与 .gitignore 相同。
+const IgnoreFileName = ".mdtranslateignore"
+
+// ignoreRule 是忽略文件中的一条规则。
+type ignoreRule struct {
+	base     string // 忽略文件所在目录 (相对源目录，以 "/" 分隔，源目录本身为 "")
+	pattern  string // 去掉 "!"、开头和结尾的 "/" 之后的模式
+	negate   bool   // 以 "!" 开头: 重新包含之前被忽略的路径
+	dirOnly  bool   // 以 "/" 结尾: 只匹配目录
+	anchored bool   // 模式中含有 "/": 相对 base 匹配完整路径，否则匹配任意层级的名称
+	source   string // 规则来源，例如 "docs/.mdtranslateignore:3"，用于说明排除原因
+	text     string // 规则原文
+}
+
+// matches 报告规则是否匹配相对源目录的路径 rel (以 "/" 分隔)。
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	sub := rel
+	if r.base != "" {
+		var ok bool
+		if sub, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if r.anchored {
+		return utils.MatchGlob(r.pattern, sub)
+	}
+	ok, _ := path.Match(r.pattern, path.Base(sub))
+	return ok
+}
+
+// ignoreRules 是对某个目录生效的全部规则: 上级目录的规则在前，越深的目录越靠后。
+type ignoreRules []ignoreRule
+
+// match 按 gitignore 的语义返回最后一条匹配 rel 的规则; ignored 表示该规则是否忽略 rel (即不是 "!" 规则)。
+func (rs ignoreRules) match(rel string, isDir bool) (rule ignoreRule, ignored bool) {
+	for i := len(rs) - 1; i >= 0; i-- {
+		if rs[i].matches(rel, isDir) {
+			return rs[i], !rs[i].negate
+		}
+	}
+	return ignoreRule{}, false
+}
+
+// loadIgnoreFile 读取目录 dir (相对源目录为 base) 中名为 name 的忽略文件。文件不存在时返回 nil。
+// 语法错误的行会被跳过并记录警告。
+func loadIgnoreFile(dir, base, name string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取忽略文件失败: %w", err)
+	}
+	defer f.Close()
+
+	source := path.Join(base, name)
+	var rules ignoreRules
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		rule, ok := parseIgnoreLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		rule.base = base
+		rule.source = fmt.Sprintf("%s:%d", source, lineNo)
+		if err := utils.ValidateGlob(rule.pattern); err != nil {
+			log.Printf("警告: 忽略 %s 中的无效规则: %v\n", rule.source, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取忽略文件 %s 失败: %w", source, err)
+	}
+	return rules, nil
+}
+
+// parseIgnoreLine 按 .gitignore 的语法解析一行。空行和 "#" 开头的注释行返回 false。
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// 行尾的空格会被忽略，除非用 "\" 转义
+	trimmed := strings.TrimRight(line, " ")
+	if strings.HasSuffix(trimmed, `\`) && len(trimmed) < len(line) {
+		trimmed += " "
+	}
+	line = trimmed
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{text: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// 开头或中间的 "/" 表示相对忽略文件所在目录匹配
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
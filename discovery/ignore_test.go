@@ -0,0 +1,108 @@
+package discovery
+
+import "testing"
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want ignoreRule
+		ok   bool
+	}{
+		{line: ""},
+		{line: "   "},
+		{line: "# 注释"},
+		{line: "/"},
+		{line: "*.md", want: ignoreRule{pattern: "*.md", text: "*.md"}, ok: true},
+		{line: "draft.md  \r", want: ignoreRule{pattern: "draft.md", text: "draft.md"}, ok: true},
+		{line: `trailing\ `, want: ignoreRule{pattern: `trailing\ `, text: `trailing\ `}, ok: true},
+		{line: "!keep.md", want: ignoreRule{pattern: "keep.md", negate: true, text: "!keep.md"}, ok: true},
+		{line: `\!important.md`, want: ignoreRule{pattern: "!important.md", text: `\!important.md`}, ok: true},
+		{line: `\#hash.md`, want: ignoreRule{pattern: "#hash.md", text: `\#hash.md`}, ok: true},
+		{line: "build/", want: ignoreRule{pattern: "build", dirOnly: true, text: "build/"}, ok: true},
+		{line: "/root.md", want: ignoreRule{pattern: "root.md", anchored: true, text: "/root.md"}, ok: true},
+		{line: "docs/*.md", want: ignoreRule{pattern: "docs/*.md", anchored: true, text: "docs/*.md"}, ok: true},
+		{line: "!/docs/tmp/", want: ignoreRule{pattern: "docs/tmp", negate: true, dirOnly: true, anchored: true, text: "!/docs/tmp/"}, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := parseIgnoreLine(tt.line)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("parseIgnoreLine(%q) = %+v, %t, 期望 %+v, %t", tt.line, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIgnoreRuleMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		base  string
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"未锚定的模式匹配任意层级的名称", "*.tmp.md", "", "a/b/c.tmp.md", false, true},
+		{"未锚定的模式只匹配名称", "drafts", "", "docs/drafts", true, true},
+		{"锚定的模式只匹配 base 下的完整路径", "/drafts", "", "docs/drafts", true, false},
+		{"锚定的模式", "/drafts", "", "drafts", true, true},
+		{"中间的斜杠也表示锚定", "docs/*.md", "", "docs/a.md", false, true},
+		{"锚定的模式中 * 不跨越目录", "docs/*.md", "", "docs/sub/a.md", false, false},
+		{"锚定的模式支持 **", "docs/**/a.md", "", "docs/x/y/a.md", false, true},
+		{"只匹配目录的模式不匹配文件", "build/", "", "build", false, false},
+		{"只匹配目录的模式", "build/", "", "src/build", true, true},
+		{"相对 base 匹配", "/a.md", "guide", "guide/a.md", false, true},
+		{"相对 base 的锚定模式不匹配更深的路径", "/a.md", "guide", "guide/sub/a.md", false, false},
+		{"不在 base 下的路径不匹配", "*.md", "guide", "other/a.md", false, false},
+		{"base 是路径段前缀时不匹配", "*.md", "guide", "guidebook/a.md", false, false},
+		{"转义的行尾空格", `name\ `, "", "name ", false, true},
+		{"转义的 !", `\!bang.md`, "", "!bang.md", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := parseIgnoreLine(tt.line)
+			if !ok {
+				t.Fatalf("parseIgnoreLine(%q) 失败", tt.line)
+			}
+			rule.base = tt.base
+			if got := rule.matches(tt.rel, tt.isDir); got != tt.want {
+				t.Errorf("%q (base %q).matches(%q, %t) = %t, 期望 %t", tt.line, tt.base, tt.rel, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	var rules ignoreRules
+	for _, r := range []struct{ base, line string }{
+		{"", "*.md"},
+		{"", "!README.md"},
+		{"docs", "README.md"}, // 更深的目录中的规则在后，优先
+		{"docs", "!keep/*.md"},
+	} {
+		rule, _ := parseIgnoreLine(r.line)
+		rule.base = r.base
+		rules = append(rules, rule)
+	}
+	tests := []struct {
+		rel         string
+		wantIgnored bool
+		wantRule    string
+	}{
+		{"a.md", true, "*.md"},
+		{"README.md", false, "!README.md"},
+		{"sub/README.md", false, "!README.md"},
+		{"docs/README.md", true, "README.md"},
+		{"docs/keep/a.md", false, "!keep/*.md"},
+		{"docs/keep/sub/a.md", true, "*.md"},
+		{"a.txt", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			rule, ignored := rules.match(tt.rel, false)
+			if ignored != tt.wantIgnored || rule.text != tt.wantRule {
+				t.Errorf("match(%q) = %q, %t, 期望 %q, %t", tt.rel, rule.text, ignored, tt.wantRule, tt.wantIgnored)
+			}
+		})
+	}
+}
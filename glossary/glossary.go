@@ -0,0 +1,249 @@
+package glossary
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+
+	"Markdown-translator-go/markdown"
+	"Markdown-translator-go/utils"
+)
+
+// Entry 是术语表中的一个条目。
+type Entry struct {
+	Source         string `toml:"source"`           // 原文术语
+	Target         string `toml:"target"`           // 规定的译法 (DoNotTranslate 时可以为空，表示与原文相同)
+	Lang           string `toml:"lang"`             // 适用的目标语言代码，为空表示所有目标语言
+	CaseSensitive  bool   `toml:"case_sensitive"`   // 匹配原文和检查译文时是否区分大小写
+	DoNotTranslate bool   `toml:"do_not_translate"` // 不翻译: 译文中保持原文术语
+}
+
+// target 返回译文中应出现的术语。
+func (e Entry) target() string {
+	if e.DoNotTranslate && e.Target == "" {
+		return e.Source
+	}
+	return e.Target
+}
+
+// Glossary 是从 CSV 或 TOML 文件加载的术语表。nil 的 *Glossary 表示未配置术语表，所有方法都可以安全调用。
+type Glossary struct {
+	entries  []Entry
+	patterns []*regexp.Regexp // 与 entries 对应，用于在原文中查找术语
+	hash     string           // 术语表文件内容的 SHA-256
+}
+
+// Load 按扩展名加载术语表: .csv 或 .toml。
+//
+// CSV 的第一行为表头，列名为 source、target、lang、case_sensitive、do_not_translate (只有 source 是必需的，顺序任意)。
+// TOML 使用 [[terms]] 数组，字段名与 CSV 的列名相同。
+func Load(path string) (*Glossary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取术语表失败: %w", err)
+	}
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = parseCSV(string(data))
+	case ".toml":
+		var file struct {
+			Terms []Entry `toml:"terms"`
+		}
+		if _, err = toml.Decode(string(data), &file); err != nil {
+			err = fmt.Errorf("解析 TOML 失败: %w", err)
+		}
+		entries = file.Terms
+	default:
+		return nil, fmt.Errorf("不支持的术语表格式 '%s' (支持 .csv 和 .toml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("术语表 %s: %w", path, err)
+	}
+
+	g := &Glossary{hash: utils.HashString(string(data))}
+	for i, e := range entries {
+		e.Source = strings.TrimSpace(e.Source)
+		e.Target = strings.TrimSpace(e.Target)
+		e.Lang = strings.TrimSpace(e.Lang)
+		if e.Source == "" {
+			return nil, fmt.Errorf("术语表 %s: 第 %d 个条目缺少原文术语 (source)", path, i+1)
+		}
+		if e.Target == "" && !e.DoNotTranslate {
+			return nil, fmt.Errorf("术语表 %s: 术语 '%s' 缺少译法 (target)，不翻译的术语请设置 do_not_translate", path, e.Source)
+		}
+		pattern := regexp.QuoteMeta(e.Source)
+		if !e.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		g.entries = append(g.entries, e)
+		g.patterns = append(g.patterns, regexp.MustCompile(pattern))
+	}
+	return g, nil
+}
+
+// parseCSV 解析带表头的 CSV 术语表。
+func parseCSV(data string) ([]Entry, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取 CSV 表头失败: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["source"]; !ok {
+		return nil, fmt.Errorf("CSV 表头中缺少 source 列")
+	}
+
+	var entries []Entry
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取 CSV 失败: %w", err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		e := Entry{Source: field("source"), Target: field("target"), Lang: field("lang")}
+		for name, dst := range map[string]*bool{"case_sensitive": &e.CaseSensitive, "do_not_translate": &e.DoNotTranslate} {
+			if v := field(name); v != "" {
+				if *dst, err = strconv.ParseBool(v); err != nil {
+					return nil, fmt.Errorf("第 %d 行: %s 的值 '%s' 无效 (应为 true 或 false)", line, name, v)
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Len 返回术语表的条目数。
+func (g *Glossary) Len() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.entries)
+}
+
+// Hash 返回术语表文件内容的哈希，术语表变化时使用该术语表的译文应视为过期。
+func (g *Glossary) Hash() string {
+	if g == nil {
+		return ""
+	}
+	return g.hash
+}
+
+// Find 返回适用于目标语言 lang、且作为完整单词出现在 text 中的条目。
+func (g *Glossary) Find(text, lang string) []Entry {
+	if g == nil {
+		return nil
+	}
+	var found []Entry
+	for i, e := range g.entries {
+		if e.Lang != "" && !strings.EqualFold(e.Lang, lang) {
+			continue
+		}
+		if containsWord(g.patterns[i], text) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Format 将条目格式化为注入 Prompt 模板的 {{.Glossary}} 文本，每行一个术语。
+func Format(entries []Entry) string {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		if e.DoNotTranslate {
+			lines[i] = fmt.Sprintf("- %q → %q (do not translate)", e.Source, e.target())
+		} else {
+			lines[i] = fmt.Sprintf("- %q → %q", e.Source, e.Target)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Violation 表示原文中出现了术语，但译文中没有使用规定的译法。
+type Violation struct {
+	Entry Entry
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("原文包含 %q，译文中没有规定的译法 %q", v.Entry.Source, v.Entry.target())
+}
+
+// Check 检查译文是否使用了原文中出现的术语的规定译法 (代码块和行内代码中的术语不计)。
+func (g *Glossary) Check(source, translated, lang string) []Violation {
+	if g == nil {
+		return nil
+	}
+	var violations []Violation
+	for _, e := range g.Find(stripCode(source), lang) {
+		target, text := e.target(), translated
+		if !e.CaseSensitive {
+			target, text = strings.ToLower(target), strings.ToLower(text)
+		}
+		if !strings.Contains(text, target) {
+			violations = append(violations, Violation{Entry: e})
+		}
+	}
+	return violations
+}
+
+// stripCode 移除围栏代码块和行内代码，它们不会被翻译。
+func stripCode(text string) string {
+	rest, _ := markdown.FencedBlocks(text)
+	for _, span := range markdown.CodeSpans(rest) {
+		rest = strings.Replace(rest, span, "", 1)
+	}
+	return rest
+}
+
+// containsWord 报告 re 是否在 text 中匹配到一个完整的单词 (匹配两侧不是字母、数字或下划线)。
+// 中文、日文等不以空格分隔单词的文字不视为单词字符 (见 isWordRune)，因此这些文字中的术语以及紧挨着它们的术语都能匹配。
+func containsWord(re *regexp.Regexp, text string) bool {
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+		after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+		first, _ := utf8.DecodeRuneInString(text[loc[0]:loc[1]])
+		last, _ := utf8.DecodeLastRuneInString(text[loc[0]:loc[1]])
+		if (loc[0] == 0 || !isWordRune(first) || !isWordRune(before)) && (loc[1] == len(text) || !isWordRune(last) || !isWordRune(after)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsegmentedScripts 是不以空格分隔单词的文字，以及助词直接附在词后的韩文。
+// 这些文字中无法判断单词边界，术语在其中的任意位置都视为完整的单词。
+var unsegmentedScripts = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul,
+	unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar,
+}
+
+// isWordRune 报告 r 是否为单词字符。unsegmentedScripts 中的文字不是单词字符。
+func isWordRune(r rune) bool {
+	if unicode.IsOneOf(unsegmentedScripts, r) {
+		return false
+	}
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,183 @@
+package glossary
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeGlossary 将 content 写入临时目录中的 name 并加载。
+func writeGlossary(t *testing.T, name, content string) (*Glossary, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	want := []Entry{
+		{Source: "API", Target: "接口", CaseSensitive: true},
+		{Source: "Kubernetes", DoNotTranslate: true},
+		{Source: "pod", Target: "ポッド", Lang: "ja"},
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "CSV",
+			file: "terms.csv",
+			content: "# 注释\n" +
+				"Target, SOURCE, lang, case_sensitive, do_not_translate\n" +
+				"接口, API, , true,\n" +
+				", Kubernetes, , , true\n" +
+				"ポッド, pod, ja\n",
+		},
+		{
+			name: "TOML",
+			file: "terms.TOML",
+			content: "[[terms]]\nsource = \"API\"\ntarget = \"接口\"\ncase_sensitive = true\n\n" +
+				"[[terms]]\nsource = \" Kubernetes \"\ndo_not_translate = true\n\n" +
+				"[[terms]]\nsource = \"pod\"\ntarget = \"ポッド\"\nlang = \"ja\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := writeGlossary(t, tt.file, tt.content)
+			if err != nil {
+				t.Fatalf("Load() 错误: %v", err)
+			}
+			if !slices.Equal(g.entries, want) {
+				t.Errorf("entries = %+v, 期望 %+v", g.entries, want)
+			}
+			if g.Len() != len(want) || g.Hash() == "" {
+				t.Errorf("Len() = %d, Hash() = %q", g.Len(), g.Hash())
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"不支持的格式", "terms.txt", "API", "不支持的术语表格式"},
+		{"缺少 source 列", "terms.csv", "target\n接口\n", "缺少 source 列"},
+		{"布尔值无效", "terms.csv", "source,target,case_sensitive\nAPI,接口,maybe\n", "第 2 行"},
+		{"缺少原文", "terms.csv", "source,target\n,接口\n", "缺少原文术语"},
+		{"缺少译法", "terms.toml", "[[terms]]\nsource = \"API\"\n", "缺少译法"},
+		{"TOML 语法错误", "terms.toml", "[[terms]\n", "解析 TOML 失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := writeGlossary(t, tt.file, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() 错误 = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testGlossary 返回测试用的术语表。
+func testGlossary(t *testing.T) *Glossary {
+	t.Helper()
+	g, err := writeGlossary(t, "terms.csv", "source,target,lang,case_sensitive,do_not_translate\n"+
+		"API,接口,,true,\n"+
+		"cache,缓存,,,\n"+
+		"Kubernetes,,,,true\n"+
+		"GitHub,,,true,true\n"+
+		"pod,ポッド,ja,,\n"+
+		"数据库,database,en,,\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestFind(t *testing.T) {
+	g := testGlossary(t)
+	tests := []struct {
+		name string
+		text string
+		lang string
+		want []string
+	}{
+		{"区分大小写", "The API and the api", "zh", []string{"API"}},
+		{"区分大小写的术语不匹配其他大小写", "the api", "zh", nil},
+		{"不区分大小写", "The CACHE is warm", "zh", []string{"cache"}},
+		{"单词边界", "caches, APIs and cached_data", "zh", nil},
+		{"标点是单词边界", "(cache) API.", "zh", []string{"API", "cache"}},
+		{"按目标语言筛选", "A pod runs", "zh", nil},
+		{"目标语言不区分大小写", "A pod runs", "JA", []string{"pod"}},
+		{"中文中的英文术语", "使用API和cache时", "zh", []string{"API", "cache"}},
+		{"中文中的中文术语", "连接数据库时出错", "en", []string{"数据库"}},
+		{"日文中的术语", "Kubernetesのpodを使う", "ja", []string{"Kubernetes", "pod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range g.Find(tt.text, tt.lang) {
+				got = append(got, e.Source)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Find(%q, %q) = %q, 期望 %q", tt.text, tt.lang, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	g := testGlossary(t)
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		lang       string
+		want       []string
+	}{
+		{"使用了规定的译法", "Clear the cache via the API.", "通过接口清除缓存。", "zh", nil},
+		{"缺少译法", "Clear the cache via the API.", "通过 API 清除缓存。", "zh", []string{"API"}},
+		{"不区分大小写的译法", "Deploy to Kubernetes.", "部署到 kubernetes。", "zh", nil},
+		{"区分大小写的译法", "Push to GitHub.", "推送到 Github。", "zh", []string{"GitHub"}},
+		{"区分大小写的译法一致", "Push to GitHub.", "推送到 GitHub。", "zh", nil},
+		{"不翻译的术语必须保留原文", "Deploy to Kubernetes.", "部署到 K8s。", "zh", []string{"Kubernetes"}},
+		{"不翻译的术语保留原文", "Deploy to Kubernetes.", "部署到 Kubernetes。", "zh", nil},
+		{"代码中的术语不检查", "Run `cache clear`.\n\n```\nAPI\n```\n", "运行 `cache clear`。\n\n```\nAPI\n```\n", "zh", nil},
+		{"其他目标语言的术语不检查", "A pod.", "一个 pod。", "zh", nil},
+		{"日文", "A pod.", "pod です。", "ja", []string{"pod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range g.Check(tt.source, tt.translated, tt.lang) {
+				got = append(got, v.Entry.Source)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Check() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilGlossary(t *testing.T) {
+	var g *Glossary
+	if g.Len() != 0 || g.Hash() != "" || g.Find("API", "zh") != nil || g.Check("API", "", "zh") != nil {
+		t.Error("nil 术语表的方法应返回零值")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := Format([]Entry{{Source: "API", Target: "接口"}, {Source: "Kubernetes", DoNotTranslate: true}})
+	want := "- \"API\" → \"接口\"\n- \"Kubernetes\" → \"Kubernetes\" (do not translate)"
+	if got != want {
+		t.Errorf("Format() = %q, 期望 %q", got, want)
+	}
+}
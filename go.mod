@@ -2,4 +2,7 @@ module Markdown-translator-go
 
 go 1.24.1
 
-require github.com/BurntSushi/toml v1.5.0
+require (
+	github.com/BurntSushi/toml v1.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
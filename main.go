@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,55 +17,106 @@ import (
 	"Markdown-translator-go/translator"
 )
 
+// 程序退出状态码
+const (
+	exitOK          = 0   // 所有文件处理成功 (或空跑完成)
+	exitFailure     = 1   // 通用错误: 配置错误或有文件处理失败
+	exitOverBudget  = 3   // 达到费用 / token 上限，部分文件未翻译
+	exitInterrupted = 130 // 被 SIGINT/SIGTERM 中断 (沿用 shell 惯例 128+SIGINT)
+)
+
 func main() {
+	// 子命令: cache 用于管理翻译缓存，不执行翻译
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCacheCommand(os.Args[2:]))
+	}
+	// 子命令: tm 用于查看和导出翻译记忆，不执行翻译
+	if len(os.Args) > 1 && os.Args[1] == "tm" {
+		os.Exit(runMemoryCommand(os.Args[2:]))
+	}
+	// 通过 run 返回退出码，确保 run 中的 defer (例如关闭 Translator) 在 os.Exit 之前执行
+	os.Exit(run())
+}
+
+// run 执行完整的翻译流程并返回进程退出码。
+func run() int {
 	// 记录程序开始时间，用于计算总耗时
 	startTime := time.Now()
 	// 配置标准日志库，添加日期、时间和微秒输出，便于追踪和调试
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
 	log.Println("启动 Markdown-translator-go...")
 
-	// 设置信号处理，以便程序可以优雅地退出
-	setupSignalHandler()
+	// 设置信号处理: 收到信号时取消根 Context，以便程序可以优雅地退出
+	ctx, stop := setupSignalHandler()
+	defer stop()
 
 	// --- 步骤 1: 加载和校验配置 ---
 	log.Println("加载配置信息...")
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		// 配置加载失败是致命错误，记录并退出
-		log.Fatalf("配置错误: %v", err) // Fatalf 会打印日志并调用 os.Exit(1)
+		log.Printf("配置错误: %v", err)
+		return exitFailure
 	}
 	// 打印加载的关键配置信息
-	log.Printf("配置加载完成: 源='%s', 目标='%s', 并发=%d, 提供商='%s', 模型='%s', 覆盖=%t, 空跑=%t",
-		cfg.SourceDir, cfg.TargetDir, cfg.Concurrency, cfg.LLMProvider, cfg.LLMModel, cfg.Overwrite, cfg.DryRun)
+	log.Printf("配置加载完成: 源='%s', 目标='%s', 目标语言=%s, 并发=%d, 提供商='%s', 模型='%s', 覆盖=%t, 空跑=%t",
+		cfg.SourceDir, cfg.TargetDir, strings.Join(cfg.TargetLanguages, ","), cfg.Concurrency, cfg.LLMProvider, cfg.LLMModel, cfg.Overwrite, cfg.DryRun)
 	if cfg.DryRun {
 		log.Println("!!! 注意：已启用空跑(Dry Run)模式 !!! 不会实际调用 API 或写入文件。")
 	}
 	log.Printf("使用的 Prompt 文件: %s", cfg.PromptFile)
+	defer func() {
+		if err := cfg.Memory.Close(); err != nil {
+			log.Printf("关闭翻译记忆失败: %v", err)
+		}
+	}()
 
 	// --- 步骤 2: 发现需要翻译的文件 ---
 	log.Println("开始在源目录中查找 Markdown 文件...")
-	filesToProcess, err := discovery.FindMarkdownFiles(cfg.SourceDir)
+	filesToProcess, err := discovery.FindMarkdownFiles(cfg.SourceDir, discovery.Options{
+		Extensions: cfg.Extensions,
+		Include:    cfg.Include,
+		Exclude:    cfg.Exclude,
+		Gitignore:  cfg.UseGitignore,
+		Explain:    cfg.DryRun,
+	})
 	if err != nil {
-		log.Fatalf("查找 Markdown 文件失败: %v", err)
+		log.Printf("查找 Markdown 文件失败: %v", err)
+		return exitFailure
+	}
+
+	// 指定 --files-from 时只处理其中列出的文件 (例如上次运行的失败报告，此时只翻译为失败的目标语言)
+	var fileLangs map[string][]string
+	if cfg.FilesFrom != "" {
+		filesToProcess, fileLangs, err = selectFiles(cfg.FilesFrom, filesToProcess, cfg.TargetLanguages)
+		if err != nil {
+			log.Printf("读取文件列表失败: %v", err)
+			return exitFailure
+		}
+		log.Printf("根据 %s 选择了 %d 个文件。\n", cfg.FilesFrom, len(filesToProcess))
 	}
 
 	// 如果没有找到文件，则无需继续，正常退出
 	if len(filesToProcess) == 0 {
 		log.Println("在源目录中未找到任何 Markdown 文件。程序退出。")
-		os.Exit(0)
+		return exitOK
 	}
 	log.Printf("发现 %d 个 Markdown 文件待处理。\n", len(filesToProcess))
 
 	// --- 步骤 3: 初始化翻译器实例 (使用工厂模式) ---
-	var llmTrans translator.Translator // 使用接口类型，与具体实现解耦
+	var llmTrans translator.Translator     // 使用接口类型，与具体实现解耦
+	var routeTrans []translator.Translator // 路由规则使用的 Translator (与 cfg.Routes 对应)
 	// 仅在非空跑模式下才需要初始化实际的 Translator
 	if !cfg.DryRun {
 		log.Printf("初始化 LLM 翻译器 (提供商: %s)...", cfg.LLMProvider)
 		// 调用工厂函数创建对应提供商的 Translator 实例
-		llmTrans, err = translator.NewTranslator(cfg)
+		// 默认 Translator 与路由规则的 Translator 共用限流器和翻译缓存
+		shared := translator.NewShared()
+		llmTrans, err = translator.NewTranslator(ctx, cfg, shared)
 		if err != nil {
 			// 初始化失败是致命错误
-			log.Fatalf("初始化 LLM 翻译器失败: %v", err)
+			log.Printf("初始化 LLM 翻译器失败: %v", err)
+			return exitFailure
 		}
 		log.Println("LLM 翻译器初始化成功。")
 
@@ -75,6 +129,18 @@ func main() {
 				}
 			}()
 		}
+
+		// 为改变了提供商配置或 temperature 的路由规则创建各自的 Translator
+		routeTrans, err = translator.NewRouteTranslators(ctx, cfg, shared)
+		if err != nil {
+			log.Printf("初始化路由规则的 LLM 翻译器失败: %v", err)
+			return exitFailure
+		}
+		defer func() {
+			if err := translator.CloseAll(routeTrans); err != nil {
+				log.Printf("关闭路由规则的 LLM 翻译器时出错: %v", err)
+			}
+		}()
 	} else {
 		// 在空跑模式下，不需要实际的 Translator 实例
 		log.Println("空跑(Dry Run)模式：跳过 LLM 翻译器初始化。")
@@ -83,45 +149,265 @@ func main() {
 
 	// --- 步骤 4: 并发处理所有文件 ---
 	log.Println("开始并发处理文件...")
-	// 调用处理函数，传入配置、文件列表和 (可能为 nil 的) Translator 实例
-	stats := processor.ProcessFiles(cfg, filesToProcess, llmTrans)
+	// 调用处理函数，传入根 Context、配置、文件列表和 (可能为 nil 的) Translator 实例
+	stats := processor.ProcessFiles(ctx, cfg, filesToProcess, fileLangs, llmTrans, routeTrans)
+	interrupted := ctx.Err() != nil
 
 	// --- 步骤 5: 报告处理结果总结 ---
 	duration := time.Since(startTime) // 计算总耗时
 	fmt.Println("\n--- 翻译任务总结 ---")
 	fmt.Printf("发现文件总数:        %d\n", stats.TotalFiles)
+	if len(cfg.TargetLanguages) > 1 {
+		fmt.Printf("翻译任务总数:        %d (%d 个目标语言)\n", stats.TotalTasks, len(cfg.TargetLanguages))
+	}
 	if cfg.DryRun {
 		// 在空跑模式下，报告模拟处理的文件数
 		fmt.Printf("处理文件数 (空跑):    %d\n", stats.DryRunHits.Load())
 	} else {
 		// 在正常模式下，报告实际处理、跳过和失败的文件数
 		fmt.Printf("成功处理文件数:      %d\n", stats.Processed.Load())
-		fmt.Printf("跳过文件数 (已存在): %d\n", stats.Skipped.Load())
+		if cfg.ChangedOnly {
+			fmt.Printf("跳过文件数 (未变化): %d\n", stats.Skipped.Load())
+		} else {
+			fmt.Printf("跳过文件数 (已存在): %d\n", stats.Skipped.Load())
+		}
+		if cfg.Resume {
+			fmt.Printf("跳过文件数 (已完成): %d\n", stats.Resumed.Load())
+		}
 	}
 	fmt.Printf("失败文件数:          %d\n", stats.Failed.Load())
+	for _, f := range stats.FailedFiles() {
+		name := f.File
+		if len(cfg.TargetLanguages) > 1 {
+			name = "[" + f.Lang + "] " + name
+		}
+		fmt.Printf("  - %s (%s, %s): %s\n", name, f.Stage, f.Class, summarizeError(f.Message))
+	}
+	if n := stats.Warned.Load(); n > 0 {
+		fmt.Printf("校验警告文件数:      %d\n", n)
+	}
+	if interrupted {
+		fmt.Printf("中断文件数:          %d\n", len(stats.InterruptedFiles()))
+		for _, f := range stats.InterruptedFiles() {
+			fmt.Printf("  - %s\n", f)
+		}
+		fmt.Printf("未开始文件数:        %d\n", stats.NotStarted.Load())
+	}
+	overBudget := stats.OverBudgetFiles()
+	if len(overBudget) > 0 {
+		fmt.Printf("预算不足未翻译数:    %d\n", len(overBudget))
+		for _, f := range overBudget {
+			fmt.Printf("  - %s\n", f)
+		}
+	}
+	if fallback := stats.FallbackFiles(); len(fallback) > 0 {
+		fmt.Printf("使用备用提供商文件数: %d\n", len(fallback))
+		for _, f := range fallback {
+			fmt.Printf("  - %s\n", f)
+		}
+	}
+	if len(cfg.TargetLanguages) > 1 {
+		printLanguageStats(cfg, stats)
+	}
+	if !cfg.DryRun {
+		printUsage(cfg, stats)
+	}
 	fmt.Printf("总耗时:              %v\n", duration)
 	fmt.Println("--------------------")
 
 	// --- 步骤 6: 根据结果决定退出状态码 ---
+	// 被信号中断时使用独立的退出码，避免 CI 将未完成的运行误判为成功
+	if interrupted {
+		log.Println("处理已被中断。使用 --resume 重新运行即可继续处理未完成的文件。")
+		return exitInterrupted
+	}
+	// 达到预算上限时使用独立的退出码，提示调整 --max-cost / --max-tokens 后重新运行
+	if len(overBudget) > 0 {
+		log.Printf("已达到预算上限，有 %d 个文件未翻译。提高预算后重新运行即可继续。", len(overBudget))
+		return exitOverBudget
+	}
 	// 如果有任何文件处理失败，以非零状态码退出，表示程序执行中存在问题
 	if stats.Failed.Load() > 0 {
-		log.Printf("处理完成，但有 %d 个文件处理失败。请检查以上日志获取详细信息。", stats.Failed.Load())
-		os.Exit(1) // 使用 1 作为通用的错误退出码
+		log.Printf("处理完成，但有 %d 个文件处理失败。", stats.Failed.Load())
+		if !cfg.DryRun {
+			for _, path := range slices.Compact(processor.FailureReportPaths(cfg)) {
+				log.Printf("失败原因见失败报告 %s，使用 --files-from %s 可以只重新运行这些文件。", path, path)
+			}
+		}
+		return exitFailure
 	}
 
 	// 如果所有文件都处理成功 (或在空跑模式下完成)，则正常退出
 	log.Println("翻译处理流程成功完成。")
-	// 默认退出码为 0，表示成功
+	return exitOK
+}
+
+// selectFiles 返回 files 中在 --files-from 文件列表 (失败报告或每行一个相对路径的文本文件) 里列出的文件，
+// 以及每个选中的文件要翻译的目标语言 (按 targetLangs 的顺序)。文本文件列出的文件翻译为所有目标语言，
+// 失败报告中的文件只翻译为其中记录的目标语言。
+// 列表中未被发现的文件 (已删除或被过滤规则排除) 记录警告后忽略。
+func selectFiles(listPath string, files, targetLangs []string) ([]string, map[string][]string, error) {
+	listed, err := processor.LoadFileList(listPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	wanted := make(map[string]map[string]bool, len(listed)) // 相对路径 -> 要翻译的目标语言
+	for _, t := range listed {
+		if wanted[t.RelativePath] == nil {
+			wanted[t.RelativePath] = make(map[string]bool)
+		}
+		switch {
+		case t.Lang == "":
+			for _, lang := range targetLangs {
+				wanted[t.RelativePath][lang] = true
+			}
+		case slices.Contains(targetLangs, t.Lang):
+			wanted[t.RelativePath][t.Lang] = true
+		default:
+			log.Printf("警告: 文件列表中 %s 的目标语言 %s 不在本次运行的目标语言中，已忽略。\n", t.RelativePath, t.Lang)
+		}
+	}
+	var selected []string
+	langs := make(map[string][]string)
+	for _, f := range files {
+		want, ok := wanted[f]
+		if !ok {
+			continue
+		}
+		delete(wanted, f)
+		for _, lang := range targetLangs {
+			if want[lang] {
+				langs[f] = append(langs[f], lang)
+			}
+		}
+		if len(langs[f]) > 0 {
+			selected = append(selected, f)
+		}
+	}
+	for _, t := range listed {
+		if _, ok := wanted[t.RelativePath]; ok {
+			log.Printf("警告: 文件列表中的 %s 不在源目录中 (或被过滤规则排除)，已忽略。\n", t.RelativePath)
+			delete(wanted, t.RelativePath)
+		}
+	}
+	return selected, langs, nil
+}
+
+// maxErrorSummary 是总结中每个失败文件显示的错误信息的最大字符数，完整信息见失败报告。
+const maxErrorSummary = 120
+
+// summarizeError 返回错误信息的第一行，过长时截断。
+func summarizeError(msg string) string {
+	msg, _, _ = strings.Cut(msg, "\n")
+	if r := []rune(msg); len(r) > maxErrorSummary {
+		msg = string(r[:maxErrorSummary]) + "..."
+	}
+	return msg
+}
+
+// printLanguageStats 按目标语言打印处理结果。
+func printLanguageStats(cfg *config.Config, stats *processor.Stats) {
+	fmt.Println("按目标语言:")
+	for _, lang := range cfg.TargetLanguages {
+		c := stats.Language(lang)
+		if cfg.DryRun {
+			fmt.Printf("  %-8s 空跑 %d, 失败 %d\n", lang, c.DryRunHits.Load(), c.Failed.Load())
+			continue
+		}
+		fmt.Printf("  %-8s 成功 %d, 跳过 %d, 失败 %d", lang, c.Processed.Load(), c.Skipped.Load(), c.Failed.Load())
+		if n := c.Warned.Load(); n > 0 {
+			fmt.Printf(", 校验警告 %d", n)
+		}
+		if n := c.Resumed.Load(); n > 0 {
+			fmt.Printf(", 已完成 %d", n)
+		}
+		if n := c.NotStarted.Load(); n > 0 {
+			fmt.Printf(", 未开始 %d", n)
+		}
+		if n := c.OverBudget.Load(); n > 0 {
+			fmt.Printf(", 预算不足 %d", n)
+		}
+		if u := stats.LanguageUsage(lang); u.Requests > 0 {
+			fmt.Printf(", token %d, 费用 %s", u.Total(), formatCost(cfg, u.Cost))
+		}
+		fmt.Println()
+	}
 }
 
-// setupSignalHandler 设置信号处理器，以便程序可以优雅地退出
-func setupSignalHandler() {
-	c := make(chan os.Signal, 1)
+// topUsageFiles 是总结中列出的费用最高的文件数量，完整的逐文件记录见用量账本。
+const topUsageFiles = 5
+
+// printUsage 打印本次运行的 token 用量、费用以及费用最高的几个文件。
+func printUsage(cfg *config.Config, stats *processor.Stats) {
+	total := stats.Usage()
+	if total.Requests == 0 && total.CacheHits == 0 {
+		return
+	}
+	fmt.Printf("API 请求数:          %d\n", total.Requests)
+	if total.CacheHits > 0 {
+		fmt.Printf("翻译缓存命中:        %d\n", total.CacheHits)
+	}
+	fmt.Printf("Token 用量:          输入 %d (缓存命中 %d), 输出 %d, 合计 %d\n",
+		total.InputTokens, total.CachedInputTokens, total.OutputTokens, total.Total())
+	fmt.Printf("费用:                %s", formatCost(cfg, total.Cost))
+	if total.Unpriced > 0 {
+		fmt.Printf(" (%d 个文件缺少价格，未计入)", total.Unpriced)
+	}
+	fmt.Println()
+	if cfg.MaxTokensTotal > 0 {
+		fmt.Printf("token 预算:          %d / %d\n", total.Total(), cfg.MaxTokensTotal)
+	}
+	if cfg.MaxCost > 0 {
+		fmt.Printf("费用预算:            %s / %s\n", formatCost(cfg, total.Cost), formatCost(cfg, cfg.MaxCost))
+	}
+
+	files := stats.FileUsages()
+	if len(files) > topUsageFiles {
+		files = files[:topUsageFiles]
+	}
+	fmt.Println("用量最高的文件:")
+	for _, f := range files {
+		name := f.File
+		if len(cfg.TargetLanguages) > 1 {
+			name = "[" + f.Lang + "] " + name
+		}
+		fmt.Printf("  - %s: token %d, 费用 %s\n", name, f.Total(), formatCost(cfg, f.Cost))
+	}
+}
+
+// formatCost 按配置的货币单位格式化费用。
+func formatCost(cfg *config.Config, cost float64) string {
+	if cfg.Currency == "" {
+		return fmt.Sprintf("%.4f", cost)
+	}
+	return fmt.Sprintf("%.4f %s", cost, cfg.Currency)
+}
+
+// setupSignalHandler 设置信号处理器，以便程序可以优雅地退出。
+// 第一次收到 SIGINT/SIGTERM 时取消返回的 Context: Worker 停止领取新文件，进行中的文件进入排空期。
+// 再次收到信号时不再等待，立即以 exitInterrupted 退出。
+func setupSignalHandler() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		sig := <-c
-		log.Printf("接收到信号 %v，正在优雅退出...", sig)
-		os.Exit(0)
+		sig, ok := <-c
+		if !ok {
+			return
+		}
+		log.Printf("接收到信号 %v，正在优雅退出 (再次发送信号将强制退出)...", sig)
+		cancel()
+		if sig, ok = <-c; ok {
+			log.Printf("再次接收到信号 %v，强制退出。", sig)
+			os.Exit(exitInterrupted)
+		}
 	}()
+
+	stop := func() {
+		signal.Stop(c)
+		close(c)
+		cancel()
+	}
+	return ctx, stop
 }
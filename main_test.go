@@ -0,0 +1,56 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSelectFiles(t *testing.T) {
+	files := []string{"a.md", "b.md", "c.md"}
+	langs := []string{"zh", "ja"}
+	tests := []struct {
+		name      string
+		list      string
+		wantFiles []string
+		wantLangs map[string][]string
+	}{
+		{
+			name:      "文本文件中的文件翻译为所有目标语言",
+			list:      "c.md\na.md\nmissing.md\n",
+			wantFiles: []string{"a.md", "c.md"},
+			wantLangs: map[string][]string{"a.md": {"zh", "ja"}, "c.md": {"zh", "ja"}},
+		},
+		{
+			name: "失败报告中的文件只翻译为失败的目标语言",
+			list: `{"failed": [
+				{"file": "b.md", "lang": "ja"},
+				{"file": "a.md", "lang": "ja"},
+				{"file": "a.md", "lang": "zh"},
+				{"file": "c.md", "lang": "fr"}
+			]}`,
+			wantFiles: []string{"a.md", "b.md"},
+			wantLangs: map[string][]string{"a.md": {"zh", "ja"}, "b.md": {"ja"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "list")
+			if err := os.WriteFile(path, []byte(tt.list), 0644); err != nil {
+				t.Fatal(err)
+			}
+			gotFiles, gotLangs, err := selectFiles(path, files, langs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(gotFiles, tt.wantFiles) {
+				t.Errorf("selectFiles() 文件 = %q, 期望 %q", gotFiles, tt.wantFiles)
+			}
+			if !maps.EqualFunc(gotLangs, tt.wantLangs, slices.Equal) {
+				t.Errorf("selectFiles() 目标语言 = %q, 期望 %q", gotLangs, tt.wantLangs)
+			}
+		})
+	}
+}
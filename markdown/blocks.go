@@ -0,0 +1,186 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BlockKind 表示 Markdown 块的类型。
+type BlockKind int
+
+const (
+	BlockParagraph  BlockKind = iota // 普通段落 (也包括分隔线、HTML 等未单独识别的块)
+	BlockHeading                     // ATX 标题 (# ...)
+	BlockFencedCode                  // 围栏代码块 (``` 或 ~~~)
+	BlockList                        // 列表 (连续的列表项及其续行)
+	BlockTable                       // 表格 (GFM 管道表格)
+	BlockQuote                       // 引用块 (> ...)
+)
+
+// String 返回块类型的名称，用于日志和校验报告。
+func (k BlockKind) String() string {
+	switch k {
+	case BlockHeading:
+		return "标题"
+	case BlockFencedCode:
+		return "代码块"
+	case BlockList:
+		return "列表"
+	case BlockTable:
+		return "表格"
+	case BlockQuote:
+		return "引用"
+	default:
+		return "段落"
+	}
+}
+
+// Block 是文档中的一个顶层块。块之间由空行分隔，块内部永远不会被切分。
+type Block struct {
+	Kind      BlockKind
+	Text      string // 块的原始文本 (不含首尾空行)
+	Level     int    // 标题级别 (仅 BlockHeading 有效)
+	StartLine int    // 块在文档中的起始行号 (从 0 开始)
+	EndLine   int    // 块的结束行号 (不包含)
+}
+
+var (
+	headingRegex       = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]|$)`)
+	fenceRegex         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	listItemRegex      = regexp.MustCompile(`^ {0,3}(?:[-*+]|\d{1,9}[.)])(?:[ \t]|$)`)
+	quoteRegex         = regexp.MustCompile(`^ {0,3}>`)
+	tableDelimRegex    = regexp.MustCompile(`^ {0,3}\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	indentedRegex      = regexp.MustCompile(`^(?: {2,}|\t)`)
+	thematicBreakRegex = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+)
+
+// ParseBlocks 将 Markdown 文档切分为顶层块。
+// 这是一个面向翻译场景的轻量级解析器: 它只识别足以保证结构不被破坏的块边界，
+// 围栏代码块、列表和表格始终作为一个整体，不会被拆开。
+func ParseBlocks(content string) []Block {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var blocks []Block
+
+	add := func(kind BlockKind, start, end, level int) {
+		blocks = append(blocks, Block{
+			Kind:      kind,
+			Text:      strings.Join(lines[start:end], "\n"),
+			Level:     level,
+			StartLine: start,
+			EndLine:   end,
+		})
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRegex.MatchString(line):
+			// 围栏代码块: 找到相同字符且长度不小于起始围栏的结束围栏，未闭合时一直延续到文档末尾
+			fence := fenceRegex.FindStringSubmatch(line)[1]
+			end := i + 1
+			for ; end < len(lines); end++ {
+				trimmed := strings.TrimSpace(lines[end])
+				if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+					end++
+					break
+				}
+			}
+			add(BlockFencedCode, i, min(end, len(lines)), 0)
+			i = end
+
+		case headingRegex.MatchString(line):
+			add(BlockHeading, i, i+1, len(headingRegex.FindStringSubmatch(line)[1]))
+			i++
+
+		case thematicBreakRegex.MatchString(line):
+			add(BlockParagraph, i, i+1, 0)
+			i++
+
+		case listItemRegex.MatchString(line):
+			end := scanList(lines, i)
+			add(BlockList, i, end, 0)
+			i = end
+
+		case quoteRegex.MatchString(line):
+			end := scanUntilBlank(lines, i)
+			add(BlockQuote, i, end, 0)
+			i = end
+
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableDelimRegex.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "|"):
+			end := i + 2
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && strings.Contains(lines[end], "|") {
+				end++
+			}
+			add(BlockTable, i, end, 0)
+			i = end
+
+		default:
+			// 段落: 直到空行，或遇到可以打断段落的标题 / 围栏代码块
+			end := i + 1
+			for end < len(lines) {
+				next := lines[end]
+				if strings.TrimSpace(next) == "" || headingRegex.MatchString(next) || fenceRegex.MatchString(next) {
+					break
+				}
+				end++
+			}
+			add(BlockParagraph, i, end, 0)
+			i = end
+		}
+	}
+	return blocks
+}
+
+// scanList 从 start 开始扫描一个列表，返回列表结束的行号 (不包含)。
+// 列表项之间的空行不会结束列表: 只要下一个非空行仍是列表项或缩进的续行，列表就继续。
+// 列表项内部的围栏代码块会被整体跳过。
+func scanList(lines []string, start int) int {
+	end := start + 1
+	for end < len(lines) {
+		line := lines[end]
+		if strings.TrimSpace(line) == "" {
+			next := end + 1
+			for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+				next++
+			}
+			if next < len(lines) && (listItemRegex.MatchString(lines[next]) || indentedRegex.MatchString(lines[next])) {
+				end = next
+				continue
+			}
+			break
+		}
+		if headingRegex.MatchString(line) {
+			break
+		}
+		if fenceRegex.MatchString(line) {
+			if !indentedRegex.MatchString(line) {
+				break
+			}
+			// 缩进的围栏代码块属于当前列表项
+			fence := fenceRegex.FindStringSubmatch(strings.TrimLeft(line, " \t"))[1]
+			end++
+			for end < len(lines) {
+				trimmed := strings.TrimSpace(lines[end])
+				end++
+				if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+					break
+				}
+			}
+			continue
+		}
+		end++
+	}
+	return end
+}
+
+// scanUntilBlank 返回从 start 开始到下一个空行 (不包含) 的行号。
+func scanUntilBlank(lines []string, start int) int {
+	end := start + 1
+	for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+		end++
+	}
+	return end
+}
@@ -0,0 +1,86 @@
+package markdown
+
+import "strings"
+
+// Chunks 是 Chunk 切分文档的结果。
+type Chunks struct {
+	Texts []string // 各片段的原文 (不含首尾的空行)
+	Gaps  []string // 原文中片段之外的内容，比 Texts 多一项: Gaps[i] 位于 Texts[i] 之前 (第一项为文档开头的空行)，最后一项为文档末尾的空行及结尾的换行
+}
+
+// Join 将与 Texts 一一对应的各片段译文按原文中的间隔拼接: 片段之间的空行、文档首尾的空行和结尾的换行原样保留。
+func (c Chunks) Join(texts []string) string {
+	var b strings.Builder
+	for i, text := range texts {
+		b.WriteString(c.Gaps[i])
+		b.WriteString(text)
+	}
+	b.WriteString(c.Gaps[len(texts)])
+	return b.String()
+}
+
+// Chunk 按块边界将文档切分为若干片段，每个片段的大小 (由 size 函数估算) 不超过 maxSize。
+// 片段只会在块之间切开，因此代码块、列表和表格永远不会被拆分；单个超过 maxSize 的块会独占一个片段。
+// 为了让每个片段在语义上尽量完整，当前片段已达到 maxSize 的一半时，遇到标题会优先开始新片段。
+// 片段内部保留原文中块之间的空行，片段之间及文档首尾的空行记录在 Gaps 中 (CRLF 换行统一为 LF)。
+// maxSize <= 0 或文档足够小时只返回一个片段; 文档只有空白时该片段为整个文档。
+func Chunk(content string, maxSize int, size func(string) int) Chunks {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	first, last := 0, len(lines)
+	for first < last && strings.TrimSpace(lines[first]) == "" {
+		first++
+	}
+	for last > first && strings.TrimSpace(lines[last-1]) == "" {
+		last--
+	}
+	if first == last {
+		return Chunks{Texts: []string{content}, Gaps: []string{"", ""}}
+	}
+
+	ranges := [][2]int{{first, last}} // 各片段的起止行号 (不包含结束行)
+	if maxSize > 0 && size(content) > maxSize {
+		if blocks := ParseBlocks(content); len(blocks) > 1 {
+			ranges = chunkRanges(blocks, maxSize, size)
+		}
+	}
+
+	// offsets[i] 为第 i 行的起始字节位置; 片段到其最后一行的换行符之前结束
+	offsets := make([]int, 0, len(lines)+1)
+	pos := 0
+	for _, line := range lines {
+		offsets = append(offsets, pos)
+		pos += len(line) + 1
+	}
+	offsets = append(offsets, pos)
+
+	var c Chunks
+	prev := 0
+	for _, r := range ranges {
+		start, end := offsets[r[0]], offsets[r[1]]-1
+		c.Gaps = append(c.Gaps, content[prev:start])
+		c.Texts = append(c.Texts, content[start:end])
+		prev = end
+	}
+	c.Gaps = append(c.Gaps, content[prev:])
+	return c
+}
+
+// chunkRanges 将块分组为片段，返回各片段的起止行号 (不包含结束行)。
+func chunkRanges(blocks []Block, maxSize int, size func(string) int) [][2]int {
+	var ranges [][2]int
+	start, current := blocks[0].StartLine, 0
+	for i, b := range blocks {
+		blockSize := size(b.Text)
+		if i > 0 {
+			overflow := current+blockSize > maxSize
+			headingBreak := b.Kind == BlockHeading && current >= maxSize/2
+			if overflow || headingBreak {
+				ranges = append(ranges, [2]int{start, blocks[i-1].EndLine})
+				start, current = b.StartLine, 0
+			}
+		}
+		current += blockSize
+	}
+	return append(ranges, [2]int{start, blocks[len(blocks)-1].EndLine})
+}
@@ -0,0 +1,126 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxSize  int
+		want     []string
+		wantGaps []string
+	}{
+		{
+			name:     "不切分",
+			content:  "# A\n\naaaa\n",
+			maxSize:  0,
+			want:     []string{"# A\n\naaaa"},
+			wantGaps: []string{"", "\n"},
+		},
+		{
+			name:     "文档足够小",
+			content:  "# A\n\naaaa\n",
+			maxSize:  100,
+			want:     []string{"# A\n\naaaa"},
+			wantGaps: []string{"", "\n"},
+		},
+		{
+			name:     "超过上限时在块之间切开",
+			content:  "aaaa\n\nbbbb\n\ncccc\n",
+			maxSize:  9,
+			want:     []string{"aaaa\n\nbbbb", "cccc"},
+			wantGaps: []string{"", "\n\n", "\n"},
+		},
+		{
+			name:     "达到上限一半后遇到标题开始新片段",
+			content:  "# A\n\naaaa\n\n# B\n\nbbbb\n",
+			maxSize:  12,
+			want:     []string{"# A\n\naaaa", "# B\n\nbbbb"},
+			wantGaps: []string{"", "\n\n", "\n"},
+		},
+		{
+			name:     "未达到上限一半时标题不切分",
+			content:  "# A\n\n# B\n\nbb\n\ncccccccccccccc\n",
+			maxSize:  16,
+			want:     []string{"# A\n\n# B\n\nbb", "cccccccccccccc"},
+			wantGaps: []string{"", "\n\n", "\n"},
+		},
+		{
+			name:     "超过上限的代码块独占一个片段且不被拆分",
+			content:  "p1\n\n```\nxxxxxxxxxxxxxxxxxxxx\n\nyyyy\n```\n\np2\n",
+			maxSize:  10,
+			want:     []string{"p1", "```\nxxxxxxxxxxxxxxxxxxxx\n\nyyyy\n```", "p2"},
+			wantGaps: []string{"", "\n\n", "\n\n", "\n"},
+		},
+		{
+			name:     "列表不被拆分",
+			content:  "- a\n- b\n- c\n\npara\n",
+			maxSize:  8,
+			want:     []string{"- a\n- b\n- c", "para"},
+			wantGaps: []string{"", "\n\n", "\n"},
+		},
+		{
+			name:     "只有一个块",
+			content:  "aaaaaaaaaaaaaaaa\nbbbbbbbbbbbbbbbb\n",
+			maxSize:  4,
+			want:     []string{"aaaaaaaaaaaaaaaa\nbbbbbbbbbbbbbbbb"},
+			wantGaps: []string{"", "\n"},
+		},
+		{
+			name:     "保留片段之间多个空行及文档首尾的空行",
+			content:  "\n\naaaa\n\n\n\nbbbb\n \n\n",
+			maxSize:  5,
+			want:     []string{"aaaa", "bbbb"},
+			wantGaps: []string{"\n\n", "\n\n\n\n", "\n \n\n"},
+		},
+		{
+			name:     "没有结尾的换行",
+			content:  "aaaa\n\nbbbb",
+			maxSize:  5,
+			want:     []string{"aaaa", "bbbb"},
+			wantGaps: []string{"", "\n\n", ""},
+		},
+		{
+			name:     "CRLF 换行",
+			content:  "aaaa\r\n\r\nbbbb\r\n",
+			maxSize:  5,
+			want:     []string{"aaaa", "bbbb"},
+			wantGaps: []string{"", "\n\n", "\n"},
+		},
+		{
+			name:     "只有空白",
+			content:  "\n \n",
+			maxSize:  5,
+			want:     []string{"\n \n"},
+			wantGaps: []string{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Chunk(tt.content, tt.maxSize, utf8.RuneCountInString)
+			if !slices.Equal(got.Texts, tt.want) {
+				t.Errorf("Chunk().Texts = %q, 期望 %q", got.Texts, tt.want)
+			}
+			if !slices.Equal(got.Gaps, tt.wantGaps) {
+				t.Errorf("Chunk().Gaps = %q, 期望 %q", got.Gaps, tt.wantGaps)
+			}
+			// 用原文拼接应还原整个文档
+			if joined, want := got.Join(got.Texts), strings.ReplaceAll(tt.content, "\r\n", "\n"); joined != want {
+				t.Errorf("Join(Texts) = %q, 期望 %q", joined, want)
+			}
+		})
+	}
+}
+
+func TestChunksJoin(t *testing.T) {
+	c := Chunk("\n# A\n\naaaa\n\n\n# B\n\nbbbb\n", 8, utf8.RuneCountInString)
+	got := c.Join([]string{"# 甲\n\n甲甲", "# 乙\n\n乙乙"})
+	if want := "\n# 甲\n\n甲甲\n\n\n# 乙\n\n乙乙\n"; got != want {
+		t.Errorf("Join() = %q, 期望 %q", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package markdown
+
+import "strings"
+
+// FencedBlocks 返回文档中的所有围栏代码块 (包括列表中缩进的代码块)，
+// 以及将这些代码块整体移除 (替换为空行) 后的剩余文本，便于在不受代码干扰的情况下分析其余结构。
+func FencedBlocks(content string) (rest string, blocks []string) {
+	rest = replaceFencedBlocks(content, func(block string) string {
+		blocks = append(blocks, block)
+		return ""
+	})
+	return rest, blocks
+}
+
+// CodeSpans 返回文本中所有的行内代码 (包含两侧的反引号)。
+func CodeSpans(text string) []string {
+	var spans []string
+	replaceCodeSpans(text, func(span string) string {
+		spans = append(spans, span)
+		return span
+	})
+	return spans
+}
+
+// replaceFencedBlocks 将每个围栏代码块替换为 fn 的返回值，保留首行缩进。
+// 传给 fn 的代码块文本不含首行缩进，未闭合的代码块延续到文档末尾。
+func replaceFencedBlocks(content string, fn func(block string) string) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " \t")
+		m := fenceRegex.FindStringSubmatch(trimmed)
+		if m == nil {
+			out = append(out, lines[i])
+			continue
+		}
+		fence := m[1]
+		end := len(lines) - 1 // 未闭合的代码块延续到文档末尾
+		for j := i + 1; j < len(lines); j++ {
+			t := strings.TrimSpace(lines[j])
+			if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				end = j
+				break
+			}
+		}
+		indent := lines[i][:len(lines[i])-len(trimmed)]
+		block := strings.Join(append([]string{trimmed}, lines[i+1:end+1]...), "\n")
+		out = append(out, indent+fn(block))
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// replaceCodeSpans 将每个行内代码 (由相同长度的反引号串包围) 替换为 fn 的返回值。
+func replaceCodeSpans(text string, fn func(span string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+		run := backtickRun(text, i)
+		// 查找长度完全相同的结束反引号串 (行内代码不会跨越段落，遇到空行即停止)
+		closeAt := -1
+		for j := i + run; j < len(text); {
+			if strings.HasPrefix(text[j:], "\n\n") {
+				break
+			}
+			if text[j] != '`' {
+				j++
+				continue
+			}
+			n := backtickRun(text, j)
+			if n == run {
+				closeAt = j
+				break
+			}
+			j += n
+		}
+		if closeAt < 0 {
+			// 没有匹配的结束符，按普通文本处理
+			b.WriteString(text[i : i+run])
+			i += run
+			continue
+		}
+		b.WriteString(fn(text[i : closeAt+run]))
+		i = closeAt + run
+	}
+	return b.String()
+}
+
+// backtickRun 返回从 i 开始的连续反引号数量。
+func backtickRun(text string, i int) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+	return n
+}
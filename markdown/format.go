@@ -0,0 +1,181 @@
+package markdown
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Format 是 Markdown 家族中的文档格式，决定翻译前需要额外保护的语法。
+type Format int
+
+const (
+	FormatMarkdown Format = iota // 普通 Markdown (.md、.markdown、.mdown 等)
+	FormatMDX                    // MDX (.mdx): import/export 语句、JSX 标签和 {表达式}
+	FormatQuarto                 // Quarto (.qmd): YAML 头、代码块选项、::: 块属性和 {属性}
+)
+
+// String 返回格式名称，用于日志。
+func (f Format) String() string {
+	switch f {
+	case FormatMDX:
+		return "MDX"
+	case FormatQuarto:
+		return "Quarto"
+	default:
+		return "Markdown"
+	}
+}
+
+// FormatOf 根据文件扩展名 (不区分大小写) 判断文档格式，未知的扩展名按普通 Markdown 处理。
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mdx":
+		return FormatMDX
+	case ".qmd":
+		return FormatQuarto
+	default:
+		return FormatMarkdown
+	}
+}
+
+var (
+	// MDX 的 ESM 语句: 顶格的 import / export，一直延续到下一个空行
+	esmRegex = regexp.MustCompile(`^(?:import|export)\b`)
+	// YAML 头 (front matter) 的分隔行
+	yamlDelimRegex = regexp.MustCompile(`^---[ \t]*$`)
+	// Quarto / Pandoc 的 ::: 块分隔行，例如 "::: {.callout-note}"、":::: columns"
+	divFenceRegex = regexp.MustCompile(`(?m)^ {0,3}:{3,}.*$`)
+	// Pandoc 属性，例如标题后的 {#sec-intro}、[文本]{.smallcaps}、图片后的 {width=50%}
+	attributeRegex = regexp.MustCompile(`\{[#.][^{}\n]*\}|\{[A-Za-z_][\w-]*=[^{}\n]*\}`)
+)
+
+// protectFormat 保护特定格式的语法。在围栏代码块和行内代码被替换为占位符之后调用，
+// 因此 Quarto 代码块的 {r, echo=FALSE} 头和 #| 选项行已随代码块整体受到保护。
+func (p *Protection) protectFormat(text string, format Format) string {
+	switch format {
+	case FormatMDX:
+		text = p.protectESM(text)
+		text = replaceJSX(text, p.add)
+	case FormatQuarto:
+		text = p.protectYAMLHeader(text)
+		text = divFenceRegex.ReplaceAllStringFunc(text, p.add)
+		text = attributeRegex.ReplaceAllStringFunc(text, p.add)
+	}
+	return text
+}
+
+// protectESM 将 MDX 的 import / export 语句 (到下一个空行为止) 整体替换为占位符。
+func (p *Protection) protectESM(text string) string {
+	lines := strings.Split(text, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if !esmRegex.MatchString(lines[i]) {
+			out = append(out, lines[i])
+			continue
+		}
+		end := i
+		for end+1 < len(lines) && strings.TrimSpace(lines[end+1]) != "" {
+			end++
+		}
+		out = append(out, p.add(strings.Join(lines[i:end+1], "\n")))
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// protectYAMLHeader 将文档开头 --- 与 --- 之间的 YAML 头 (包括分隔行) 整体替换为占位符。
+func (p *Protection) protectYAMLHeader(text string) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 0 || !yamlDelimRegex.MatchString(lines[0]) {
+		return text
+	}
+	for end := 1; end < len(lines); end++ {
+		if yamlDelimRegex.MatchString(lines[end]) || lines[end] == "..." {
+			header := p.add(strings.Join(lines[:end+1], "\n"))
+			return strings.Join(append([]string{header}, lines[end+1:]...), "\n")
+		}
+	}
+	return text
+}
+
+// replaceJSX 将 MDX 中的 JSX 标签 (连同属性) 和 {表达式} 分别替换为 fn 的返回值，标签之间的文本保持不变。
+// 标签和表达式可以跨行，但不会跨越空行; 无法解析的 "<" 和 "{" 按普通文本处理。
+func replaceJSX(text string, fn func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		end := -1
+		switch text[i] {
+		case '<':
+			end = jsxTagEnd(text, i)
+		case '{':
+			end = jsxExpressionEnd(text, i)
+		}
+		if end < 0 {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+		b.WriteString(fn(text[i:end]))
+		i = end
+	}
+	return b.String()
+}
+
+// jsxTagEnd 返回从 start ("<") 开始的 JSX 标签的结束位置 (">" 之后)，不是标签时返回 -1。
+// 支持 <Name ...>、</Name>、<Name ... />、片段 <> 和 </>，属性值中的引号和 {} 内的 ">" 不会结束标签。
+func jsxTagEnd(text string, start int) int {
+	i := start + 1
+	if i < len(text) && text[i] == '/' {
+		i++
+	}
+	if i < len(text) && text[i] == '>' {
+		return i + 1
+	}
+	if i >= len(text) || !isLetter(text[i]) {
+		return -1
+	}
+	return scanJSX(text, i, 0)
+}
+
+// jsxExpressionEnd 返回从 start ("{") 开始的 JSX 表达式的结束位置 (匹配的 "}" 之后)，没有闭合时返回 -1。
+func jsxExpressionEnd(text string, start int) int {
+	return scanJSX(text, start+1, 1)
+}
+
+// scanJSX 从 i 开始扫描，跳过引号字符串，在 {} 嵌套深度回到 0 时遇到 ">" (depth 初始为 0 时)
+// 或匹配的 "}" (depth 初始为 1 时) 结束。遇到空行或文本末尾返回 -1。
+func scanJSX(text string, i, depth int) int {
+	expression := depth > 0
+	var quote byte
+	for ; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case strings.HasPrefix(text[i:], "\n\n"):
+			return -1
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if expression && depth == 0 {
+				return i + 1
+			}
+		case c == '>' && depth == 0 && !expression:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// isLetter 报告 c 是否为 ASCII 字母。
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
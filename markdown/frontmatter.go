@@ -0,0 +1,626 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter 是文档开头的 YAML (--- ... ---) 或 TOML (+++ ... +++) front matter。
+// 只有配置的顶层键的字符串值会被提取出来翻译，其余内容 (键名、注释、缩进、其他键的值) 在 Render 时保持逐字节不变。
+//
+// 支持的值的写法: 单行的纯量 (无引号、单引号、双引号)、单行的 [a, "b"] 数组、YAML 的 "- item" 列表
+// 和 | / > 块标量。其他写法 (多行纯量、嵌套的映射等) 的键不会被翻译，记录在 Unsupported 中。
+type FrontMatter struct {
+	Format      string   // "yaml" 或 "toml"
+	Unsupported []string // 配置了但值的写法不受支持、因而不会翻译的键
+
+	text   string // 原始 front matter (含分隔行和结尾的换行)
+	lines  []string
+	fields []frontMatterField
+}
+
+// frontMatterField 是一个需要翻译的顶层键及其值的位置。
+type frontMatterField struct {
+	key   string
+	spans []valueSpan
+}
+
+// valueSpan 是 front matter 中一个字符串值的位置和写法。
+type valueSpan struct {
+	line       int    // 所在行 (相对 lines)
+	start, end int    // 行内字节范围 (含引号); 块标量时不使用
+	lastLine   int    // 块标量的最后一个非空内容行
+	indent     string // 块标量内容的缩进
+	style      byte   // 'p' 无引号, '\'' 单引号, '"' 双引号, '|' 块标量
+	folded     bool   // 块标量为 > (折叠) 风格
+	flow       bool   // 位于 [...] 数组中
+	value      string // 解析后的值
+}
+
+var (
+	// YAML 顶层键，例如 "title: ..."、"'my key': ..."
+	yamlKeyRegex = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"\-?:,\[\]{}][^:#]*?|-[^\s:#][^:#]*?)[ \t]*:(?:[ \t]+|$)`)
+	// YAML 块列表项，例如 "  - item"
+	yamlItemRegex = regexp.MustCompile(`^([ \t]*)-[ \t]+`)
+	// YAML 块标量的头，例如 "|"、">-"、"|2+"，后面可以有注释
+	yamlBlockHeaderRegex = regexp.MustCompile(`^[|>][1-9+-]{0,2}[ \t]*(?:#.*)?$`)
+	// 会被 YAML 解析为非字符串 (布尔、空值、数字、日期) 的无引号值; 包括 YAML 1.1 的 yes/no/on/off，写入时为这些值加上引号
+	yamlNonStringRegex = regexp.MustCompile(`^(?i:true|false|yes|no|on|off|null|~|[-+]?(?:\.inf|\.nan)|[-+]?[0-9][0-9_.:eE+\-]*|0x[0-9a-f_]+|0o[0-7_]+)$`)
+	// TOML 顶层键，例如 "title = ..."
+	tomlKeyRegex = regexp.MustCompile(`^[ \t]*("[^"]*"|'[^']*'|[A-Za-z0-9_-]+)[ \t]*=[ \t]*`)
+)
+
+// SplitFrontMatter 将文档开头的 front matter 与正文分开，并定位 keys 中各顶层键的字符串值。
+// 文档没有 front matter 时返回 nil 和原文。正文从结束分隔行的下一行开始。
+func SplitFrontMatter(content string, keys []string) (*FrontMatter, string) {
+	lines := strings.Split(content, "\n")
+	var format, closing string
+	switch strings.TrimRight(lines[0], " \t\r") {
+	case "---":
+		format, closing = "yaml", "---"
+	case "+++":
+		format, closing = "toml", "+++"
+	default:
+		return nil, content
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		l := strings.TrimRight(lines[i], " \t\r")
+		if l == closing || (format == "yaml" && l == "...") {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, content
+	}
+
+	text := strings.Join(lines[:end+1], "\n")
+	if end+1 < len(lines) {
+		text += "\n"
+	}
+	fm := &FrontMatter{Format: format, text: text, lines: lines[:end+1]}
+	if format == "yaml" {
+		fm.parseYAML(keys)
+	} else {
+		fm.parseTOML(keys)
+	}
+	return fm, content[len(text):]
+}
+
+// Text 返回原始的 front matter (含分隔行)。
+func (fm *FrontMatter) Text() string {
+	return fm.text
+}
+
+// Values 按出现顺序返回所有需要翻译的字符串值。
+func (fm *FrontMatter) Values() []string {
+	var values []string
+	for _, f := range fm.fields {
+		for _, s := range f.spans {
+			values = append(values, s.value)
+		}
+	}
+	return values
+}
+
+// Keys 返回值需要翻译的键。
+func (fm *FrontMatter) Keys() []string {
+	keys := make([]string, len(fm.fields))
+	for i, f := range fm.fields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// Render 用 values (与 Values 的顺序对应) 替换各个值，保持原有的引号风格 (值无法用原风格表示时改用双引号)，
+// 返回新的 front matter。结果会被重新解析，确认仍然有效且各键的值与 values 一致。
+func (fm *FrontMatter) Render(values []string) (string, error) {
+	if len(values) != len(fm.Values()) {
+		return "", fmt.Errorf("front matter 的值数量不一致: 需要 %d 个，得到 %d 个", len(fm.Values()), len(values))
+	}
+	lines := slices.Clone(fm.lines)
+	expected := slices.Clone(values) // 重新解析后各值应有的内容 (块标量去掉了行尾的空行和只含空白的行)
+	// 从后往前替换，前面的位置不受影响
+	var spans []valueSpan
+	for _, f := range fm.fields {
+		spans = append(spans, f.spans...)
+	}
+	for i := len(spans) - 1; i >= 0; i-- {
+		s, v := spans[i], values[i]
+		if s.style == '|' {
+			block := strings.Split(strings.TrimRight(v, "\n"), "\n")
+			for j, l := range block {
+				if strings.TrimSpace(l) == "" {
+					block[j] = ""
+				}
+			}
+			expected[i] = strings.Join(block, "\n")
+			for j, l := range block {
+				if l != "" {
+					block[j] = s.indent + l
+				}
+			}
+			lines = slices.Concat(lines[:s.line+1], block, lines[s.lastLine+1:])
+			continue
+		}
+		line := lines[s.line]
+		lines[s.line] = line[:s.start] + fm.formatScalar(v, s) + line[s.end:]
+	}
+
+	text := strings.Join(lines, "\n")
+	if strings.HasSuffix(fm.text, "\n") {
+		text += "\n"
+	}
+	if err := fm.verify(text, expected); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// verify 重新解析渲染后的 front matter，确认各键的值仍在原来的位置，
+// 并用 YAML / TOML 解析器解码，确认其有效且各键的值与 values 一致 (> 块标量按折叠规则解码，只确认其为字符串)。
+func (fm *FrontMatter) verify(text string, values []string) error {
+	rendered, _ := SplitFrontMatter(text+"\n", fm.Keys())
+	if rendered == nil || len(rendered.Unsupported) > 0 || !slices.Equal(rendered.Keys(), fm.Keys()) {
+		return fmt.Errorf("翻译后的 %s front matter 无法解析", fm.Format)
+	}
+	if !slices.Equal(rendered.Values(), values) {
+		return fmt.Errorf("翻译后的 %s front matter 的值与译文不一致", fm.Format)
+	}
+
+	inner := strings.Join(rendered.lines[1:len(rendered.lines)-1], "\n")
+	decoded := map[string]any{}
+	var err error
+	if fm.Format == "toml" {
+		_, err = toml.Decode(inner, &decoded)
+	} else {
+		err = yaml.Unmarshal([]byte(inner), &decoded)
+	}
+	if err != nil {
+		return fmt.Errorf("翻译后的 %s front matter 无法解析: %w", fm.Format, err)
+	}
+	i := 0
+	for _, f := range rendered.fields {
+		got := stringValues(decoded[f.key])
+		if len(got) != len(f.spans) {
+			return fmt.Errorf("翻译后的 %s front matter 中 %s 的值与译文不一致", fm.Format, f.key)
+		}
+		for j, s := range f.spans {
+			want := values[i]
+			i++
+			if s.style == '|' {
+				if s.folded {
+					continue
+				}
+				got[j] = strings.TrimRight(got[j], "\n")
+			}
+			if got[j] != want {
+				return fmt.Errorf("翻译后的 %s front matter 中 %s 的值与译文不一致", fm.Format, f.key)
+			}
+		}
+	}
+	return nil
+}
+
+// stringValues 返回解码后的值中的字符串: 字符串本身，或数组中的字符串元素 (跳过其他类型的元素)。
+func stringValues(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// formatScalar 按原有风格格式化一个值。
+func (fm *FrontMatter) formatScalar(v string, s valueSpan) string {
+	if fm.Format == "toml" {
+		if s.style == '\'' && !strings.ContainsAny(v, "'\n\r") {
+			return "'" + v + "'"
+		}
+		return tomlQuote(v)
+	}
+	switch {
+	case s.style == 'p' && yamlPlainSafe(v, s.flow):
+		return v
+	case s.style == '\'' && !strings.ContainsAny(v, "\n\r"):
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		return yamlQuote(v)
+	}
+}
+
+// parseYAML 定位 keys 中各顶层键的字符串值。
+func (fm *FrontMatter) parseYAML(keys []string) {
+	body := fm.lines[1 : len(fm.lines)-1]
+	for i := 0; i < len(body); i++ {
+		line := strings.TrimSuffix(body[i], "\r")
+		m := yamlKeyRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := unquoteKey(m[1])
+		// 属于该键的后续行: 缩进的行、空行、注释以及顶格的 "- " 列表项
+		next := i + 1
+		for next < len(body) {
+			l := strings.TrimSuffix(body[next], "\r")
+			if l != "" && l[0] != ' ' && l[0] != '\t' && l[0] != '#' && !yamlItemRegex.MatchString(l) {
+				break
+			}
+			next++
+		}
+		if slices.Contains(keys, key) {
+			spans, ok := yamlSpans(body, i, len(m[0]), next)
+			switch {
+			case !ok:
+				fm.Unsupported = append(fm.Unsupported, key)
+			case len(spans) > 0:
+				for j := range spans {
+					spans[j].line++ // 相对 lines (第 0 行是开始分隔行)
+					spans[j].lastLine++
+				}
+				fm.fields = append(fm.fields, frontMatterField{key: key, spans: spans})
+			}
+		}
+		i = next - 1
+	}
+}
+
+// yamlSpans 解析第 i 行 (值从 off 开始) 的键的值，其后续行为 body[i+1:next]。
+// 非字符串的值返回空列表; 写法不受支持时 ok 为 false。
+func yamlSpans(body []string, i, off, next int) (spans []valueSpan, ok bool) {
+	line := strings.TrimSuffix(body[i], "\r")
+	rest := strings.TrimSpace(stripComment(line[off:]))
+	switch {
+	case rest == "":
+		// 块列表: 后续的每个非空、非注释行都必须是单行纯量的列表项
+		for j := i + 1; j < next; j++ {
+			l := strings.TrimSuffix(body[j], "\r")
+			if t := strings.TrimSpace(l); t == "" || strings.HasPrefix(t, "#") {
+				continue
+			}
+			m := yamlItemRegex.FindString(l)
+			if m == "" {
+				return nil, false
+			}
+			s, end, ok := scanYAMLScalar(l, len(m), false)
+			if !ok || strings.TrimSpace(stripComment(l[end:])) != "" {
+				return nil, false
+			}
+			if s.style != 'p' || yamlPlainString(s.value) {
+				s.line = j
+				spans = append(spans, s)
+			}
+		}
+		return spans, true
+	case rest[0] == '[':
+		// 单行的流式数组
+		pos := off + strings.Index(line[off:], "[") + 1
+		for {
+			for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+				pos++
+			}
+			if pos < len(line) && line[pos] == ']' {
+				break
+			}
+			s, end, ok := scanYAMLScalar(line, pos, true)
+			if !ok {
+				return nil, false
+			}
+			if s.style != 'p' || yamlPlainString(s.value) {
+				s.line = i
+				spans = append(spans, s)
+			}
+			pos = end
+			for pos < len(line) && (line[pos] == ' ' || line[pos] == '\t') {
+				pos++
+			}
+			if pos < len(line) && line[pos] == ',' {
+				pos++
+				continue
+			}
+			if pos < len(line) && line[pos] == ']' {
+				break
+			}
+			return nil, false
+		}
+		if strings.TrimSpace(stripComment(line[pos+1:])) != "" || hasContent(body[i+1:next]) {
+			return nil, false
+		}
+		return spans, true
+	case yamlBlockHeaderRegex.MatchString(rest):
+		return yamlBlockSpan(body, i, next, rest[0] == '>')
+	case strings.ContainsRune("{&*!%@`", rune(rest[0])):
+		return nil, false
+	}
+	// 单行纯量，不能延续到下一行
+	s, end, ok := scanYAMLScalar(line, off, false)
+	if !ok || strings.TrimSpace(stripComment(line[end:])) != "" || hasContent(body[i+1:next]) {
+		return nil, false
+	}
+	if s.style == 'p' && !yamlPlainString(s.value) {
+		return nil, true
+	}
+	s.line = i
+	return []valueSpan{s}, true
+}
+
+// yamlBlockSpan 解析第 i 行的块标量 (| 或 >，后者 folded 为 true) 的内容行 body[i+1:next]。
+func yamlBlockSpan(body []string, i, next int, folded bool) ([]valueSpan, bool) {
+	first, last := -1, -1
+	for j := i + 1; j < next; j++ {
+		if strings.TrimSpace(body[j]) != "" {
+			if first < 0 {
+				first = j
+			}
+			last = j
+		}
+	}
+	if first < 0 {
+		return nil, true
+	}
+	firstLine := strings.TrimSuffix(body[first], "\r")
+	indent := firstLine[:len(firstLine)-len(strings.TrimLeft(firstLine, " \t"))]
+	if indent == "" || strings.Contains(body[i], "\r") {
+		return nil, false
+	}
+	var text []string
+	for j := i + 1; j <= last; j++ {
+		l, ok := strings.CutPrefix(body[j], indent)
+		if !ok && strings.TrimSpace(body[j]) != "" {
+			return nil, false
+		}
+		text = append(text, l)
+	}
+	return []valueSpan{{line: i, lastLine: last, indent: indent, style: '|', folded: folded, value: strings.Join(text, "\n")}}, true
+}
+
+// scanYAMLScalar 从 line[pos] 开始扫描一个单行纯量，返回其位置、解析后的值和结束位置。
+// flow 为 true 时纯量在 ","、"]" 处结束。
+func scanYAMLScalar(line string, pos int, flow bool) (valueSpan, int, bool) {
+	s := valueSpan{start: pos, flow: flow}
+	if pos >= len(line) {
+		return s, pos, false
+	}
+	switch q := line[pos]; q {
+	case '"':
+		for j := pos + 1; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if line[j] == '"' {
+				// YAML 与 TOML 的双引号字符串的转义写法相同 (TOML 的转义是 YAML 的子集)
+				var v string
+				if err := yaml.Unmarshal([]byte(line[pos:j+1]), &v); err != nil {
+					return s, pos, false
+				}
+				s.end, s.style, s.value = j+1, '"', v
+				return s, j + 1, true
+			}
+		}
+		return s, pos, false
+	case '\'':
+		for j := pos + 1; j < len(line); j++ {
+			if line[j] != '\'' {
+				continue
+			}
+			if j+1 < len(line) && line[j+1] == '\'' {
+				j++
+				continue
+			}
+			s.end, s.style, s.value = j+1, '\'', strings.ReplaceAll(line[pos+1:j], "''", "'")
+			return s, j + 1, true
+		}
+		return s, pos, false
+	case '[', '{', '&', '*', '!', '|', '>', '%', '@', '`':
+		return s, pos, false
+	}
+	end := len(line)
+	if i := strings.Index(line[pos:], " #"); i >= 0 {
+		end = pos + i
+	}
+	if flow {
+		if i := strings.IndexAny(line[pos:end], ",]"); i >= 0 {
+			end = pos + i
+		}
+	}
+	raw := strings.TrimRight(line[pos:end], " \t")
+	if raw == "" {
+		return s, pos, false
+	}
+	s.end, s.style, s.value = pos+len(raw), 'p', raw
+	return s, pos + len(raw), true
+}
+
+// yamlPlainSafe 报告 v 能否不加引号写成 YAML 纯量并被解析为相同的字符串。
+func yamlPlainSafe(v string, flow bool) bool {
+	if v == "" || v != strings.TrimSpace(v) || strings.ContainsAny(v, "\n\r\t") || yamlNonStringRegex.MatchString(v) {
+		return false
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(v[0])) {
+		return false
+	}
+	if strings.Contains(v, ": ") || strings.Contains(v, " #") || strings.HasSuffix(v, ":") {
+		return false
+	}
+	return (!flow || !strings.ContainsAny(v, ",[]{}")) && yamlPlainString(v)
+}
+
+// yamlPlainString 报告无引号的值 v 是否被 YAML 解析器解析为相同的字符串 (而不是数字、布尔、日期或空值)。
+func yamlPlainString(v string) bool {
+	var decoded any
+	if err := yaml.Unmarshal([]byte(v), &decoded); err != nil {
+		return false
+	}
+	s, ok := decoded.(string)
+	return ok && s == v
+}
+
+// parseTOML 定位 keys 中各顶层键 (第一个 [table] 之前) 的字符串值。
+func (fm *FrontMatter) parseTOML(keys []string) {
+	for i := 1; i < len(fm.lines)-1; i++ {
+		line := strings.TrimSuffix(fm.lines[i], "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			break
+		}
+		m := tomlKeyRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := unquoteKey(m[1])
+		if !slices.Contains(keys, key) {
+			continue
+		}
+		spans, ok := tomlSpans(line, len(m[0]))
+		switch {
+		case !ok:
+			fm.Unsupported = append(fm.Unsupported, key)
+		case len(spans) > 0:
+			for j := range spans {
+				spans[j].line = i
+			}
+			fm.fields = append(fm.fields, frontMatterField{key: key, spans: spans})
+		}
+	}
+}
+
+// tomlSpans 解析从 line[off] 开始的 TOML 值: 单行字符串或单行字符串数组。
+func tomlSpans(line string, off int) ([]valueSpan, bool) {
+	if off >= len(line) {
+		return nil, false
+	}
+	if strings.HasPrefix(line[off:], `"""`) || strings.HasPrefix(line[off:], "'''") {
+		return nil, false
+	}
+	if line[off] != '[' {
+		if line[off] != '"' && line[off] != '\'' {
+			return nil, true // 数字、布尔、日期等非字符串值
+		}
+		s, end, ok := scanYAMLScalar(line, off, false)
+		if !ok || strings.TrimSpace(stripComment(line[end:])) != "" {
+			return nil, false
+		}
+		return []valueSpan{s}, true
+	}
+	var spans []valueSpan
+	pos := off + 1
+	for {
+		pos += len(line[pos:]) - len(strings.TrimLeft(line[pos:], " \t"))
+		if pos < len(line) && line[pos] == ']' {
+			break
+		}
+		if pos >= len(line) || (line[pos] != '"' && line[pos] != '\'') {
+			return nil, false
+		}
+		s, end, ok := scanYAMLScalar(line, pos, true)
+		if !ok {
+			return nil, false
+		}
+		spans = append(spans, s)
+		pos = end + len(line[end:]) - len(strings.TrimLeft(line[end:], " \t"))
+		if pos < len(line) && line[pos] == ',' {
+			pos++
+			continue
+		}
+		if pos < len(line) && line[pos] == ']' {
+			break
+		}
+		return nil, false
+	}
+	if strings.TrimSpace(stripComment(line[pos+1:])) != "" {
+		return nil, false
+	}
+	return spans, true
+}
+
+// tomlQuote 将 v 写成 TOML 基本字符串。
+func tomlQuote(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range v {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f || r == utf8.RuneError:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// yamlQuote 将 v 写成 YAML 双引号字符串。
+func yamlQuote(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range v {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || (r >= 0x7f && r <= 0x9f) || r == 0x2028 || r == 0x2029 || r == 0xfeff || r == utf8.RuneError:
+			// 控制字符、YAML 视为换行的 U+0085/U+2028/U+2029 以及 BOM 必须转义
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// stripComment 去掉值之后以 "#" 开始的注释 (只用于已经越过引号的部分)。
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// hasContent 报告 lines 中是否有非空、非注释的行。
+func hasContent(lines []string) bool {
+	for _, l := range lines {
+		if t := strings.TrimSpace(l); t != "" && !strings.HasPrefix(t, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// unquoteKey 去掉键名两侧的引号。
+func unquoteKey(k string) string {
+	if len(k) >= 2 && (k[0] == '"' || k[0] == '\'') && k[len(k)-1] == k[0] {
+		return k[1 : len(k)-1]
+	}
+	return k
+}
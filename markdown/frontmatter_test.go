@@ -0,0 +1,174 @@
+package markdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var frontMatterKeys = []string{"title", "tags", "summary", "description"}
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		wantFormat      string // 为空表示没有 front matter
+		wantValues      []string
+		wantUnsupported []string
+		wantBody        string
+	}{
+		{
+			name:     "没有 front matter",
+			content:  "# Title\n",
+			wantBody: "# Title\n",
+		},
+		{
+			name:     "没有结束分隔行",
+			content:  "---\ntitle: Hello\n",
+			wantBody: "---\ntitle: Hello\n",
+		},
+		{
+			name:       "YAML 纯量和引号",
+			content:    "---\ntitle: Hello world # 注释\nsummary: 'It''s here'\ndescription: \"Say \\\"hi\\\" caf\\u00e9\\t\"\nauthor: me\n---\n\nBody\n",
+			wantFormat: "yaml",
+			wantValues: []string{"Hello world", "It's here", "Say \"hi\" café\t"},
+			wantBody:   "\nBody\n",
+		},
+		{
+			name:       "YAML 非字符串的值不翻译",
+			content:    "---\ntitle: 2024\nsummary: true\ntags: [a, 3, \"yes\"]\n---\n",
+			wantFormat: "yaml",
+			wantValues: []string{"a", "yes"},
+		},
+		{
+			name:       "YAML 流式数组和块列表",
+			content:    "---\ntags: [one, \"two, three\", 'four']\nsummary:\n  - first\n  # 注释\n  - 'second'\n...\n",
+			wantFormat: "yaml",
+			wantValues: []string{"one", "two, three", "four", "first", "second"},
+		},
+		{
+			name:       "YAML 块标量",
+			content:    "---\ndescription: |\n  line 1\n\n  line 2\nsummary: >-\n  folded\n  text\n---\n",
+			wantFormat: "yaml",
+			wantValues: []string{"line 1\n\nline 2", "folded\ntext"},
+		},
+		{
+			name:            "YAML 不支持的写法",
+			content:         "---\ntitle:\n  en: Hello\nsummary: &anchor value\ndescription: multi\n  line\ntags: {a: b}\n---\n",
+			wantFormat:      "yaml",
+			wantUnsupported: []string{"title", "summary", "description", "tags"},
+		},
+		{
+			name:       "TOML",
+			content:    "+++\ntitle = \"Hello \\\"world\\\"\"\ntags = [\"a\", 'b']\ndraft = true\nsummary = 3\n[params]\ndescription = \"nested\"\n+++\nBody\n",
+			wantFormat: "toml",
+			wantValues: []string{"Hello \"world\"", "a", "b"},
+			wantBody:   "Body\n",
+		},
+		{
+			name:            "TOML 不支持的写法",
+			content:         "+++\ntitle = \"\"\"\nmulti\n\"\"\"\ntags = [\"a\", 1]\n+++\n",
+			wantFormat:      "toml",
+			wantUnsupported: []string{"title", "tags"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body := SplitFrontMatter(tt.content, frontMatterKeys)
+			if body != tt.wantBody {
+				t.Errorf("正文 = %q, 期望 %q", body, tt.wantBody)
+			}
+			if tt.wantFormat == "" {
+				if fm != nil {
+					t.Fatalf("SplitFrontMatter() = %+v, 期望没有 front matter", fm)
+				}
+				return
+			}
+			if fm == nil {
+				t.Fatal("SplitFrontMatter() 未识别 front matter")
+			}
+			if fm.Format != tt.wantFormat {
+				t.Errorf("Format = %q, 期望 %q", fm.Format, tt.wantFormat)
+			}
+			if fm.Text()+body != tt.content {
+				t.Errorf("Text() + 正文 = %q, 期望原文", fm.Text()+body)
+			}
+			if got := fm.Values(); !slices.Equal(got, tt.wantValues) {
+				t.Errorf("Values() = %q, 期望 %q", got, tt.wantValues)
+			}
+			if !slices.Equal(fm.Unsupported, tt.wantUnsupported) {
+				t.Errorf("Unsupported = %q, 期望 %q", fm.Unsupported, tt.wantUnsupported)
+			}
+		})
+	}
+}
+
+func TestFrontMatterRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		values  []string
+		want    string
+	}{
+		{
+			name:    "保持纯量风格",
+			content: "---\ntitle: Hello # 注释\nsummary: 'Hi'\nauthor: me\n---\n",
+			values:  []string{"你好", "It's"},
+			want:    "---\ntitle: 你好 # 注释\nsummary: 'It''s'\nauthor: me\n---\n",
+		},
+		{
+			name:    "无法用纯量表示时改用双引号",
+			content: "---\ntitle: Hello\ntags: [a, b]\n---\n",
+			values:  []string{"键: 值", "#标签", "x, y"},
+			want:    "---\ntitle: \"键: 值\"\ntags: [\"#标签\", \"x, y\"]\n---\n",
+		},
+		{
+			name:    "会被解析为非字符串的值加上引号",
+			content: "---\ntitle: Hello\ntags: [a, b, c]\n---\n",
+			values:  []string{".5", "yes", "2024-01-01", "null"},
+			want:    "---\ntitle: \".5\"\ntags: [\"yes\", \"2024-01-01\", \"null\"]\n---\n",
+		},
+		{
+			name:    "双引号中的转义是有效的 YAML",
+			content: "---\ntitle: \"Hello\"\n---\n",
+			values:  []string{"引号\" 反斜杠\\ 换行\n 制表\t 控制\x01 NEL\u0085 LS  \U0001F600"},
+			want:    "---\ntitle: \"引号\\\" 反斜杠\\\\ 换行\\n 制表\\t 控制\\u0001 NEL\\u0085 LS\\u2028 \U0001F600\"\n---\n",
+		},
+		{
+			name:    "块标量",
+			content: "---\ndescription: |\n  line 1\n  line 2\nsummary: >\n  folded\n  text\nauthor: me\n---\n",
+			values:  []string{"第一行\n\n第二行\n", "折叠\n文本"},
+			want:    "---\ndescription: |\n  第一行\n\n  第二行\nsummary: >\n  折叠\n  文本\nauthor: me\n---\n",
+		},
+		{
+			name:    "TOML",
+			content: "+++\ntitle = 'Hello'\ntags = [\"a\", 'b']\n+++\n",
+			values:  []string{"It's", "引号\"", "制表\t"},
+			want:    "+++\ntitle = \"It's\"\ntags = [\"引号\\\"\", '制表\t']\n+++\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, _ := SplitFrontMatter(tt.content, frontMatterKeys)
+			got, err := fm.Render(tt.values)
+			if err != nil {
+				t.Fatalf("Render() 错误: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrontMatterRenderErrors(t *testing.T) {
+	fm, _ := SplitFrontMatter("---\ntitle: Hello\nsummary: Hi\n---\n", frontMatterKeys)
+	if _, err := fm.Render([]string{"只有一个"}); err == nil {
+		t.Error("值的数量不一致时 Render() 应返回错误")
+	}
+	// 块标量的第一行有额外的缩进时，后续行的缩进不足，结果无法解析
+	block, _ := SplitFrontMatter("---\ndescription: |\n  text\n---\n", frontMatterKeys)
+	if _, err := block.Render([]string{"  a\nb"}); err == nil || !strings.Contains(err.Error(), "front matter") {
+		t.Errorf("Render() 错误 = %v, 期望 front matter 无法解析", err)
+	}
+}
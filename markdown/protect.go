@@ -0,0 +1,155 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sentinelPrefix 是受保护内容占位符的前缀，完整格式为 @@MT001@@。
+// 选择不属于 Markdown 语法、也不会被翻译的纯 ASCII 标记，便于 LLM 原样保留。
+const sentinelPrefix = "@@MT"
+
+var (
+	// 自动链接，例如 <https://example.com> 或 <mailto:a@b.c>
+	autolinkRegex = regexp.MustCompile(`<(?:https?|ftp|mailto):[^\s<>]+>`)
+	// 裸 URL (包括 [text](url) 中的 url)，结尾的标点在 protectURLs 中剔除
+	urlRegex = regexp.MustCompile("https?://[^\\s<>()\\[\\]\"'`]+")
+	// tldr 风格的 {{placeholder}}
+	placeholderRegex = regexp.MustCompile(`\{\{.*?\}\}`)
+	// 被 LLM 改动过的占位符，例如 "@@ MT001 @@"、"@MT001@"、"MT001@@"
+	alteredSentinelRegex = regexp.MustCompile(`@+\s*MT\s*\d+\s*@*|MT\d{3,}\s*@+`)
+)
+
+// Protection 记录一次 Protect 调用中被替换为占位符的原始内容，用于翻译后还原。
+type Protection struct {
+	originals []string     // 第 i 项对应占位符 sentinel(i)
+	nested    map[int]bool // 出现在其他受保护内容中的占位符 (例如 JSX 属性中的行内代码)，不会单独出现在译文中
+	reused    int          // 从翻译记忆复用译文的段落数 (这些段落的占位符还原为译文)
+}
+
+// Protect 将不应被翻译的内容替换为不透明的占位符 (如 @@MT001@@)，返回替换后的文本。
+// 受保护的内容包括: 围栏代码块、行内代码、自动链接、URL 以及 tldr 的 {{placeholder}}，
+// 以及 format 特有的语法 (MDX 的 import/export 和 JSX，Quarto 的 YAML 头和属性，见 protectFormat)。
+// reuse 不为 nil 时，先将 reuse 能给出译文的段落 (见 Segments) 整体替换为占位符，还原时得到该译文 (翻译记忆的精确匹配)。
+// 如果原文本身已包含占位符前缀，为避免混淆，不做任何替换。
+func Protect(content string, format Format, reuse func(segment string) (string, bool)) (string, *Protection) {
+	p := &Protection{}
+	if strings.Contains(content, sentinelPrefix) {
+		return content, p
+	}
+	text := content
+	if reuse != nil {
+		text = p.reuseSegments(text, reuse)
+	}
+	text = p.protectFencedBlocks(text)
+	text = p.protectCodeSpans(text)
+	text = p.protectFormat(text, format)
+	text = autolinkRegex.ReplaceAllStringFunc(text, p.add)
+	text = p.protectURLs(text)
+	text = placeholderRegex.ReplaceAllStringFunc(text, p.add)
+	return text, p
+}
+
+// Len 返回被保护的内容数量。
+func (p *Protection) Len() int {
+	return len(p.originals)
+}
+
+// ProtectionError 表示译文中的占位符与原文不一致。
+type ProtectionError struct {
+	Missing    []string // 译文中缺失的占位符
+	Duplicated []string // 译文中出现多次的占位符
+	Altered    []string // 被 LLM 改动过的占位符片段
+}
+
+func (e *ProtectionError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("缺失 %s", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Duplicated) > 0 {
+		parts = append(parts, fmt.Sprintf("重复 %s", strings.Join(e.Duplicated, ", ")))
+	}
+	if len(e.Altered) > 0 {
+		parts = append(parts, fmt.Sprintf("被改动 %q", e.Altered))
+	}
+	return "受保护内容的占位符不一致: " + strings.Join(parts, "; ")
+}
+
+// Restore 将译文中的占位符还原为原始内容。
+// 任何占位符缺失、重复或被改动时返回 *ProtectionError，调用方应将该文件视为翻译失败。
+func (p *Protection) Restore(translated string) (string, error) {
+	if len(p.originals) == 0 {
+		return translated, nil
+	}
+	protErr := &ProtectionError{}
+	remaining := translated
+	pairs := make([]string, 0, len(p.originals)*2)
+	for i, original := range p.originals {
+		s := sentinel(i)
+		pairs = append(pairs, s, original)
+		if p.nested[i] {
+			continue
+		}
+		switch n := strings.Count(translated, s); {
+		case n == 0:
+			protErr.Missing = append(protErr.Missing, s)
+		case n > 1:
+			protErr.Duplicated = append(protErr.Duplicated, s)
+		}
+		remaining = strings.ReplaceAll(remaining, s, "")
+	}
+	protErr.Altered = alteredSentinelRegex.FindAllString(remaining, -1)
+
+	if len(protErr.Missing)+len(protErr.Duplicated)+len(protErr.Altered) > 0 {
+		return "", protErr
+	}
+	// 受保护内容中可能嵌套了先替换的占位符，逐层还原 (原文不含占位符前缀，见 Protect)
+	replacer := strings.NewReplacer(pairs...)
+	restored := replacer.Replace(translated)
+	for range p.originals {
+		if !strings.Contains(restored, sentinelPrefix) {
+			break
+		}
+		restored = replacer.Replace(restored)
+	}
+	return restored, nil
+}
+
+// add 记录一段原始内容并返回对应的占位符。原始内容中已有的占位符被标记为嵌套。
+func (p *Protection) add(original string) string {
+	for i := range p.originals {
+		if strings.Contains(original, sentinelPrefix) && strings.Contains(original, sentinel(i)) {
+			if p.nested == nil {
+				p.nested = map[int]bool{}
+			}
+			p.nested[i] = true
+		}
+	}
+	p.originals = append(p.originals, original)
+	return sentinel(len(p.originals) - 1)
+}
+
+// sentinel 返回第 i 个受保护内容的占位符。
+func sentinel(i int) string {
+	return fmt.Sprintf("%s%03d@@", sentinelPrefix, i+1)
+}
+
+// protectFencedBlocks 将围栏代码块 (包括列表中缩进的代码块) 整体替换为占位符，保留首行缩进。
+func (p *Protection) protectFencedBlocks(content string) string {
+	return replaceFencedBlocks(content, func(block string) string { return p.add(block) })
+}
+
+// protectCodeSpans 将行内代码 (由相同长度的反引号串包围) 整体替换为占位符。
+func (p *Protection) protectCodeSpans(text string) string {
+	return replaceCodeSpans(text, p.add)
+}
+
+// protectURLs 将裸 URL 替换为占位符，结尾的句号、逗号等标点不属于 URL。
+func (p *Protection) protectURLs(text string) string {
+	return urlRegex.ReplaceAllStringFunc(text, func(url string) string {
+		trimmed := strings.TrimRight(url, ".,;:!?")
+		return p.add(trimmed) + url[len(trimmed):]
+	})
+}
@@ -0,0 +1,165 @@
+package markdown
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		format    Format
+		want      string
+		originals []string
+	}{
+		{
+			name:    "没有受保护的内容",
+			content: "Hello, world.",
+			want:    "Hello, world.",
+		},
+		{
+			name:      "围栏代码块",
+			content:   "Intro\n\n```sh\nls\n```\n\nEnd",
+			want:      "Intro\n\n@@MT001@@\n\nEnd",
+			originals: []string{"```sh\nls\n```"},
+		},
+		{
+			name:      "列表中缩进的围栏代码块保留首行缩进",
+			content:   "- item\n\n  ```sh\n  ls\n  ```\n",
+			want:      "- item\n\n  @@MT001@@\n",
+			originals: []string{"```sh\n  ls\n  ```"},
+		},
+		{
+			name:      "行内代码与链接 URL",
+			content:   "[link](https://example.com/p) and `a``b`",
+			want:      "[link](@@MT002@@) and @@MT001@@",
+			originals: []string{"`a``b`", "https://example.com/p"},
+		},
+		{
+			name:      "自动链接与 URL 结尾的标点",
+			content:   "See https://example.com/a. Or <https://x.y>!",
+			want:      "See @@MT002@@. Or @@MT001@@!",
+			originals: []string{"<https://x.y>", "https://example.com/a"},
+		},
+		{
+			name:      "tldr 占位符",
+			content:   "Copy {{path/to/file}} to {{dir}}",
+			want:      "Copy @@MT001@@ to @@MT002@@",
+			originals: []string{"{{path/to/file}}", "{{dir}}"},
+		},
+		{
+			name:      "MDX 的 import、JSX 和表达式",
+			content:   "import X from 'y'\n\n# Hi <Note title=\"`x`\">text</Note> {1+1}",
+			format:    FormatMDX,
+			want:      "@@MT002@@\n\n# Hi @@MT003@@text@@MT004@@ @@MT005@@",
+			originals: []string{"`x`", "import X from 'y'", "<Note title=\"@@MT001@@\">", "</Note>", "{1+1}"},
+		},
+		{
+			name:      "普通 Markdown 不保护 JSX",
+			content:   "a <b>c</b>",
+			want:      "a <b>c</b>",
+			originals: nil,
+		},
+		{
+			name:      "Quarto 的 YAML 头、::: 块和属性",
+			content:   "---\ntitle: A\n---\n\n# Intro {#sec-intro}\n\n::: {.callout-note}\nHi\n:::\n",
+			format:    FormatQuarto,
+			want:      "@@MT001@@\n\n# Intro @@MT004@@\n\n@@MT002@@\nHi\n@@MT003@@\n",
+			originals: []string{"---\ntitle: A\n---", "::: {.callout-note}", ":::", "{#sec-intro}"},
+		},
+		{
+			name:    "原文已包含占位符前缀时不替换",
+			content: "already @@MT001@@ here `code`",
+			want:    "already @@MT001@@ here `code`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, p := Protect(tt.content, tt.format, nil)
+			if got != tt.want {
+				t.Errorf("Protect() = %q, 期望 %q", got, tt.want)
+			}
+			if !slices.Equal(p.originals, tt.originals) {
+				t.Errorf("受保护的内容 = %q, 期望 %q", p.originals, tt.originals)
+			}
+			// 原样返回的译文应还原为原文
+			restored, err := p.Restore(got)
+			if err != nil {
+				t.Fatalf("Restore() 错误: %v", err)
+			}
+			if restored != tt.content {
+				t.Errorf("Restore() = %q, 期望 %q", restored, tt.content)
+			}
+		})
+	}
+}
+
+func TestProtectReuse(t *testing.T) {
+	memory := map[string]string{"Hello.": "你好。"}
+	reuse := func(segment string) (string, bool) {
+		translated, ok := memory[segment]
+		return translated, ok
+	}
+	content := "# Title\n\nHello.\n\nRun `ls`.\n"
+
+	got, p := Protect(content, FormatMarkdown, reuse)
+	if want := "# Title\n\n@@MT001@@\n\nRun @@MT002@@.\n"; got != want {
+		t.Errorf("Protect() = %q, 期望 %q", got, want)
+	}
+	if p.Reused() != 1 {
+		t.Errorf("Reused() = %d, 期望 1", p.Reused())
+	}
+	restored, err := p.Restore("# 标题\n\n@@MT001@@\n\n运行 @@MT002@@。\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# 标题\n\n你好。\n\n运行 `ls`。\n"; restored != want {
+		t.Errorf("Restore() = %q, 期望 %q", restored, want)
+	}
+
+	// Reuse 只替换复用的段落，不保护行内代码
+	got, p = Reuse(content, reuse)
+	if want := "# Title\n\n@@MT001@@\n\nRun `ls`.\n"; got != want || p.Len() != 1 {
+		t.Errorf("Reuse() = %q (%d 个占位符), 期望 %q (1 个)", got, p.Len(), want)
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	_, p := Protect("Use `a` and `b`.", FormatMarkdown, nil)
+	tests := []struct {
+		name       string
+		translated string
+		missing    []string
+		duplicated []string
+		altered    []string
+	}{
+		{name: "缺失", translated: "使用 @@MT001@@。", missing: []string{"@@MT002@@"}},
+		{name: "重复", translated: "@@MT001@@ @@MT001@@ @@MT002@@", duplicated: []string{"@@MT001@@"}},
+		{name: "被改动", translated: "@@MT001@@ 和 @@ MT002 @@", missing: []string{"@@MT002@@"}, altered: []string{"@@ MT002 @@"}},
+		{name: "缺少结尾的 @@", translated: "@@MT001@@ 和 @@MT002", missing: []string{"@@MT002@@"}, altered: []string{"@@MT002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Restore(tt.translated)
+			var protErr *ProtectionError
+			if !errors.As(err, &protErr) {
+				t.Fatalf("Restore() 错误 = %v, 期望 *ProtectionError", err)
+			}
+			if !slices.Equal(protErr.Missing, tt.missing) || !slices.Equal(protErr.Duplicated, tt.duplicated) || !slices.Equal(protErr.Altered, tt.altered) {
+				t.Errorf("Restore() 错误 = %+v, 期望缺失 %q、重复 %q、被改动 %q", protErr, tt.missing, tt.duplicated, tt.altered)
+			}
+		})
+	}
+
+	// 嵌套在其他受保护内容中的占位符不要求单独出现在译文中
+	_, p = Protect(`<Note title="`+"`x`"+`">text</Note>`, FormatMDX, nil)
+	restored, err := p.Restore("@@MT002@@文本@@MT003@@")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<Note title="` + "`x`" + `">文本</Note>`; restored != want {
+		t.Errorf("Restore() = %q, 期望 %q", restored, want)
+	}
+}
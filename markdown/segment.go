@@ -0,0 +1,100 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sentinelOnlyRegex 匹配占位符和空白，用于判断文本是否已没有需要翻译的内容。
+var sentinelOnlyRegex = regexp.MustCompile(`^(?:\s|` + sentinelPrefix + `\d{3,}@@)*$`)
+
+// Units 与 ParseBlocks 相同，但将列表切分为顶层列表项 (连同其续行和子列表)，使每个列表项成为独立的单元。
+// 单元覆盖文档中所有的非空行，返回的 Block 中 StartLine/EndLine 指向文档中的行。
+func Units(content string) []Block {
+	var units []Block
+	for _, b := range ParseBlocks(content) {
+		if b.Kind == BlockList {
+			units = append(units, splitListItems(b)...)
+		} else {
+			units = append(units, b)
+		}
+	}
+	return units
+}
+
+// Segments 将文档切分为翻译记忆使用的段落: 标题、段落、引用块、表格以及每个顶层列表项。
+// 即 Units 中除围栏代码块和分隔线以外的单元。
+func Segments(content string) []Block {
+	var segments []Block
+	for _, u := range Units(content) {
+		if u.Kind != BlockFencedCode && !thematicBreakRegex.MatchString(u.Text) {
+			segments = append(segments, u)
+		}
+	}
+	return segments
+}
+
+// splitListItems 将列表块按顶层列表项 (缩进与第一项相同的列表项行) 切分，每项不含项之间的空行。
+func splitListItems(b Block) []Block {
+	lines := strings.Split(b.Text, "\n")
+	indent := len(lines[0]) - len(strings.TrimLeft(lines[0], " "))
+	var items []Block
+	start := 0
+	flush := func(end int) {
+		for end > start && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		items = append(items, Block{
+			Kind:      BlockList,
+			Text:      strings.Join(lines[start:end], "\n"),
+			StartLine: b.StartLine + start,
+			EndLine:   b.StartLine + end,
+		})
+	}
+	for i := 1; i < len(lines); i++ {
+		line := lines[i]
+		if listItemRegex.MatchString(line) && len(line)-len(strings.TrimLeft(line, " ")) == indent {
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(lines))
+	return items
+}
+
+// Reuse 只将 reuse 能给出译文的段落替换为占位符 (不保护代码、URL 等其他内容)，用于未启用内容保护时复用翻译记忆。
+// 与 Protect 相同，原文本身已包含占位符前缀时不做任何替换。
+func Reuse(content string, reuse func(segment string) (string, bool)) (string, *Protection) {
+	p := &Protection{}
+	if strings.Contains(content, sentinelPrefix) {
+		return content, p
+	}
+	return p.reuseSegments(content, reuse), p
+}
+
+// reuseSegments 将 reuse 能给出译文的段落整体替换为占位符，还原时得到该译文而不是原文 (见 Protect)。
+func (p *Protection) reuseSegments(content string, reuse func(segment string) (string, bool)) string {
+	lines := strings.Split(content, "\n")
+	segments := Segments(content)
+	// 从后往前替换，前面段落的行号不受影响
+	for i := len(segments) - 1; i >= 0; i-- {
+		s := segments[i]
+		translated, ok := reuse(s.Text)
+		if !ok {
+			continue
+		}
+		p.reused++
+		lines = append(lines[:s.StartLine], append([]string{p.add(translated)}, lines[s.EndLine:]...)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Reused 返回从翻译记忆复用译文的段落数。
+func (p *Protection) Reused() int {
+	return p.reused
+}
+
+// OnlySentinels 报告文本是否只由占位符和空白组成，即没有需要 LLM 翻译的内容。
+func OnlySentinels(text string) bool {
+	return sentinelOnlyRegex.MatchString(text)
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"fmt"
+
+	"Markdown-translator-go/markdown"
+)
+
+// Pair 是一对对齐的原文段落和译文段落。
+type Pair struct {
+	Source string
+	Target string
+}
+
+// Align 将原文与译文按段落 (见 markdown.Segments) 一一对齐。front matter 不参与对齐。
+// 只有两者的段落数相同、且每对段落的类型 (以及标题级别) 一致时才认为对齐可靠，否则返回错误，该文件不写入翻译记忆。
+// 译文与原文相同或原文没有字母的段落不返回。
+func Align(source, translated string) ([]Pair, error) {
+	_, source = markdown.SplitFrontMatter(source, nil)
+	_, translated = markdown.SplitFrontMatter(translated, nil)
+	src, dst := markdown.Segments(source), markdown.Segments(translated)
+	if len(src) != len(dst) {
+		return nil, fmt.Errorf("段落数不一致 (原文 %d 个，译文 %d 个)", len(src), len(dst))
+	}
+	pairs := make([]Pair, 0, len(src))
+	for i := range src {
+		if src[i].Kind != dst[i].Kind || src[i].Level != dst[i].Level {
+			return nil, fmt.Errorf("第 %d 个段落的类型不一致 (原文为%s，译文为%s)", i+1, src[i].Kind, dst[i].Kind)
+		}
+		// 译文与原文相同 (未翻译) 或没有字母 (只有代码、链接或符号) 的段落作为参考译文没有意义，
+		// 还会诱导 LLM 保留原文，不写入翻译记忆
+		if src[i].Text == dst[i].Text || !hasLetter(src[i].Text) {
+			continue
+		}
+		pairs = append(pairs, Pair{Source: src[i].Text, Target: dst[i].Text})
+	}
+	return pairs, nil
+}
@@ -0,0 +1,76 @@
+package memory
+
+import "unicode/utf8"
+
+// pairIndex 是一个语言对的条目及其模糊匹配索引。
+// 条目只会追加 (相同原文的条目原地替换，原文不变)，因此下标在翻译记忆的生命周期内保持不变。
+type pairIndex struct {
+	entries []*Entry
+	lengths []int            // 第 i 项为 entries[i] 原文的字符数
+	grams   map[string][]int // 三元组 (连续 3 个字符) -> 原文包含它的条目下标
+}
+
+func newPairIndex() *pairIndex {
+	return &pairIndex{grams: make(map[string][]int)}
+}
+
+// add 将条目加入索引。
+func (idx *pairIndex) add(e *Entry) {
+	i := len(idx.entries)
+	idx.entries = append(idx.entries, e)
+	idx.lengths = append(idx.lengths, utf8.RuneCountInString(e.Source))
+	for _, g := range trigrams(e.Source) {
+		idx.grams[g] = append(idx.grams[g], i)
+	}
+}
+
+// best 返回原文与 text 的相似度 (见 similarity) 不低于 threshold 的最相似条目，没有时返回 nil。
+// 只对通过两项过滤的条目计算编辑距离:
+//   - 长度: 编辑距离不小于长度之差，长度差异已使相似度不可能达到当前最高分的条目跳过;
+//   - 三元组: 每次编辑最多使 text 中 3 个不同的三元组在条目中消失，编辑距离为 d 的条目至少与 text 共有 |三元组| - 3d 个三元组。
+func (idx *pairIndex) best(text string, threshold float64) (*Entry, float64) {
+	n := utf8.RuneCountInString(text)
+	grams := trigrams(text)
+	shared := make(map[int]int)
+	for _, g := range grams {
+		for _, i := range idx.grams[g] {
+			shared[i]++
+		}
+	}
+
+	var best *Entry
+	bestScore := threshold
+	for i, e := range idx.entries {
+		longer, shorter := max(n, idx.lengths[i]), min(n, idx.lengths[i])
+		if longer == 0 || 1-float64(longer-shorter)/float64(longer) < bestScore {
+			continue
+		}
+		// 相似度不低于 bestScore 时允许的最大编辑距离 (加上一个很小的值，避免浮点误差导致向下取整错误)
+		maxEdits := int((1-bestScore)*float64(longer) + 1e-9)
+		if shared[i] < len(grams)-3*maxEdits {
+			continue
+		}
+		if score := similarity(text, e.Source, bestScore); score >= bestScore && (best == nil || score > bestScore) {
+			best, bestScore = e, score
+		}
+	}
+	return best, bestScore
+}
+
+// trigrams 返回 s 中所有不同的三元组 (按字符计算)。少于 3 个字符时返回空。
+func trigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 3 {
+		return nil
+	}
+	seen := make(map[string]bool, len(runes)-2)
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		g := string(runes[i : i+3])
+		if !seen[g] {
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+	return grams
+}
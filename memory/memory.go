@@ -0,0 +1,335 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"Markdown-translator-go/markdown"
+)
+
+// MaxReferences 是每个片段最多提供给 LLM 的模糊匹配参考译文数。
+const MaxReferences = 10
+
+// maxSimilarMemo 是 Similar 缓存的查询结果数上限，超过时清空缓存。
+const maxSimilarMemo = 256
+
+// Entry 是翻译记忆中一对对齐的原文段落和译文段落。
+type Entry struct {
+	SourceLang string    `json:"source_lang"`    // 源语言代码
+	TargetLang string    `json:"target_lang"`    // 目标语言代码
+	Source     string    `json:"source"`         // 原文段落
+	Target     string    `json:"target"`         // 译文段落
+	File       string    `json:"file,omitempty"` // 最近一次产生该条目的文件 (相对路径)
+	Updated    time.Time `json:"updated"`        // 最近一次写入的时间
+}
+
+// key 唯一确定一个条目: 同一语言对中相同的原文只保留最新的译文。
+type key struct {
+	sourceLang, targetLang, source string
+}
+
+// Memory 是段落级的翻译记忆，以 JSON Lines 格式存储在一个文件中。
+// 新条目追加写入文件末尾，加载时后面的记录覆盖前面相同原文的记录。
+// nil 的 *Memory 表示未启用翻译记忆，查询方法都可以安全调用。
+type Memory struct {
+	path string
+
+	mu      sync.RWMutex
+	entries map[key]*Entry
+	byPair  map[[2]string]*pairIndex // 语言对 -> 条目及模糊匹配索引
+	file    *os.File                 // 追加写入的文件，第一次写入时打开
+
+	// Similar 的查询结果缓存: 同一片段在预估用量和翻译时各渲染一次 Prompt，只计算一次参考译文
+	memoMu sync.Mutex
+	memo   map[similarKey][]Match
+}
+
+// similarKey 唯一确定一次 Similar 查询。
+type similarKey struct {
+	sourceLang, targetLang, content string
+	threshold                       float64
+}
+
+// Open 加载翻译记忆文件，文件不存在时返回空的翻译记忆 (第一次写入时创建文件)。
+// 无法解析的行 (例如写入中断留下的不完整记录) 被跳过并记录警告。
+func Open(path string) (*Memory, error) {
+	m := &Memory{
+		path:    path,
+		entries: make(map[key]*Entry),
+		byPair:  make(map[[2]string]*pairIndex),
+		memo:    make(map[similarKey][]Match),
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开翻译记忆失败: %w", err)
+	}
+	defer f.Close()
+
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.Source == "" {
+			skipped++
+			continue
+		}
+		m.put(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取翻译记忆 %s 失败: %w", path, err)
+	}
+	if skipped > 0 {
+		log.Printf("警告: 翻译记忆 %s 中有 %d 行无法解析，已跳过。\n", path, skipped)
+	}
+	return m, nil
+}
+
+// put 添加或替换条目。调用方需持有写锁 (Open 时除外)。
+func (m *Memory) put(e Entry) {
+	k := key{e.SourceLang, e.TargetLang, e.Source}
+	if old, ok := m.entries[k]; ok {
+		*old = e
+		return
+	}
+	entry := &e
+	m.entries[k] = entry
+	pair := [2]string{e.SourceLang, e.TargetLang}
+	idx, ok := m.byPair[pair]
+	if !ok {
+		idx = newPairIndex()
+		m.byPair[pair] = idx
+	}
+	idx.add(entry)
+}
+
+// Len 返回条目数。
+func (m *Memory) Len() int {
+	if m == nil {
+		return 0
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+// Entries 返回所有条目的副本，按语言对和原文排序。
+func (m *Memory) Entries() []Entry {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	entries := make([]Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, *e)
+	}
+	m.mu.RUnlock()
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.SourceLang+"\x00"+a.Source+"\x00"+a.TargetLang, b.SourceLang+"\x00"+b.Source+"\x00"+b.TargetLang)
+	})
+	return entries
+}
+
+// Lookup 返回原文段落 source 在语言对 sourceLang → targetLang 中的译文 (精确匹配)。
+func (m *Memory) Lookup(sourceLang, targetLang, source string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[key{sourceLang, targetLang, source}]
+	if !ok {
+		return "", false
+	}
+	return e.Target, true
+}
+
+// Match 是一个模糊匹配结果。
+type Match struct {
+	Entry
+	Score float64 // 原文的相似度 (0~1)
+}
+
+// Similar 为 content 中每个没有精确匹配的段落查找相似度不低于 threshold 的最相似条目，
+// 按相似度从高到低返回，最多 MaxReferences 个。threshold <= 0 时不查找。
+// 相同的查询 (同一片段) 只计算一次，之后返回缓存的结果; 期间新增的条目不影响已缓存的结果。
+func (m *Memory) Similar(sourceLang, targetLang, content string, threshold float64) []Match {
+	if m == nil || threshold <= 0 {
+		return nil
+	}
+	k := similarKey{sourceLang, targetLang, content, threshold}
+	m.memoMu.Lock()
+	matches, ok := m.memo[k]
+	m.memoMu.Unlock()
+	if ok {
+		return slices.Clone(matches)
+	}
+
+	matches = m.similar(sourceLang, targetLang, content, threshold)
+	m.memoMu.Lock()
+	if len(m.memo) >= maxSimilarMemo {
+		clear(m.memo)
+	}
+	m.memo[k] = matches
+	m.memoMu.Unlock()
+	return slices.Clone(matches)
+}
+
+// similar 执行 Similar 的查询 (不使用缓存)。
+func (m *Memory) similar(sourceLang, targetLang, content string, threshold float64) []Match {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.byPair[[2]string{sourceLang, targetLang}]
+	if idx == nil || len(idx.entries) == 0 {
+		return nil
+	}
+
+	var matches []Match
+	seen := make(map[*Entry]bool)
+	for _, s := range markdown.Segments(content) {
+		if _, ok := m.entries[key{sourceLang, targetLang, s.Text}]; ok || !hasLetter(s.Text) {
+			continue
+		}
+		best, bestScore := idx.best(s.Text, threshold)
+		if best != nil && !seen[best] {
+			seen[best] = true
+			matches = append(matches, Match{Entry: *best, Score: bestScore})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(matches) > MaxReferences {
+		matches = matches[:MaxReferences]
+	}
+	return matches
+}
+
+// Add 将对齐的段落写入翻译记忆，返回新增或译文发生变化的条目数。已有且译文相同的条目不会重复写入。
+func (m *Memory) Add(sourceLang, targetLang, file string, pairs []Pair) (int, error) {
+	if m == nil {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // 保持 Markdown 中的 <、>、& 可读
+	now := time.Now()
+	added := 0
+	for _, p := range pairs {
+		if old, ok := m.entries[key{sourceLang, targetLang, p.Source}]; ok && old.Target == p.Target {
+			continue
+		}
+		e := Entry{SourceLang: sourceLang, TargetLang: targetLang, Source: p.Source, Target: p.Target, File: filepath.ToSlash(file), Updated: now}
+		if err := enc.Encode(e); err != nil {
+			return 0, fmt.Errorf("序列化翻译记忆条目失败: %w", err)
+		}
+		m.put(e)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	if m.file == nil {
+		if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+			return 0, fmt.Errorf("创建翻译记忆目录失败: %w", err)
+		}
+		f, err := os.OpenFile(m.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return 0, fmt.Errorf("打开翻译记忆 %s 失败: %w", m.path, err)
+		}
+		m.file = f
+	}
+	// 一个文件的所有条目一次写入，减少与其他进程交错写入的可能
+	if _, err := m.file.Write(buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("写入翻译记忆 %s 失败: %w", m.path, err)
+	}
+	return added, nil
+}
+
+// Close 关闭翻译记忆文件。
+func (m *Memory) Close() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
+
+// Format 将模糊匹配结果格式化为注入 Prompt 模板的 {{.References}} 文本，每行一个参考译文。
+func Format(matches []Match) string {
+	lines := make([]string, len(matches))
+	for i, match := range matches {
+		lines[i] = fmt.Sprintf("- %q → %q (%.0f%% similar)", match.Source, match.Target, match.Score*100)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// similarity 返回 a 与 b 的相似度: 1 减去按字符计算的编辑距离与较长者长度之比。
+// 编辑距离不小于长度之差，长度差异已使相似度不可能达到 threshold 时直接返回 0。
+func similarity(a, b string, threshold float64) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	la, lb := len(ra), len(rb)
+	longer := max(la, lb)
+	if longer == 0 || 1-float64(longer-min(la, lb))/float64(longer) < threshold {
+		return 0
+	}
+	prev := make([]int, lb+1)
+	curr := make([]int, lb+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= la; i++ {
+		curr[0] = i
+		for j := 1; j <= lb; j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[lb])/float64(longer)
+}
+
+// hasLetter 报告文本中是否有字母 (只有代码、链接或符号的段落不需要参考译文)。
+func hasLetter(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		want       []Pair
+		wantErr    bool
+	}{
+		{
+			name:       "标题和段落",
+			source:     "# Title\n\nHello world.\n",
+			translated: "# 标题\n\n你好，世界。\n",
+			want:       []Pair{{"# Title", "# 标题"}, {"Hello world.", "你好，世界。"}},
+		},
+		{
+			name:       "忽略 front matter",
+			source:     "---\ntitle: A\n---\n\nHello.\n",
+			translated: "---\ntitle: 甲\n---\n\n你好。\n",
+			want:       []Pair{{"Hello.", "你好。"}},
+		},
+		{
+			name:       "跳过未翻译的段落",
+			source:     "Hello.\n\nKeep me.\n",
+			translated: "你好。\n\nKeep me.\n",
+			want:       []Pair{{"Hello.", "你好。"}},
+		},
+		{
+			name:       "跳过没有字母的段落",
+			source:     "Hello.\n\n123 -> 456\n",
+			translated: "你好。\n\n123 → 456\n",
+			want:       []Pair{{"Hello.", "你好。"}},
+		},
+		{
+			name:       "围栏代码块不参与对齐",
+			source:     "Hello.\n\n```go\nfmt.Println()\n```\n",
+			translated: "你好。\n\n```go\nfmt.Println()\n```\n",
+			want:       []Pair{{"Hello.", "你好。"}},
+		},
+		{
+			name:       "段落数不一致",
+			source:     "A.\n\nB.\n",
+			translated: "甲。乙。\n",
+			wantErr:    true,
+		},
+		{
+			name:       "段落类型不一致",
+			source:     "# A\n\nB.\n",
+			translated: "甲\n\n乙。\n",
+			wantErr:    true,
+		},
+		{
+			name:       "标题级别不一致",
+			source:     "# A\n",
+			translated: "## 甲\n",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Align(tt.source, tt.translated)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Align() 错误 = %v, 期望错误: %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("Align() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		threshold float64
+		want      float64
+	}{
+		{"abc", "abc", 0.5, 1},
+		{"", "", 0.5, 1},
+		{"abcd", "abcx", 0.5, 0.75},
+		{"abcd", "abc", 0.5, 0.75},
+		{"kitten", "sitting", 0, 1 - 3.0/7},
+		{"数据翻译", "数据处理", 0.5, 0.5},
+		{"abcd", "wxyz", 0, 0},
+		// 长度差异已使相似度低于阈值时直接返回 0
+		{"a", "abcdefgh", 0.5, 0},
+		{"abc", "", 0.1, 0},
+	}
+	for _, tt := range tests {
+		got := similarity(tt.a, tt.b, tt.threshold)
+		if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("similarity(%q, %q, %v) = %v, 期望 %v", tt.a, tt.b, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"ab", nil},
+		{"abc", []string{"abc"}},
+		{"abcd", []string{"abc", "bcd"}},
+		{"aaaa", []string{"aaa"}},
+		{"数据翻译", []string{"数据翻", "据翻译"}},
+	}
+	for _, tt := range tests {
+		if got := trigrams(tt.s); !slices.Equal(got, tt.want) {
+			t.Errorf("trigrams(%q) = %q, 期望 %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestPairIndexBest 检查经过长度和三元组过滤后的查找结果与逐条计算相似度的结果一致。
+func TestPairIndexBest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "beta", "gamma", "the", "quick", "fox", "a", "数据", "翻译", "。"}
+	sentence := func() string {
+		var b strings.Builder
+		for range 2 + r.Intn(8) {
+			b.WriteString(words[r.Intn(len(words))])
+			b.WriteByte(' ')
+		}
+		return b.String()
+	}
+	idx := newPairIndex()
+	var entries []*Entry
+	for i := range 300 {
+		e := &Entry{Source: sentence(), Target: fmt.Sprint(i)}
+		idx.add(e)
+		entries = append(entries, e)
+	}
+	for range 200 {
+		text := sentence()
+		for _, threshold := range []float64{0.3, 0.6, 0.75, 0.9} {
+			var want *Entry
+			wantScore := threshold
+			for _, e := range entries {
+				if score := similarity(text, e.Source, wantScore); score >= wantScore && (want == nil || score > wantScore) {
+					want, wantScore = e, score
+				}
+			}
+			got, gotScore := idx.best(text, threshold)
+			if got != want || (got != nil && gotScore != wantScore) {
+				t.Fatalf("best(%q, %v) = %v (%v), 期望 %v (%v)", text, threshold, got, gotScore, want, wantScore)
+			}
+		}
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	m, err := Open(filepath.Join(t.TempDir(), "tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	pairs := []Pair{
+		{"Install the package with npm.", "使用 npm 安装软件包。"},
+		{"Run the tests before committing.", "提交前运行测试。"},
+		{"Completely unrelated sentence here.", "完全无关的句子。"},
+	}
+	if _, err := m.Add("en", "zh", "a.md", pairs); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "Install the package with yarn.\n\nRun the tests before committing.\n\nSomething else entirely.\n"
+	got := m.Similar("en", "zh", content, 0.7)
+	// 第二段有精确匹配 (由翻译记忆直接复用)，不作为参考译文
+	if len(got) != 1 || got[0].Source != pairs[0].Source {
+		t.Fatalf("Similar() = %+v, 期望只匹配 %q", got, pairs[0].Source)
+	}
+	if got[0].Score < 0.7 || got[0].Score >= 1 {
+		t.Errorf("相似度 = %v, 期望在 [0.7, 1) 内", got[0].Score)
+	}
+
+	if got := m.Similar("en", "ja", content, 0.7); len(got) != 0 {
+		t.Errorf("其他语言对的 Similar() = %+v, 期望为空", got)
+	}
+	if got := m.Similar("en", "zh", content, 0); got != nil {
+		t.Errorf("threshold 为 0 时 Similar() = %+v, 期望为 nil", got)
+	}
+
+	// 相同的查询返回缓存的结果，修改返回值不影响缓存
+	got[0].Target = "changed"
+	again := m.Similar("en", "zh", content, 0.7)
+	if len(again) != 1 || again[0].Target != pairs[0].Target {
+		t.Errorf("再次查询 Similar() = %+v, 期望与第一次相同", again)
+	}
+}
+
+func TestOpenReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tm.jsonl")
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Add("en", "zh", "a.md", []Pair{{"Hello.", "你好。"}, {"Bye.", "再见。"}}); err != nil || n != 2 {
+		t.Fatalf("Add() = %d, %v, 期望 2, nil", n, err)
+	}
+	// 相同的译文不重复写入，不同的译文覆盖原有条目
+	if n, err := m.Add("en", "zh", "b.md", []Pair{{"Hello.", "你好。"}, {"Bye.", "拜拜。"}}); err != nil || n != 1 {
+		t.Fatalf("Add() = %d, %v, 期望 1, nil", n, err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Len() != 2 {
+		t.Errorf("Len() = %d, 期望 2", reloaded.Len())
+	}
+	if got, ok := reloaded.Lookup("en", "zh", "Bye."); !ok || got != "拜拜。" {
+		t.Errorf("Lookup() = %q, %t, 期望 %q", got, ok, "拜拜。")
+	}
+	if _, ok := reloaded.Lookup("en", "ja", "Bye."); ok {
+		t.Error("其他语言对的 Lookup() 不应命中")
+	}
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TMX 1.4 文档结构 (只包含导出需要的元素和属性)。
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	Props    []tmxProp    `xml:"prop"`
+	Variants []tmxVariant `xml:"tuv"`
+}
+
+type tmxProp struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tmxVariant struct {
+	Lang       string `xml:"xml:lang,attr"`
+	ChangeDate string `xml:"changedate,attr,omitempty"`
+	Seg        string `xml:"seg"`
+}
+
+// WriteTMX 将翻译记忆以 TMX 1.4 格式写入 w。
+// 原文相同的条目 (不同目标语言) 合并为一个 <tu>，语言代码中的 "_" 转换为 "-" (例如 zh_TW → zh-TW)。
+func (m *Memory) WriteTMX(w io.Writer) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "Markdown-translator-go",
+			CreationToolVersion: "1.0",
+			SegType:             "paragraph",
+			OTMF:                "jsonl",
+			AdminLang:           "en",
+			DataType:            "markdown",
+		},
+	}
+
+	sourceLangs := map[string]bool{}
+	var unit *tmxUnit
+	var unitKey string
+	for _, e := range m.Entries() {
+		sourceLangs[e.SourceLang] = true
+		// Entries 按源语言和原文排序，原文相同的条目相邻
+		if k := e.SourceLang + "\x00" + e.Source; unit == nil || k != unitKey {
+			doc.Units = append(doc.Units, tmxUnit{Variants: []tmxVariant{{Lang: tmxLang(e.SourceLang), Seg: e.Source}}})
+			unit, unitKey = &doc.Units[len(doc.Units)-1], k
+		}
+		if e.File != "" && (len(unit.Props) == 0 || unit.Props[len(unit.Props)-1].Value != e.File) {
+			unit.Props = append(unit.Props, tmxProp{Type: "x-file", Value: e.File})
+		}
+		unit.Variants = append(unit.Variants, tmxVariant{
+			Lang:       tmxLang(e.TargetLang),
+			ChangeDate: e.Updated.UTC().Format("20060102T150405Z"),
+			Seg:        e.Target,
+		})
+	}
+	// 所有条目的源语言相同时写入 srclang，否则按 TMX 规范使用 *all*
+	doc.Header.SrcLang = "*all*"
+	if len(sourceLangs) == 1 {
+		for lang := range sourceLangs {
+			doc.Header.SrcLang = tmxLang(lang)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("写入 TMX 失败: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// tmxLang 将语言代码转换为 TMX 使用的 BCP 47 形式。
+func tmxLang(code string) string {
+	return strings.ReplaceAll(code, "_", "-")
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+// budget 是整个运行共享的费用 / token 预算。
+// 每个文件在调用 API 之前按渲染后的 Prompt 预估用量并预留额度，完成后用实际用量结算。
+// 已用 + 进行中预留 + 下一个文件的预估超过上限时，预算被标记为耗尽，之后不再开始新的文件。
+type budget struct {
+	maxCost   float64 // 费用上限，0 表示不限制
+	maxTokens int     // token 总数上限，0 表示不限制
+
+	mu             sync.Mutex
+	spentCost      float64 // 已结算的实际费用
+	spentTokens    int     // 已结算的实际 token 数
+	reservedCost   float64 // 进行中的文件预留的费用
+	reservedTokens int     // 进行中的文件预留的 token 数
+	exhausted      bool    // 是否已有文件因预算不足而未开始
+}
+
+// reservation 是为单个文件预留的预算额度。
+type reservation struct {
+	tokens int
+	cost   float64
+	price  config.Price // 预估费用使用的价格 (文件所用路由规则的主提供商/模型)
+}
+
+// newBudget 根据配置创建预算。未设置任何上限时返回 nil，nil 预算的所有方法都不做限制。
+func newBudget(cfg *config.Config) *budget {
+	if cfg.MaxCost <= 0 && cfg.MaxTokensTotal <= 0 {
+		return nil
+	}
+	return &budget{maxCost: cfg.MaxCost, maxTokens: cfg.MaxTokensTotal}
+}
+
+// reserve 为预计消耗 estimate 的文件预留额度，按 price (文件所用提供商/模型的价格) 预估费用。
+// 剩余预算不足时返回 false 并将预算标记为耗尽。
+func (b *budget) reserve(estimate translator.Usage, price config.Price) (reservation, bool) {
+	if b == nil {
+		return reservation{}, true
+	}
+	r := reservation{
+		tokens: estimate.Total(),
+		cost:   price.Cost(estimate.InputTokens, 0, estimate.OutputTokens),
+		price:  price,
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exhausted {
+		return r, false
+	}
+	overTokens := b.maxTokens > 0 && b.spentTokens+b.reservedTokens+r.tokens > b.maxTokens
+	overCost := b.maxCost > 0 && b.spentCost+b.reservedCost+r.cost > b.maxCost
+	if overTokens || overCost {
+		b.exhausted = true
+		log.Printf("预算不足: 已用 %s, 进行中预留 %s, 下一个文件预计 %s, 上限 %s。停止分发新文件。\n",
+			b.format(b.spentTokens, b.spentCost), b.format(b.reservedTokens, b.reservedCost),
+			b.format(r.tokens, r.cost), b.format(b.maxTokens, b.maxCost))
+		return r, false
+	}
+	b.reservedTokens += r.tokens
+	b.reservedCost += r.cost
+	return r, true
+}
+
+// settle 释放文件的预留额度，并按实际用量和费用 (无论文件最终成功与否) 计入已用预算。
+// 费用由调用方按实际使用的提供商计算 (可能包含备用提供商)，无法计算时可以用 r.price 估算。
+func (b *budget) settle(r reservation, tokens int, cost float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reservedTokens -= r.tokens
+	b.reservedCost -= r.cost
+	b.spentTokens += tokens
+	b.spentCost += cost
+}
+
+// isExhausted 报告预算是否已耗尽。
+func (b *budget) isExhausted() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exhausted
+}
+
+// format 按已设置的上限格式化 token 数和费用，用于日志。
+func (b *budget) format(tokens int, cost float64) string {
+	switch {
+	case b.maxTokens > 0 && b.maxCost > 0:
+		return fmt.Sprintf("%d token / %.4f", tokens, cost)
+	case b.maxTokens > 0:
+		return fmt.Sprintf("%d token", tokens)
+	default:
+		return fmt.Sprintf("%.4f", cost)
+	}
+}
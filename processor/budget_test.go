@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+func TestNewBudget(t *testing.T) {
+	if b := newBudget(&config.Config{}); b != nil {
+		t.Fatalf("未设置上限时 newBudget() = %+v, 期望 nil", b)
+	}
+	// nil 预算不做任何限制
+	var b *budget
+	if _, ok := b.reserve(translator.Usage{InputTokens: 1 << 30}, config.Price{Input: 1000}); !ok || b.isExhausted() {
+		t.Error("nil 预算不应限制")
+	}
+	b.settle(reservation{}, 1, 1)
+}
+
+func TestBudget(t *testing.T) {
+	price := config.Price{Input: 1, Output: 2} // 每百万 token
+	usage := func(in, out int) translator.Usage { return translator.Usage{InputTokens: in, OutputTokens: out} }
+
+	// step 是一次预留或结算: settle 为 true 时按 tokens/cost 结算第 reservation 个预留
+	type step struct {
+		estimate    translator.Usage
+		wantOK      bool
+		settle      bool
+		reservation int
+		tokens      int
+		cost        float64
+	}
+	tests := []struct {
+		name          string
+		cfg           config.Config
+		steps         []step
+		wantSpent     int
+		wantReserved  int
+		wantExhausted bool
+	}{
+		{
+			name: "token 上限内",
+			cfg:  config.Config{MaxTokensTotal: 1000},
+			steps: []step{
+				{estimate: usage(300, 300), wantOK: true},
+				{estimate: usage(200, 200), wantOK: true},
+			},
+			wantReserved: 1000,
+		},
+		{
+			name: "进行中的预留计入上限",
+			cfg:  config.Config{MaxTokensTotal: 1000},
+			steps: []step{
+				{estimate: usage(300, 300), wantOK: true},
+				{estimate: usage(300, 300), wantOK: false},
+			},
+			wantReserved:  600,
+			wantExhausted: true,
+		},
+		{
+			name: "结算后按实际用量计入",
+			cfg:  config.Config{MaxTokensTotal: 1000},
+			steps: []step{
+				{estimate: usage(300, 300), wantOK: true},
+				{settle: true, reservation: 0, tokens: 100},
+				{estimate: usage(300, 300), wantOK: true},
+			},
+			wantSpent:    100,
+			wantReserved: 600,
+		},
+		{
+			name: "实际用量超出预估",
+			cfg:  config.Config{MaxTokensTotal: 1000},
+			steps: []step{
+				{estimate: usage(100, 100), wantOK: true},
+				{settle: true, reservation: 0, tokens: 900},
+				{estimate: usage(100, 100), wantOK: false},
+			},
+			wantSpent:     900,
+			wantExhausted: true,
+		},
+		{
+			name: "费用上限",
+			cfg:  config.Config{MaxCost: 1},
+			steps: []step{
+				{estimate: usage(200_000, 200_000), wantOK: true}, // 0.6
+				{estimate: usage(200_000, 200_000), wantOK: false},
+			},
+			wantReserved:  400_000,
+			wantExhausted: true,
+		},
+		{
+			name: "耗尽后不再预留",
+			cfg:  config.Config{MaxTokensTotal: 1000},
+			steps: []step{
+				{estimate: usage(2000, 0), wantOK: false},
+				{estimate: usage(1, 0), wantOK: false},
+			},
+			wantExhausted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBudget(&tt.cfg)
+			var reservations []reservation
+			for i, s := range tt.steps {
+				if s.settle {
+					b.settle(reservations[s.reservation], s.tokens, s.cost)
+					continue
+				}
+				r, ok := b.reserve(s.estimate, price)
+				if ok != s.wantOK {
+					t.Fatalf("第 %d 步: reserve() = %t, 期望 %t", i+1, ok, s.wantOK)
+				}
+				if ok {
+					reservations = append(reservations, r)
+				}
+			}
+			if b.spentTokens != tt.wantSpent || b.reservedTokens != tt.wantReserved || b.isExhausted() != tt.wantExhausted {
+				t.Errorf("已用 %d, 预留 %d, 耗尽 %t, 期望 %d, %d, %t", b.spentTokens, b.reservedTokens, b.isExhausted(), tt.wantSpent, tt.wantReserved, tt.wantExhausted)
+			}
+		})
+	}
+}
+
+func TestBudgetReservationCost(t *testing.T) {
+	b := newBudget(&config.Config{MaxCost: 10})
+	price := config.Price{Input: 3, Output: 15}
+	r, ok := b.reserve(translator.Usage{InputTokens: 1_000_000, OutputTokens: 100_000}, price)
+	if !ok || r.tokens != 1_100_000 || math.Abs(r.cost-4.5) > 1e-9 || r.price != price {
+		t.Fatalf("reserve() = %+v, %t", r, ok)
+	}
+	b.settle(r, 900_000, 4)
+	if b.reservedCost != 0 || b.reservedTokens != 0 || b.spentCost != 4 || b.spentTokens != 900_000 {
+		t.Errorf("结算后 预留 %v/%d, 已用 %v/%d", b.reservedCost, b.reservedTokens, b.spentCost, b.spentTokens)
+	}
+}
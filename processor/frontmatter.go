@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/markdown"
+	"Markdown-translator-go/translator"
+)
+
+// frontMatterMarkerRegex 匹配 front matter 值之间的分隔标记行，例如 @@FM001@@。
+var frontMatterMarkerRegex = regexp.MustCompile(`(?m)^[ \t]*@@FM(\d{3,})@@[ \t]*$`)
+
+// frontMatterDocument 将需要翻译的 front matter 值拼接为一个文档，每个值前有一行 @@FM001@@ 形式的标记，
+// 以便在一次请求中翻译所有值，并在译文中按标记拆分。
+func frontMatterDocument(values []string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("@@FM%03d@@\n%s", i+1, v)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// splitFrontMatterDocument 按标记将译文拆分为 n 个值。标记缺失、重复或顺序错乱时返回错误。
+func splitFrontMatterDocument(translated string, n int) ([]string, error) {
+	locs := frontMatterMarkerRegex.FindAllStringSubmatchIndex(translated, -1)
+	if len(locs) != n {
+		return nil, fmt.Errorf("译文中应有 %d 个 @@FM@@ 标记，实际为 %d 个", n, len(locs))
+	}
+	values := make([]string, n)
+	for i, loc := range locs {
+		if num, _ := strconv.Atoi(translated[loc[2]:loc[3]]); num != i+1 {
+			return nil, fmt.Errorf("译文中的 @@FM@@ 标记顺序错乱")
+		}
+		end := len(translated)
+		if i+1 < n {
+			end = locs[i+1][0]
+		}
+		values[i] = strings.TrimSpace(translated[loc[1]:end])
+	}
+	return values, nil
+}
+
+// translateFrontMatter 翻译 front matter 中配置的键 (cfg.FrontMatterKeys) 的值，返回替换后的 front matter。
+// 所有值在一次请求中翻译; 其余内容保持逐字节不变，结果会被重新解析校验 (见 markdown.FrontMatter.Render)。
+func translateFrontMatter(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, fm *markdown.FrontMatter, meter *usageMeter) (string, error) {
+	if len(fm.Unsupported) > 0 {
+		log.Printf("文件 %s 的 front matter 中 %s 的值写法不受支持，保持原样。\n", task.label(cfg), strings.Join(fm.Unsupported, ", "))
+	}
+	values := fm.Values()
+	if len(values) == 0 {
+		return fm.Text(), nil
+	}
+
+	translated, err := translateChunk(ctx, cfg, trans, task, frontMatterDocument(values), "", meter)
+	if err != nil {
+		return "", err
+	}
+	translatedValues, err := splitFrontMatterDocument(translated, len(values))
+	if err != nil {
+		return "", withStage(stageExtract, err)
+	}
+	rendered, err := fm.Render(translatedValues)
+	return rendered, withStage(stageExtract, err)
+}
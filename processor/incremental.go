@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/markdown"
+	"Markdown-translator-go/translator"
+	"Markdown-translator-go/utils"
+	"Markdown-translator-go/validator"
+)
+
+// incrementalContextUnits 是增量翻译时作为上下文提供给 LLM 的、变化段落之前的单元数。
+const incrementalContextUnits = 2
+
+// errNotIncremental 表示无法进行增量翻译 (例如旧原文与现有译文无法按单元对齐)，调用方应改为翻译整个文件。
+var errNotIncremental = errors.New("无法增量翻译")
+
+// translateFile 翻译文件内容。增量模式下，清单中有上次翻译时的原文、Prompt 未变化且目标文件存在时，
+// 只翻译有变化的段落 (见 translateChanges)，无法增量翻译时改为翻译整个文件 (见 translateAndValidate)。
+func translateFile(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, manifest *Manifest, targetPath, content string, meter *usageMeter) (string, []validator.Issue, error) {
+	previous, ok := manifest.Lookup(task.RelativePath)
+	if !cfg.Incremental || !ok || previous.Source == "" || previous.PromptHash != cfg.PromptHash {
+		return translateAndValidate(ctx, cfg, trans, task, content, meter)
+	}
+	oldTarget, err := utils.ReadFile(targetPath)
+	if err != nil {
+		log.Printf("文件 %s 无法增量翻译 (读取现有译文失败: %v)，翻译整个文件。\n", task.label(cfg), err)
+		return translateAndValidate(ctx, cfg, trans, task, content, meter)
+	}
+	translated, issues, err := translateChanges(ctx, cfg, trans, task, previous.Source, oldTarget, content, meter)
+	if errors.Is(err, errNotIncremental) {
+		log.Printf("文件 %s %v，翻译整个文件。\n", task.label(cfg), err)
+		return translateAndValidate(ctx, cfg, trans, task, content, meter)
+	}
+	return translated, issues, err
+}
+
+// translateChanges 增量翻译: 将新原文与上次翻译时的原文 oldSource 按单元 (见 markdown.Units) 比较，
+// 只翻译新增或修改的单元，未变化的单元直接沿用现有译文 oldTarget 中对应的单元 (包括人工修改)，删除的单元从译文中移除。
+// 相邻的变化单元合并为一次翻译，变化单元之前的原文作为上下文提供给 LLM (cfg.ChunkContext 时)。
+// oldSource 与 oldTarget 的单元数或类型不一致时返回 errNotIncremental。
+func translateChanges(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, oldSource, oldTarget, content string, meter *usageMeter) (string, []validator.Issue, error) {
+	if strings.Contains(content+oldSource+oldTarget, "\r\n") {
+		return "", nil, fmt.Errorf("%w: 不支持 CRLF 换行", errNotIncremental)
+	}
+
+	// front matter: 原文的 front matter 未变化时沿用现有译文中的 front matter，否则重新翻译
+	fm, body := markdown.SplitFrontMatter(content, cfg.FrontMatterKeys)
+	oldFM, oldBody := markdown.SplitFrontMatter(oldSource, nil)
+	targetFM, targetBody := markdown.SplitFrontMatter(oldTarget, nil)
+	header := ""
+	switch {
+	case fm == nil:
+	case oldFM != nil && targetFM != nil && oldFM.Text() == fm.Text():
+		header = targetFM.Text()
+	default:
+		var err error
+		if header, err = translateFrontMatter(ctx, cfg, trans, task, fm, meter); err != nil {
+			return "", nil, fmt.Errorf("翻译 front matter 失败: %w", err)
+		}
+	}
+
+	oldUnits, targetUnits := markdown.Units(oldBody), markdown.Units(targetBody)
+	if len(oldUnits) != len(targetUnits) {
+		return "", nil, fmt.Errorf("%w: 上次的原文有 %d 个单元，现有译文有 %d 个", errNotIncremental, len(oldUnits), len(targetUnits))
+	}
+	for i := range oldUnits {
+		if oldUnits[i].Kind != targetUnits[i].Kind || oldUnits[i].Level != targetUnits[i].Level {
+			return "", nil, fmt.Errorf("%w: 第 %d 个单元的类型不一致 (原文为%s，译文为%s)", errNotIncremental, i+1, oldUnits[i].Kind, targetUnits[i].Kind)
+		}
+	}
+
+	units := markdown.Units(body)
+	kept := matchUnits(oldUnits, units) // kept[i] 为新单元 i 对应的旧单元，-1 表示新增或修改
+	changed := 0
+	for _, k := range kept {
+		if k < 0 {
+			changed++
+		}
+	}
+	log.Printf("文件 %s 增量翻译: %d 个单元中有 %d 个新增或修改，%d 个删除。\n",
+		task.label(cfg), len(units), changed, len(oldUnits)-(len(units)-changed))
+
+	// 按新原文的顺序拼接: 单元之间的空行取自新原文，未变化的单元使用现有译文，连续的变化单元一起翻译
+	offsets := lineOffsets(body)
+	start := func(u markdown.Block) int { return offsets[u.StartLine] }
+	end := func(u markdown.Block) int { return offsets[u.StartLine] + len(u.Text) }
+	var b strings.Builder
+	var issues []validator.Issue
+	prev := 0 // 已输出到的字节位置
+	for i := 0; i < len(units); {
+		b.WriteString(body[prev:start(units[i])])
+		if kept[i] >= 0 {
+			b.WriteString(targetUnits[kept[i]].Text)
+			prev = end(units[i])
+			i++
+			continue
+		}
+		last := i
+		for last+1 < len(units) && kept[last+1] < 0 {
+			last++
+		}
+		previous := ""
+		if cfg.ChunkContext && i > 0 {
+			previous = body[start(units[max(i-incrementalContextUnits, 0)]):end(units[i-1])]
+		}
+		translated, runIssues, err := translateBody(ctx, cfg, trans, task, body[start(units[i]):end(units[last])], previous, meter)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(translated)
+		issues = append(issues, runIssues...)
+		prev = end(units[last])
+		i = last + 1
+	}
+	b.WriteString(body[prev:])
+	return header + b.String(), issues, nil
+}
+
+// lineOffsets 返回文本中每一行的起始字节位置。
+func lineOffsets(text string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// matchUnits 按最长公共子序列将新单元与旧单元中文本完全相同的单元配对。
+// 返回的切片中第 i 项为新单元 i 对应的旧单元下标，没有对应 (新增或修改) 时为 -1。
+func matchUnits(old, current []markdown.Block) []int {
+	n, m := len(old), len(current)
+	// lcs[i][j] 为 old[i:] 与 current[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if old[i].Text == current[j].Text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	kept := make([]int, m)
+	for j := range kept {
+		kept[j] = -1
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case old[i].Text == current[j].Text:
+			kept[j] = i
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return kept
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"slices"
+	"testing"
+
+	"Markdown-translator-go/markdown"
+)
+
+// blocks 将文本列表转换为只有 Text 的单元，便于构造测试用例。
+func blocks(texts ...string) []markdown.Block {
+	units := make([]markdown.Block, len(texts))
+	for i, text := range texts {
+		units[i] = markdown.Block{Text: text}
+	}
+	return units
+}
+
+func TestMatchUnits(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     []markdown.Block
+		current []markdown.Block
+		want    []int
+	}{
+		{"相同", blocks("a", "b", "c"), blocks("a", "b", "c"), []int{0, 1, 2}},
+		{"旧文档为空", nil, blocks("a", "b"), []int{-1, -1}},
+		{"新文档为空", blocks("a", "b"), nil, []int{}},
+		{"修改中间的单元", blocks("a", "b", "c"), blocks("a", "B", "c"), []int{0, -1, 2}},
+		{"插入单元", blocks("a", "c"), blocks("a", "b", "c"), []int{0, -1, 1}},
+		{"删除单元", blocks("a", "b", "c"), blocks("a", "c"), []int{0, 2}},
+		{"在开头插入", blocks("a", "b"), blocks("x", "a", "b"), []int{-1, 0, 1}},
+		{"移动的单元只保留一侧", blocks("a", "b", "c"), blocks("c", "a", "b"), []int{-1, 0, 1}},
+		{"重复的单元按顺序配对", blocks("a", "x", "a"), blocks("a", "a"), []int{0, 2}},
+		{"全部修改", blocks("a", "b"), blocks("c", "d"), []int{-1, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchUnits(tt.old, tt.current)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matchUnits() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineOffsets(t *testing.T) {
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"", []int{0}},
+		{"abc", []int{0}},
+		{"a\nbc\n", []int{0, 2, 5}},
+		{"\n\n", []int{0, 1, 2}},
+		{"中文\nb", []int{0, 7}},
+	}
+	for _, tt := range tests {
+		if got := lineOffsets(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("lineOffsets(%q) = %v, 期望 %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+	"Markdown-translator-go/validator"
+)
+
+// JournalFileName 是保存在目标目录中的任务日志文件名。
+const JournalFileName = ".mdtranslate-journal.jsonl"
+
+// 任务日志中记录的任务状态。
+const (
+	journalQueued     = "queued"      // 已加入队列，尚未开始
+	journalInProgress = "in_progress" // 正在处理 (程序在此状态下退出说明处理被中断)
+	journalDone       = "done"        // 已完成 (翻译并写入，或按规则跳过)
+	journalFailed     = "failed"      // 处理失败，Class 为失败类别
+)
+
+// JournalEntry 是任务日志 (JSON Lines) 中的一行，记录一个任务的一次状态变化。
+type JournalEntry struct {
+	RunID   string    `json:"run_id"`            // 写入该记录的运行的标识 (开始时间)
+	Time    time.Time `json:"time"`              // 记录写入时间
+	File    string    `json:"file"`              // 相对路径 (使用 "/" 分隔)
+	Lang    string    `json:"lang"`              // 目标语言代码
+	State   string    `json:"state"`             // queued、in_progress、done 或 failed
+	Stage   string    `json:"stage,omitempty"`   // 失败的阶段 (仅 failed，见 FailedFile)
+	Class   string    `json:"class,omitempty"`   // 失败类别 (仅 failed，见 config.FailureClasses)
+	Message string    `json:"message,omitempty"` // 失败原因 (仅 failed)
+}
+
+// journal 将任务的状态变化追加写入各目标目录中的任务日志，续跑 (cfg.Resume) 时据此跳过已完成的任务。
+// 不续跑时每次运行都从空的任务日志开始。nil 的 *journal 表示不记录任务日志 (例如空跑模式)，方法都可以安全调用。
+type journal struct {
+	runID string
+
+	mu    sync.Mutex
+	files map[string]*os.File              // 目标语言 -> 任务日志文件
+	last  map[TranslationTask]JournalEntry // 续跑时加载的每个任务的最后状态
+}
+
+// openJournal 打开所有目标语言的任务日志。续跑时先加载其中每个任务的最后状态并在文件末尾追加，
+// 否则清空原有内容。write 为 false (空跑模式) 时只加载，不写入。
+func openJournal(cfg *config.Config, start time.Time, write bool) (*journal, error) {
+	j := &journal{
+		runID: start.UTC().Format("20060102T150405Z"),
+		files: make(map[string]*os.File),
+		last:  make(map[TranslationTask]JournalEntry),
+	}
+	for _, lang := range cfg.TargetLanguages {
+		path := filepath.Join(cfg.TargetDirFor(lang), JournalFileName)
+		if cfg.Resume {
+			if err := j.load(path, lang); err != nil {
+				j.Close()
+				return nil, err
+			}
+		}
+		if !write {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			j.Close()
+			return nil, fmt.Errorf("创建任务日志目录失败: %w", err)
+		}
+		flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		if !cfg.Resume {
+			flags |= os.O_TRUNC
+		}
+		f, err := os.OpenFile(path, flags, 0644)
+		if err != nil {
+			j.Close()
+			return nil, fmt.Errorf("打开任务日志 %s 失败: %w", path, err)
+		}
+		j.files[lang] = f
+	}
+	return j, nil
+}
+
+// load 读取一个任务日志，后面的记录覆盖同一任务前面的记录。文件不存在时不做任何事。
+// 无法解析的行 (例如写入中断留下的不完整记录) 被跳过并记录警告。
+func (j *journal) load(path, lang string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("任务日志 %s 不存在，将处理所有文件。\n", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开任务日志失败: %w", err)
+	}
+	defer f.Close()
+
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.File == "" || e.Lang != lang {
+			skipped++
+			continue
+		}
+		j.last[TranslationTask{RelativePath: filepath.FromSlash(e.File), Lang: e.Lang}] = e
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取任务日志 %s 失败: %w", path, err)
+	}
+	if skipped > 0 {
+		log.Printf("警告: 任务日志 %s 中有 %d 行无法解析，已跳过。\n", path, skipped)
+	}
+	return nil
+}
+
+// previous 返回续跑时任务在上次运行中的最后状态。
+func (j *journal) previous(task TranslationTask) (JournalEntry, bool) {
+	if j == nil {
+		return JournalEntry{}, false
+	}
+	e, ok := j.last[task]
+	return e, ok
+}
+
+// targetExists 报告任务的目标文件是否存在。续跑时只有目标文件仍存在的已完成任务才会被跳过。
+func targetExists(cfg *config.Config, task TranslationTask) bool {
+	_, err := os.Stat(filepath.Join(cfg.TargetDirFor(task.Lang), task.RelativePath))
+	return err == nil
+}
+
+// record 写入任务的一次状态变化。failure 仅用于 failed 状态，记录失败的阶段、类别和原因。
+func (j *journal) record(task TranslationTask, state string, failure *FailedFile) error {
+	if j == nil {
+		return nil
+	}
+	entry := JournalEntry{
+		RunID: j.runID,
+		Time:  time.Now(),
+		File:  filepath.ToSlash(task.RelativePath),
+		Lang:  task.Lang,
+		State: state,
+	}
+	if failure != nil {
+		entry.Stage, entry.Class, entry.Message = failure.Stage, failure.Class, failure.Message
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化任务日志记录失败: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f := j.files[task.Lang]
+	if f == nil {
+		return nil
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入任务日志 %s 失败: %w", f.Name(), err)
+	}
+	return nil
+}
+
+// finish 根据处理结果写入任务的最终状态。被中断、未开始或预算不足的任务不写入，
+// 它们在任务日志中保持 in_progress 或 queued 状态，续跑时重新处理。
+func (j *journal) finish(task TranslationTask, o outcome, failure *FailedFile) error {
+	switch o {
+	case outcomeProcessed, outcomeSkipped:
+		return j.record(task, journalDone, nil)
+	case outcomeFailed:
+		return j.record(task, journalFailed, failure)
+	}
+	return nil
+}
+
+// Close 关闭所有任务日志文件。
+func (j *journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var firstErr error
+	for _, f := range j.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("关闭任务日志 %s 失败: %w", f.Name(), err)
+		}
+	}
+	j.files = nil
+	return firstErr
+}
+
+// failureClass 将处理失败的原因归为 config.FailureClasses 中的一类，续跑时按 cfg.ResumeRetry 决定是否重试。
+func failureClass(err error) string {
+	var validationErr *validator.Error
+	var apiErr *translator.APIError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &validationErr):
+		return config.FailureValidation
+	case errors.Is(err, translator.ErrOutputTruncated):
+		return config.FailureTruncated
+	case translator.IsRetryable(err):
+		return config.FailureTransient
+	case errors.As(err, &apiErr):
+		return config.FailureAPI
+	case errors.As(err, &pathErr):
+		return config.FailureIO
+	}
+	return config.FailureOther
+}
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+	"Markdown-translator-go/validator"
+)
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"结构校验", &validator.Error{}, config.FailureValidation},
+		{"输出被截断", fmt.Errorf("Claude: %w", translator.ErrOutputTruncated), config.FailureTruncated},
+		{"限流 (重试后)", &translator.RetryError{Attempts: 3, Err: &translator.APIError{StatusCode: 429}}, config.FailureTransient},
+		{"服务端错误", &translator.APIError{StatusCode: 503}, config.FailureTransient},
+		{"流式空闲超时", translator.ErrStreamIdle, config.FailureTransient},
+		{"认证失败", &translator.APIError{StatusCode: 401}, config.FailureAPI},
+		{"读写文件失败", withStage(stageWrite, &fs.PathError{Op: "open", Path: "a.md", Err: fs.ErrPermission}), config.FailureIO},
+		{"其他错误", errors.New("缺少 <translate> 标签"), config.FailureOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureClass(tt.err); got != tt.want {
+				t.Errorf("failureClass(%v) = %q, 期望 %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalResume(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{TargetDir: filepath.Join(dir, "out.{lang}"), TargetLanguages: []string{"zh", "ja"}}
+	done := TranslationTask{RelativePath: filepath.Join("docs", "a.md"), Lang: "zh"}
+	failed := TranslationTask{RelativePath: "b.md", Lang: "zh"}
+	interrupted := TranslationTask{RelativePath: "c.md", Lang: "ja"}
+
+	j, err := openJournal(cfg, time.Now(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []TranslationTask{done, failed, interrupted} {
+		if err := j.record(task, journalQueued, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.record(task, journalInProgress, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.finish(done, outcomeProcessed, nil); err != nil {
+		t.Fatal(err)
+	}
+	failure := &FailedFile{Stage: stageValidate, Class: config.FailureValidation, Message: "标题数不一致"}
+	if err := j.finish(failed, outcomeFailed, failure); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.finish(interrupted, outcomeNotStarted, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 续跑时加载每个任务的最后状态
+	cfg.Resume = true
+	j, err = openJournal(cfg, time.Now(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		task  TranslationTask
+		state string
+		class string
+	}{
+		{done, journalDone, ""},
+		{failed, journalFailed, config.FailureValidation},
+		{interrupted, journalInProgress, ""},
+	}
+	for _, tt := range tests {
+		e, ok := j.previous(tt.task)
+		if !ok || e.State != tt.state || e.Class != tt.class {
+			t.Errorf("previous(%v) = %+v, %t, 期望状态 %q、类别 %q", tt.task, e, ok, tt.state, tt.class)
+		}
+	}
+	if _, ok := j.previous(TranslationTask{RelativePath: "new.md", Lang: "zh"}); ok {
+		t.Error("任务日志中没有的任务不应有上次的状态")
+	}
+
+	// 已完成的任务只有在目标文件仍存在时才跳过
+	if targetExists(cfg, done) {
+		t.Error("目标文件不存在时 targetExists() 应返回 false")
+	}
+	target := filepath.Join(dir, "out.zh", "docs", "a.md")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("译文"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !targetExists(cfg, done) {
+		t.Error("目标文件存在时 targetExists() 应返回 true")
+	}
+}
@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"Markdown-translator-go/config"
+)
+
+// LedgerEntry 是用量账本 (JSON Lines) 中的一行。
+// 每个调用过 API 或命中翻译缓存的任务写入一条 type 为 "file" 的记录，运行结束时再写入一条 type 为 "run" 的合计记录，
+// 便于按源目录 (文档仓库) 和运行统计、分摊费用。
+type LedgerEntry struct {
+	Type              string    `json:"type"`               // "file" 或 "run"
+	RunID             string    `json:"run_id"`             // 本次运行的标识 (开始时间)
+	Time              time.Time `json:"time"`               // 记录写入时间
+	SourceDir         string    `json:"source_dir"`         // 源目录
+	File              string    `json:"file,omitempty"`     // 相对路径 (仅 file 记录)
+	Lang              string    `json:"lang,omitempty"`     // 目标语言代码 (run 记录中为空表示多个语言的合计)
+	Provider          string    `json:"provider,omitempty"` // 使用的 LLM 提供商
+	Model             string    `json:"model,omitempty"`    // 使用的模型
+	Status            string    `json:"status,omitempty"`   // 处理结果: ok、failed、interrupted (仅 file 记录)
+	Requests          int       `json:"requests"`           // 成功的 API 请求数
+	CacheHits         int       `json:"cache_hits"`         // 命中翻译缓存、未调用 API 的请求数
+	InputTokens       int       `json:"input_tokens"`
+	CachedInputTokens int       `json:"cached_input_tokens"`
+	OutputTokens      int       `json:"output_tokens"`
+	Cost              float64   `json:"cost"`               // 按价格表计算的费用
+	Currency          string    `json:"currency,omitempty"` // 价格表的货币单位
+	Priced            bool      `json:"priced"`             // 费用是否完整 (所有文件都有对应的价格)
+}
+
+// usageLedger 将用量记录追加写入账本文件。默认每个目标目录各有一个账本，
+// 指定 cfg.UsageLedger 时所有目标语言共用同一个文件。
+type usageLedger struct {
+	cfg   *config.Config
+	runID string
+
+	mu     sync.Mutex
+	files  map[string]*os.File    // 按路径打开的账本文件
+	byLang map[string]string      // 目标语言 -> 账本路径
+	totals map[string]UsageTotals // 账本路径 -> 本次运行写入该账本的合计
+	langs  map[string][]string    // 账本路径 -> 写入该账本的目标语言
+}
+
+// openUsageLedger 打开 (或创建) 所有目标语言的用量账本。
+func openUsageLedger(cfg *config.Config, start time.Time) (*usageLedger, error) {
+	l := &usageLedger{
+		cfg:    cfg,
+		runID:  start.UTC().Format("20060102T150405Z"),
+		files:  make(map[string]*os.File),
+		byLang: make(map[string]string),
+		totals: make(map[string]UsageTotals),
+		langs:  make(map[string][]string),
+	}
+	for _, lang := range cfg.TargetLanguages {
+		path := cfg.UsageLedger
+		if path == "" {
+			path = filepath.Join(cfg.TargetDirFor(lang), config.UsageLedgerFileName)
+		}
+		l.byLang[lang] = path
+		l.langs[path] = append(l.langs[path], lang)
+		if _, ok := l.files[path]; ok {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("创建用量账本目录失败: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("打开用量账本 %s 失败: %w", path, err)
+		}
+		l.files[path] = f
+	}
+	return l, nil
+}
+
+// Record 写入一个任务的用量记录。
+func (l *usageLedger) Record(f FileUsage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	path := l.byLang[f.Lang]
+	totals := l.totals[path]
+	totals.add(f)
+	l.totals[path] = totals
+	return l.writeLocked(path, LedgerEntry{
+		Type:              "file",
+		File:              filepath.ToSlash(f.File),
+		Lang:              f.Lang,
+		Provider:          f.Provider,
+		Model:             f.Model,
+		Status:            f.Status,
+		Requests:          f.Requests,
+		CacheHits:         f.CacheHits,
+		InputTokens:       f.InputTokens,
+		CachedInputTokens: f.CachedInputTokens,
+		OutputTokens:      f.OutputTokens,
+		Cost:              f.Cost,
+		Priced:            f.Priced,
+	})
+}
+
+// Close 向每个账本写入本次运行的合计记录并关闭文件。没有调用过 API 的账本不写入合计。
+func (l *usageLedger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var firstErr error
+	for path, f := range l.files {
+		if totals, ok := l.totals[path]; ok {
+			entry := LedgerEntry{
+				Type:              "run",
+				Provider:          l.cfg.LLMProvider,
+				Model:             l.cfg.LLMModel,
+				Requests:          totals.Requests,
+				CacheHits:         totals.CacheHits,
+				InputTokens:       totals.InputTokens,
+				CachedInputTokens: totals.CachedInputTokens,
+				OutputTokens:      totals.OutputTokens,
+				Cost:              totals.Cost,
+				Priced:            totals.Unpriced == 0,
+			}
+			if langs := l.langs[path]; len(langs) == 1 {
+				entry.Lang = langs[0]
+			}
+			if err := l.writeLocked(path, entry); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("关闭用量账本 %s 失败: %w", path, err)
+		}
+	}
+	l.files = nil
+	return firstErr
+}
+
+// writeLocked 补全公共字段并写入一行记录。调用方需持有 l.mu。
+func (l *usageLedger) writeLocked(path string, entry LedgerEntry) error {
+	entry.RunID = l.runID
+	entry.Time = time.Now()
+	entry.SourceDir = l.cfg.SourceDir
+	entry.Currency = l.cfg.Currency
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化用量记录失败: %w", err)
+	}
+	if _, err := l.files[path].Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入用量账本 %s 失败: %w", path, err)
+	}
+	return nil
+}
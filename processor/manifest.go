@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"Markdown-translator-go/utils"
+)
+
+// ManifestFileName 是保存在目标目录中的翻译清单文件名。
+const ManifestFileName = ".mdtranslate-manifest.json"
+
+// manifestSaveInterval 控制清单在运行过程中自动保存的最小间隔，避免程序崩溃时丢失过多记录。
+const manifestSaveInterval = 10 * time.Second
+
+// ManifestEntry 记录单个文件最近一次成功翻译时的输入指纹。
+type ManifestEntry struct {
+	SourceHash   string    `json:"source_hash"`     // 源文件内容的 SHA-256
+	PromptHash   string    `json:"prompt_hash"`     // Prompt 模板内容的 SHA-256
+	Provider     string    `json:"provider"`        // 生成译文的 LLM 提供商 (使用备用提供商时为实际使用的提供商，多个时以逗号分隔)
+	Model        string    `json:"model,omitempty"` // 生成译文的模型 (为空表示提供商默认模型，多个时以逗号分隔)
+	TranslatedAt time.Time `json:"translated_at"`   // 翻译完成时间
+	// 翻译时的原文 (仅增量模式记录)，下次翻译时与新原文比较，只翻译有变化的段落
+	Source string `json:"source,omitempty"`
+}
+
+// Manifest 是目标目录中的翻译清单，按相对路径记录每个文件的 ManifestEntry。
+// 通过比较源文件哈希和 Prompt 哈希，可以只重新翻译发生变化的文件。
+type Manifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]ManifestEntry `json:"files"` // 键为使用 "/" 分隔的相对路径
+
+	path     string     // 清单文件路径
+	mu       sync.Mutex // 保护 Files 及保存状态
+	dirty    bool       // 自上次保存后是否有新记录
+	lastSave time.Time  // 上次保存的时间
+}
+
+// LoadManifest 从 targetDir 加载翻译清单。清单文件不存在时返回一个空清单。
+func LoadManifest(targetDir string) (*Manifest, error) {
+	m := newManifest(targetDir)
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取翻译清单 %s 失败: %w", m.path, err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("解析翻译清单 %s 失败: %w", m.path, err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+// newManifest 创建一个位于 targetDir 的空清单。
+func newManifest(targetDir string) *Manifest {
+	return &Manifest{
+		Version:  1,
+		Files:    make(map[string]ManifestEntry),
+		path:     filepath.Join(targetDir, ManifestFileName),
+		lastSave: time.Now(),
+	}
+}
+
+// Lookup 返回指定相对路径的清单记录。
+func (m *Manifest) Lookup(relPath string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Files[filepath.ToSlash(relPath)]
+	return entry, ok
+}
+
+// Unchanged 报告文件自上次翻译以来源内容和 Prompt 是否都未发生变化。
+func (m *Manifest) Unchanged(relPath, sourceHash, promptHash string) bool {
+	entry, ok := m.Lookup(relPath)
+	return ok && entry.SourceHash == sourceHash && entry.PromptHash == promptHash
+}
+
+// Record 记录一个文件的翻译结果。距上次保存超过 manifestSaveInterval 时会顺带保存清单。
+func (m *Manifest) Record(relPath string, entry ManifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files[filepath.ToSlash(relPath)] = entry
+	m.dirty = true
+	if time.Since(m.lastSave) < manifestSaveInterval {
+		return nil
+	}
+	return m.saveLocked()
+}
+
+// Save 将清单写回目标目录 (没有新记录时不做任何事)。
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.saveLocked()
+}
+
+// saveLocked 将清单原子地写回文件 (见 utils.WriteFile)，避免中途退出留下损坏的清单。调用方需持有 m.mu。
+func (m *Manifest) saveLocked() error {
+	if !m.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化翻译清单失败: %w", err)
+	}
+	if err := utils.WriteFile(m.path, string(data), true); err != nil {
+		return fmt.Errorf("保存翻译清单失败: %w", err)
+	}
+	m.dirty = false
+	m.lastSave = time.Now()
+	return nil
+}
@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+	"Markdown-translator-go/utils"
+	"Markdown-translator-go/validator"
+)
+
+// 任务失败的阶段。
+const (
+	stageRead      = "read"      // 读取源文件
+	stageTranslate = "translate" // 渲染 Prompt 或调用 LLM
+	stageExtract   = "extract"   // 从 LLM 输出中提取译文并还原受保护的内容
+	stageValidate  = "validate"  // 译文结构校验
+	stageWrite     = "write"     // 检查或写入目标文件
+)
+
+// stageError 为错误标注失败的阶段，Error() 与原错误相同。
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string { return e.err.Error() }
+
+func (e *stageError) Unwrap() error { return e.err }
+
+// withStage 为 err 标注失败的阶段。err 已标注阶段时保留原有的阶段。
+func withStage(stage string, err error) error {
+	var stageErr *stageError
+	if err == nil || errors.As(err, &stageErr) {
+		return err
+	}
+	return &stageError{stage: stage, err: err}
+}
+
+// FailedFile 记录一个失败的任务，写入失败报告。
+type FailedFile struct {
+	File     string `json:"file"`               // 相对路径 (使用 "/" 分隔)
+	Lang     string `json:"lang"`               // 目标语言代码
+	Stage    string `json:"stage"`              // 失败的阶段: read、translate、extract、validate 或 write
+	Class    string `json:"class"`              // 失败类别 (见 config.FailureClasses)
+	Provider string `json:"provider,omitempty"` // 返回错误的 LLM 提供商 (仅 API 错误)
+	Message  string `json:"message"`            // 提供商返回的错误信息，非 API 错误时为完整的错误信息
+	Attempts int    `json:"attempts,omitempty"` // 最后一次请求的尝试次数 (含重试)
+}
+
+// newFailedFile 根据处理失败的原因 err 创建任务的失败记录。
+func newFailedFile(task TranslationTask, err error) FailedFile {
+	f := FailedFile{
+		File:     filepath.ToSlash(task.RelativePath),
+		Lang:     task.Lang,
+		Stage:    stageTranslate,
+		Class:    failureClass(err),
+		Attempts: translator.Attempts(err),
+	}
+	if err == nil {
+		return f
+	}
+	f.Message = err.Error()
+	var stageErr *stageError
+	var validationErr *validator.Error
+	var apiErr *translator.APIError
+	switch {
+	case errors.As(err, &stageErr):
+		f.Stage = stageErr.stage
+	case errors.As(err, &validationErr):
+		f.Stage = stageValidate
+	}
+	if errors.As(err, &apiErr) {
+		f.Provider, f.Message = apiErr.Provider, apiErr.Message
+	}
+	return f
+}
+
+// FailureReport 是失败报告 (JSON) 的内容，可以通过 --files-from 重新运行其中的文件。
+type FailureReport struct {
+	Time      time.Time    `json:"time"`       // 写入时间
+	SourceDir string       `json:"source_dir"` // 源目录
+	Failed    []FailedFile `json:"failed"`     // 失败的任务，按路径和目标语言排序
+}
+
+// FailureReportPaths 返回各目标语言的失败报告路径 (与 cfg.TargetLanguages 对应)。
+// 指定 cfg.FailedReport 时所有目标语言共用同一个文件。
+func FailureReportPaths(cfg *config.Config) []string {
+	paths := make([]string, len(cfg.TargetLanguages))
+	for i, lang := range cfg.TargetLanguages {
+		paths[i] = cfg.FailedReport
+		if paths[i] == "" {
+			paths[i] = filepath.Join(cfg.TargetDirFor(lang), config.FailureReportFileName)
+		}
+	}
+	return paths
+}
+
+// writeFailureReports 将本次运行失败的任务写入失败报告 (原子写入，覆盖上次的报告)。
+// 没有失败的任务时同样写入 (空列表)，避免 --files-from 重新运行已经成功的文件。
+func writeFailureReports(cfg *config.Config, stats *Stats) error {
+	failed := stats.FailedFiles()
+	reports := make(map[string]*FailureReport)
+	var order []string
+	for i, path := range FailureReportPaths(cfg) {
+		if _, ok := reports[path]; !ok {
+			reports[path] = &FailureReport{Time: time.Now(), SourceDir: cfg.SourceDir, Failed: []FailedFile{}}
+			order = append(order, path)
+		}
+		lang := cfg.TargetLanguages[i]
+		for _, f := range failed {
+			if f.Lang == lang {
+				reports[path].Failed = append(reports[path].Failed, f)
+			}
+		}
+	}
+	for _, path := range order {
+		data, err := json.MarshalIndent(reports[path], "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化失败报告失败: %w", err)
+		}
+		if err := utils.WriteFile(path, string(data)+"\n", true); err != nil {
+			return fmt.Errorf("保存失败报告失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadFileList 读取 --files-from 指定的文件列表，返回其中列出的任务 (去重，保持原有顺序)。
+// 文件可以是失败报告 (JSON，见 FailureReport)，此时每个任务的 Lang 为失败的目标语言;
+// 也可以是每行一个相对路径的文本文件 (忽略空行和 # 开头的注释)，此时 Lang 为空，表示所有目标语言。
+func LoadFileList(path string) ([]TranslationTask, error) {
+	content, err := utils.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var listed []TranslationTask
+	if strings.HasPrefix(strings.TrimSpace(content), "{") {
+		var report FailureReport
+		if err := json.Unmarshal([]byte(content), &report); err != nil {
+			return nil, fmt.Errorf("解析失败报告 %s 失败: %w", path, err)
+		}
+		for _, f := range report.Failed {
+			if f.Lang == "" {
+				return nil, fmt.Errorf("失败报告 %s 中的 %s 缺少目标语言", path, f.File)
+			}
+			listed = append(listed, TranslationTask{RelativePath: f.File, Lang: f.Lang})
+		}
+	} else {
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			listed = append(listed, TranslationTask{RelativePath: line})
+		}
+	}
+
+	seen := make(map[TranslationTask]bool, len(listed))
+	tasks := make([]TranslationTask, 0, len(listed))
+	for _, t := range listed {
+		t.RelativePath = filepath.Clean(filepath.FromSlash(t.RelativePath))
+		if !seen[t] {
+			seen[t] = true
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+// sortFailedFiles 按路径和目标语言排序失败记录。
+func sortFailedFiles(files []FailedFile) {
+	slices.SortFunc(files, func(a, b FailedFile) int {
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Lang, b.Lang)
+	})
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+	"Markdown-translator-go/validator"
+)
+
+func TestLoadFileList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []TranslationTask
+		wantErr bool
+	}{
+		{
+			name:    "文本文件",
+			content: "# 需要重新翻译的文件\na.md\n\n  docs/b.md  \n./c.md\na.md\n",
+			want: []TranslationTask{
+				{RelativePath: "a.md"},
+				{RelativePath: filepath.Join("docs", "b.md")},
+				{RelativePath: "c.md"},
+			},
+		},
+		{
+			name: "失败报告只包含失败的目标语言",
+			content: `{"time": "2026-01-01T00:00:00Z", "source_dir": "src", "failed": [
+				{"file": "docs/a.md", "lang": "zh", "stage": "translate", "class": "transient", "message": "x"},
+				{"file": "docs/a.md", "lang": "ja", "stage": "validate", "class": "validation", "message": "y"},
+				{"file": "b.md", "lang": "ja", "stage": "write", "class": "io", "message": "z"},
+				{"file": "b.md", "lang": "ja", "stage": "write", "class": "io", "message": "z"}
+			]}`,
+			want: []TranslationTask{
+				{RelativePath: filepath.Join("docs", "a.md"), Lang: "zh"},
+				{RelativePath: filepath.Join("docs", "a.md"), Lang: "ja"},
+				{RelativePath: "b.md", Lang: "ja"},
+			},
+		},
+		{
+			name:    "空的失败报告",
+			content: `{"failed": []}`,
+			want:    []TranslationTask{},
+		},
+		{
+			name:    "失败报告缺少目标语言",
+			content: `{"failed": [{"file": "a.md"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "无法解析的失败报告",
+			content: `{"failed": [`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "list")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadFileList(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFileList() 错误 = %v, 期望错误: %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("LoadFileList() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadFileList(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("文件不存在时 LoadFileList() 应返回错误")
+	}
+}
+
+func TestNewFailedFile(t *testing.T) {
+	task := TranslationTask{RelativePath: filepath.Join("docs", "a.md"), Lang: "zh"}
+	apiErr := &translator.APIError{Provider: "OpenAI", StatusCode: 429, Message: "rate limited"}
+	tests := []struct {
+		name string
+		err  error
+		want FailedFile
+	}{
+		{
+			name: "重试后仍失败的 API 错误",
+			err:  &translator.RetryError{Attempts: 3, Err: apiErr},
+			want: FailedFile{Stage: stageTranslate, Class: config.FailureTransient, Provider: "OpenAI", Message: "rate limited", Attempts: 3},
+		},
+		{
+			name: "结构校验失败",
+			err:  &validator.Error{Issues: []validator.Issue{{Check: "标题", Message: "数量不一致"}}},
+			want: FailedFile{Stage: stageValidate, Class: config.FailureValidation, Attempts: 1},
+		},
+		{
+			name: "写入失败",
+			err:  withStage(stageWrite, errors.New("磁盘已满")),
+			want: FailedFile{Stage: stageWrite, Class: config.FailureOther, Message: "磁盘已满", Attempts: 1},
+		},
+		{
+			name: "已标注的阶段不被覆盖",
+			err:  withStage(stageWrite, withStage(stageRead, errors.New("x"))),
+			want: FailedFile{Stage: stageRead, Class: config.FailureOther, Message: "x", Attempts: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newFailedFile(task, tt.err)
+			tt.want.File, tt.want.Lang = "docs/a.md", "zh"
+			if tt.want.Message == "" {
+				tt.want.Message = tt.err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("newFailedFile() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/glossary"
+	"Markdown-translator-go/markdown"
+	"Markdown-translator-go/memory"
+	"Markdown-translator-go/translator"
+	"Markdown-translator-go/utils"
+	"Markdown-translator-go/validator"
+)
+
+// translateAndValidate 翻译文档，并将正文的译文与原文进行结构校验 (见 validator.Compare)。
+// 文档开头的 front matter 与正文分开处理: 只翻译 cfg.FrontMatterKeys 中的键的值 (见 translateFrontMatter)。
+func translateAndValidate(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, content string, meter *usageMeter) (string, []validator.Issue, error) {
+	fm, body := markdown.SplitFrontMatter(content, cfg.FrontMatterKeys)
+	if fm == nil {
+		return translateBody(ctx, cfg, trans, task, content, "", meter)
+	}
+
+	header, err := translateFrontMatter(ctx, cfg, trans, task, fm, meter)
+	if err != nil {
+		return "", nil, fmt.Errorf("翻译 front matter 失败: %w", err)
+	}
+	// front matter 与正文之间的空行原样保留
+	text := strings.TrimLeft(body, "\r\n")
+	gap := body[:len(body)-len(text)]
+	if strings.TrimSpace(text) == "" {
+		return header + body, nil, nil
+	}
+	translated, issues, err := translateBody(ctx, cfg, trans, task, text, "", meter)
+	if err != nil {
+		return "", nil, err
+	}
+	return header + gap + translated, issues, nil
+}
+
+// translateBody 翻译正文 (previous 为提供给第一个片段的上下文，可以为空)，并将译文与原文进行结构校验和术语检查。
+// 按 cfg.ValidatePolicy 处理不一致: warn 返回译文及不一致列表，由调用方记录警告;
+// fail 返回 *validator.Error; retry 重新翻译一次，仍不一致时返回 *validator.Error。
+func translateBody(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, content, previous string, meter *usageMeter) (string, []validator.Issue, error) {
+	translated, err := translateDocument(ctx, cfg, trans, task, content, previous, meter)
+	if err != nil || cfg.ValidatePolicy == validator.PolicyOff {
+		return translated, nil, err
+	}
+
+	issues := checkTranslation(cfg, task, content, translated)
+	if len(issues) == 0 {
+		return translated, nil, nil
+	}
+	switch cfg.ValidatePolicy {
+	case validator.PolicyWarn:
+		return translated, issues, nil
+	case validator.PolicyRetry:
+		log.Printf("文件 %s 的译文未通过结构校验 (%d 处不一致)，重新翻译一次。\n", task.label(cfg), len(issues))
+		// 跳过翻译缓存，否则会再次得到同一份未通过校验的译文
+		if translated, err = translateDocument(translator.WithCacheRefresh(ctx), cfg, trans, task, content, previous, meter); err != nil {
+			return "", nil, err
+		}
+		if issues = checkTranslation(cfg, task, content, translated); len(issues) == 0 {
+			return translated, nil, nil
+		}
+	}
+	return "", nil, &validator.Error{Issues: issues}
+}
+
+// checkTranslation 比较原文与译文的结构 (见 validator.Compare)，并检查原文中出现的术语在译文中是否使用了术语表规定的译法。
+func checkTranslation(cfg *config.Config, task TranslationTask, content, translated string) []validator.Issue {
+	issues := validator.Compare(content, translated)
+	for _, v := range cfg.Glossary.Check(content, translated, task.Lang) {
+		issues = append(issues, validator.Issue{Check: "术语", Message: v.String()})
+	}
+	return issues
+}
+
+// translateDocument 将单个文档翻译为 task.Lang 并返回提取后的译文。
+// 超过 cfg.ChunkMaxTokens 的文档会在标题/段落边界切分为多个片段 (见 markdown.Chunk)，
+// 各片段分别调用 LLM (可按 cfg.ChunkConcurrency 并行)，再按原顺序拼接为完整译文:
+// 片段之间的空行、文档首尾的空行和结尾的换行取自原文 (见 markdown.Chunks.Join)。
+// previous 是文档之前的原文 (增量翻译时为变化段落之前的内容)，作为上下文提供给第一个片段。
+func translateDocument(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, content, previous string, meter *usageMeter) (string, error) {
+	chunks := markdown.Chunk(content, cfg.ChunkMaxTokens, translator.EstimateTokens)
+	if len(chunks.Texts) == 1 {
+		translated, err := translateChunk(ctx, cfg, trans, task, chunks.Texts[0], previous, meter)
+		if err != nil {
+			return "", err
+		}
+		return chunks.Join([]string{translated}), nil
+	}
+	log.Printf("文件 %s 较大，已切分为 %d 个片段进行翻译。\n", task.label(cfg), len(chunks.Texts))
+
+	results := make([]string, len(chunks.Texts))
+	errs := make([]error, len(chunks.Texts))
+	sem := make(chan struct{}, max(cfg.ChunkConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks.Texts {
+		// 上一个片段的原文作为上下文提供给 LLM，帮助保持术语和语气的连贯 (使用原文使得片段之间可以并行)
+		chunkContext := previous
+		if cfg.ChunkContext && i > 0 {
+			chunkContext = chunks.Texts[i-1]
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = translateChunk(ctx, cfg, trans, task, chunk, chunkContext, meter)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return "", fmt.Errorf("片段 %d/%d: %w", i+1, len(chunks.Texts), err)
+		}
+	}
+	return chunks.Join(results), nil
+}
+
+// translateChunk 渲染 Prompt、调用 LLM 并从响应中提取 <translate> 标签内的译文。
+// 启用 cfg.Protect 时，代码、URL 和 {{placeholder}} 在发送前被替换为占位符，提取译文后再还原并校验。
+// 请求成功后其 token 用量计入 meter (即使随后提取或还原失败，这次请求也已产生费用)。
+// 来自翻译缓存的输出无法提取或还原时，跳过缓存重新请求一次，避免损坏的条目使该片段永远失败。
+// 片段中除受保护内容 (代码、从翻译记忆复用的段落等) 外没有需要翻译的内容时，直接还原，不调用 LLM。
+func translateChunk(ctx context.Context, cfg *config.Config, trans translator.Translator, task TranslationTask, content, previous string, meter *usageMeter) (string, error) {
+	protected, protection := protectChunk(cfg, task, content)
+	meter.addReused(protection.Reused())
+	if protection.Len() > 0 && markdown.OnlySentinels(protected) {
+		return protection.Restore(protected)
+	}
+	prompt, err := renderPrompt(cfg, task, protected, protection, content, previous)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := trans.Translate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	meter.add(result)
+
+	translated, err := extractAndRestore(result.Text, protection)
+	if err != nil && result.Cached {
+		log.Printf("缓存中的译文无法使用 (%v)，跳过缓存重新翻译。\n", err)
+		if result, err = trans.Translate(translator.WithCacheRefresh(ctx), prompt); err != nil {
+			return "", err
+		}
+		meter.add(result)
+		translated, err = extractAndRestore(result.Text, protection)
+	}
+	return translated, err
+}
+
+// extractAndRestore 从 LLM 的原始输出中提取译文并还原受保护的内容。
+func extractAndRestore(output string, protection *markdown.Protection) (string, error) {
+	// 从 LLM 的原始响应中提取 <translate> 标签内的内容
+	// ExtractTranslation 内部已经记录了详细的错误信息和预览。
+	translated, err := utils.ExtractTranslation(output)
+	if err != nil {
+		return "", withStage(stageExtract, err)
+	}
+
+	// 还原受保护的内容; 占位符缺失、重复或被改动时该文件视为失败
+	restored, err := protection.Restore(translated)
+	return restored, withStage(stageExtract, err)
+}
+
+// protectChunk 在启用 cfg.Protect 时将片段中的受保护内容 (按文件扩展名确定的格式) 替换为占位符，
+// 使用翻译记忆时，精确匹配的段落也被替换为占位符，还原时得到记忆中的译文 (未启用内容保护时同样复用)。
+// 返回替换后的文本和用于在提取译文后还原的 Protection。
+func protectChunk(cfg *config.Config, task TranslationTask, content string) (string, *markdown.Protection) {
+	var reuse func(string) (string, bool)
+	if cfg.Memory != nil {
+		reuse = func(segment string) (string, bool) {
+			return cfg.Memory.Lookup(cfg.SourceLang, task.Lang, segment)
+		}
+	}
+	switch {
+	case cfg.Protect:
+		return markdown.Protect(content, markdown.FormatOf(task.RelativePath), reuse)
+	case reuse != nil:
+		return markdown.Reuse(content, reuse)
+	}
+	return content, &markdown.Protection{}
+}
+
+// renderPrompt 渲染单个片段的 Prompt。protected 是 protectChunk 处理后的片段，original 是处理前的原文。
+// 只有片段中 (受保护内容之外) 出现的术语才会注入 Prompt; 翻译记忆中与原文段落相似的条目作为参考译文注入。
+func renderPrompt(cfg *config.Config, task TranslationTask, protected string, protection *markdown.Protection, original, previous string) (string, error) {
+	return translator.RenderPrompt(cfg.PromptTemplate, translator.PromptData{
+		Content:    protected,
+		Context:    previous,
+		Protected:  protection.Len() > 0,
+		Glossary:   glossary.Format(cfg.Glossary.Find(protected, task.Lang)),
+		References: memory.Format(cfg.Memory.Similar(cfg.SourceLang, task.Lang, original, cfg.MemoryFuzzy)),
+		SourceLang: config.LanguageName(cfg.SourceLang),
+		TargetLang: config.LanguageName(task.Lang),
+	})
+}
+
+// estimateUsage 在调用 API 之前预估翻译一个文档的 token 用量，用于预算检查。
+// 按与 translateDocument 相同的方式切分并渲染每个片段的 Prompt: 输入为 Prompt 的估算 token 数，输出按与原文片段等长估算。
+// front matter 中需要翻译的值按一次额外的请求估算; 没有需要翻译的内容 (例如完全从翻译记忆复用) 的片段不计。
+func estimateUsage(cfg *config.Config, task TranslationTask, content string) (translator.Usage, error) {
+	var usage translator.Usage
+	add := func(chunk, previous string) error {
+		protected, protection := protectChunk(cfg, task, chunk)
+		if protection.Len() > 0 && markdown.OnlySentinels(protected) {
+			return nil
+		}
+		prompt, err := renderPrompt(cfg, task, protected, protection, chunk, previous)
+		if err != nil {
+			return err
+		}
+		usage.InputTokens += translator.EstimateTokens(prompt)
+		usage.OutputTokens += translator.EstimateTokens(chunk)
+		return nil
+	}
+
+	if fm, body := markdown.SplitFrontMatter(content, cfg.FrontMatterKeys); fm != nil {
+		if values := fm.Values(); len(values) > 0 {
+			if err := add(frontMatterDocument(values), ""); err != nil {
+				return usage, err
+			}
+		}
+		if content = strings.TrimLeft(body, "\r\n"); strings.TrimSpace(content) == "" {
+			return usage, nil
+		}
+	}
+	chunks := markdown.Chunk(content, cfg.ChunkMaxTokens, translator.EstimateTokens)
+	for i, chunk := range chunks.Texts {
+		previous := ""
+		if cfg.ChunkContext && i > 0 {
+			previous = chunks.Texts[i-1]
+		}
+		if err := add(chunk, previous); err != nil {
+			return usage, err
+		}
+	}
+	return usage, nil
+}
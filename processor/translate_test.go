@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+// upperTranslator 将 Prompt (即片段原文) 转为大写作为译文返回。
+type upperTranslator struct{}
+
+func (upperTranslator) Translate(_ context.Context, prompt string) (*translator.Result, error) {
+	return &translator.Result{Text: "<translate>" + strings.ToUpper(prompt) + "</translate>"}, nil
+}
+
+func TestTranslateDocumentKeepsGaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int
+		content   string
+		want      string
+	}{
+		{
+			name:    "不切分",
+			content: "# a\n\ntext\n",
+			want:    "# A\n\nTEXT\n",
+		},
+		{
+			name:      "切分后按原文的空行拼接",
+			chunkSize: 3,
+			content:   "\n# a\n\naaaa aaaa aaaa\n\n\n\nbbbb bbbb bbbb\n\n",
+			want:      "\n# A\n\nAAAA AAAA AAAA\n\n\n\nBBBB BBBB BBBB\n\n",
+		},
+		{
+			name:      "没有结尾的换行",
+			chunkSize: 3,
+			content:   "aaaa aaaa aaaa\n\nbbbb bbbb bbbb",
+			want:      "AAAA AAAA AAAA\n\nBBBB BBBB BBBB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				PromptTemplate:   template.Must(template.New("prompt").Parse("{{.Content}}")),
+				ChunkMaxTokens:   tt.chunkSize,
+				ChunkConcurrency: 2,
+			}
+			task := TranslationTask{RelativePath: "doc.md", Lang: "en"}
+			got, err := translateDocument(context.Background(), cfg, upperTranslator{}, task, tt.content, "", newUsageMeter(cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("translateDocument() = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package processor
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+// producer 标识生成译文的提供商和模型。
+type producer struct {
+	provider string
+	model    string
+}
+
+// label 返回 "提供商/模型" 形式的标识。
+func (p producer) label() string {
+	return config.ProviderProfile{Provider: p.provider, Model: p.model}.Label()
+}
+
+// usageMeter 累计单个翻译任务 (包括所有片段和校验重试) 的 token 用量。片段可能并行翻译，因此需要加锁。
+// 启用备用提供商时，各片段可能由不同的提供商完成，因此用量按提供商/模型分别记录，以便按各自的价格计算费用。
+type usageMeter struct {
+	primary producer // 主提供商，结果未注明提供商时使用
+
+	mu         sync.Mutex
+	requests   int
+	cacheHits  int
+	reused     int // 从翻译记忆复用译文的段落数
+	usage      translator.Usage
+	byProducer map[producer]translator.Usage
+	producers  []producer // 生成过结果的提供商/模型 (按首次出现的顺序)
+}
+
+// newUsageMeter 创建一个以 cfg 中的主提供商为默认值的 usageMeter。
+func newUsageMeter(cfg *config.Config) *usageMeter {
+	return &usageMeter{
+		primary:    producer{cfg.LLMProvider, cfg.LLMModel},
+		byProducer: make(map[producer]translator.Usage),
+	}
+}
+
+// add 记录一次成功请求的用量。命中翻译缓存的结果只计入命中次数，不计入请求数和用量。
+func (m *usageMeter) add(r *translator.Result) {
+	p := m.primary
+	if r.Provider != "" {
+		p = producer{r.Provider, r.Model}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !slices.Contains(m.producers, p) {
+		m.producers = append(m.producers, p)
+	}
+	if r.Cached {
+		m.cacheHits++
+		return
+	}
+	m.requests++
+	m.usage = m.usage.Add(r.Usage)
+	m.byProducer[p] = m.byProducer[p].Add(r.Usage)
+}
+
+// addReused 记录从翻译记忆复用译文的段落数。
+func (m *usageMeter) addReused(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reused += n
+}
+
+// cost 按各提供商/模型的价格计算费用。任一有用量的提供商/模型缺少价格时 priced 为 false。
+func (m *usageMeter) cost(cfg *config.Config) (cost float64, priced bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	priced = true
+	for p, u := range m.byProducer {
+		price, ok := cfg.PriceFor(p.provider, p.model)
+		if !ok {
+			priced = false
+			continue
+		}
+		cost += price.Cost(u.InputTokens, u.CachedInputTokens, u.OutputTokens)
+	}
+	return cost, priced
+}
+
+// producedBy 返回生成译文的提供商和模型 (多个时以逗号分隔)，以及是否使用了备用提供商。
+// 没有成功的请求时返回主提供商。
+func (m *usageMeter) producedBy() (provider, model string, fallback bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.producers) == 0 {
+		return m.primary.provider, m.primary.model, false
+	}
+	providers := make([]string, len(m.producers))
+	models := make([]string, len(m.producers))
+	for i, p := range m.producers {
+		providers[i], models[i] = p.provider, p.model
+		fallback = fallback || p != m.primary
+	}
+	return strings.Join(providers, ","), strings.Join(models, ","), fallback
+}
+
+// label 返回生成译文的提供商/模型标识 (多个时以逗号分隔)，用于报告。
+func (m *usageMeter) label() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	labels := make([]string, len(m.producers))
+	for i, p := range m.producers {
+		labels[i] = p.label()
+	}
+	return strings.Join(labels, ", ")
+}
+
+// UsageTotals 是一组请求的 token 用量和费用合计。
+type UsageTotals struct {
+	Requests  int // 成功的 API 请求数
+	CacheHits int // 命中翻译缓存、未调用 API 的请求数
+	translator.Usage
+	Cost     float64 // 按价格表计算的费用
+	Unpriced int     // 价格表中没有对应价格、因而未计入费用的文件数
+}
+
+// add 将一个文件的用量计入合计。
+func (t *UsageTotals) add(f FileUsage) {
+	t.Requests += f.Requests
+	t.CacheHits += f.CacheHits
+	t.Usage = t.Usage.Add(f.Usage)
+	t.Cost += f.Cost
+	if !f.Priced {
+		t.Unpriced++
+	}
+}
+
+// FileUsage 是单个翻译任务的 token 用量和费用。
+type FileUsage struct {
+	File      string // 相对路径
+	Lang      string // 目标语言代码
+	Provider  string // 生成译文的 LLM 提供商 (多个时以逗号分隔)
+	Model     string // 生成译文的模型 (为空表示提供商默认模型，多个时以逗号分隔)
+	Fallback  bool   // 是否使用了备用提供商
+	Requests  int    // 成功的 API 请求数
+	CacheHits int    // 命中翻译缓存、未调用 API 的请求数
+	translator.Usage
+	Cost   float64 // 费用 (Priced 为 false 时为 0)
+	Priced bool    // 价格表中是否有对应的价格
+	Status string  // 处理结果: ok、failed 或 interrupted
+}
+
+// newFileUsage 根据任务的用量和价格表计算该文件的费用。
+func newFileUsage(cfg *config.Config, task TranslationTask, m *usageMeter, o outcome) FileUsage {
+	f := FileUsage{
+		File:      task.RelativePath,
+		Lang:      task.Lang,
+		Requests:  m.requests,
+		CacheHits: m.cacheHits,
+		Usage:     m.usage,
+		Status:    o.status(),
+	}
+	f.Provider, f.Model, f.Fallback = m.producedBy()
+	f.Cost, f.Priced = m.cost(cfg)
+	return f
+}
+
+// status 返回用于用量账本的处理结果名称。
+func (o outcome) status() string {
+	switch o {
+	case outcomeProcessed:
+		return "ok"
+	case outcomeInterrupted:
+		return "interrupted"
+	default:
+		return "failed"
+	}
+}
+
+// recordUsage 将一个文件的用量计入合计和对应语言的合计。
+func (s *Stats) recordUsage(f FileUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage.add(f)
+	lang := s.langUsage[f.Lang]
+	lang.add(f)
+	s.langUsage[f.Lang] = lang
+	s.files = append(s.files, f)
+}
+
+// Usage 返回本次运行所有目标语言的用量合计。
+func (s *Stats) Usage() UsageTotals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage
+}
+
+// LanguageUsage 返回指定目标语言的用量合计。
+func (s *Stats) LanguageUsage(lang string) UsageTotals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.langUsage[lang]
+}
+
+// FileUsages 返回每个调用过 API 的任务的用量，按费用 (其次按 token 总数) 从高到低排序。
+func (s *Stats) FileUsages() []FileUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := slices.Clone(s.files)
+	slices.SortStableFunc(files, func(a, b FileUsage) int {
+		if c := cmp.Compare(b.Cost, a.Cost); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Total(), a.Total())
+	})
+	return files
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/translator"
+)
+
+func TestUsageMeterProducers(t *testing.T) {
+	cfg := &config.Config{
+		LLMProvider: "gemini",
+		LLMModel:    "gemini-1.5-pro",
+		Prices: map[string]config.Price{
+			"gemini/gemini-1.5-pro": {Input: 1, Output: 2},
+			"claude":                {Input: 10, Output: 20},
+		},
+	}
+	usage := translator.Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+	tests := []struct {
+		name         string
+		results      []translator.Result
+		wantProvider string
+		wantModel    string
+		wantFallback bool
+		wantCost     float64
+		wantPriced   bool
+	}{
+		{
+			name:         "没有成功的请求时为主提供商",
+			wantProvider: "gemini", wantModel: "gemini-1.5-pro", wantPriced: true,
+		},
+		{
+			name:         "未注明提供商的结果来自主提供商",
+			results:      []translator.Result{{Usage: usage}},
+			wantProvider: "gemini", wantModel: "gemini-1.5-pro", wantCost: 3, wantPriced: true,
+		},
+		{
+			name:         "备用提供商",
+			results:      []translator.Result{{Provider: "claude", Model: "claude-3-5-haiku", Usage: usage}},
+			wantProvider: "claude", wantModel: "claude-3-5-haiku", wantFallback: true, wantCost: 30, wantPriced: true,
+		},
+		{
+			name: "多个提供商按首次出现的顺序记录，按各自的价格计费",
+			results: []translator.Result{
+				{Provider: "gemini", Model: "gemini-1.5-pro", Usage: usage},
+				{Provider: "claude", Model: "claude-3-5-haiku", Usage: usage},
+				{Provider: "gemini", Model: "gemini-1.5-pro", Usage: usage},
+			},
+			wantProvider: "gemini,claude", wantModel: "gemini-1.5-pro,claude-3-5-haiku", wantFallback: true, wantCost: 36, wantPriced: true,
+		},
+		{
+			name:         "缺少价格",
+			results:      []translator.Result{{Provider: "ollama", Usage: usage}},
+			wantProvider: "ollama", wantFallback: true, wantPriced: false,
+		},
+		{
+			name:         "命中缓存的结果记录提供商但不计费",
+			results:      []translator.Result{{Provider: "claude", Model: "claude-3-5-haiku", Cached: true}},
+			wantProvider: "claude", wantModel: "claude-3-5-haiku", wantFallback: true, wantPriced: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newUsageMeter(cfg)
+			for _, r := range tt.results {
+				m.add(&r)
+			}
+			provider, model, fallback := m.producedBy()
+			if provider != tt.wantProvider || model != tt.wantModel || fallback != tt.wantFallback {
+				t.Errorf("producedBy() = %q, %q, %t, 期望 %q, %q, %t", provider, model, fallback, tt.wantProvider, tt.wantModel, tt.wantFallback)
+			}
+			cost, priced := m.cost(cfg)
+			if math.Abs(cost-tt.wantCost) > 1e-9 || priced != tt.wantPriced {
+				t.Errorf("cost() = %v, %t, 期望 %v, %t", cost, priced, tt.wantCost, tt.wantPriced)
+			}
+		})
+	}
+}
@@ -2,14 +2,17 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os" // 导入 os 包
 	"path/filepath"
+	"slices"
 	"sync"
 	"sync/atomic" // 使用原子操作保证计数器线程安全
-	"time"        // 导入 time 包
+	"time"
 
 	"Markdown-translator-go/config"
+	"Markdown-translator-go/memory"
 	"Markdown-translator-go/translator"
 	"Markdown-translator-go/utils"
 )
@@ -17,25 +20,329 @@ import (
 // TranslationTask 结构体包含处理单个文件所需的所有信息。
 type TranslationTask struct {
 	RelativePath string // 文件相对于源/目标基础目录的路径。
+	Lang         string // 目标语言代码。
 }
 
-// Stats 结构体用于跟踪处理过程中的统计数据。
-type Stats struct {
-	TotalFiles int32        // 发现的总文件数。
+// label 返回用于日志和报告的任务名称。只有一个目标语言时就是相对路径，否则带上语言前缀。
+func (t TranslationTask) label(cfg *config.Config) string {
+	if len(cfg.TargetLanguages) == 1 {
+		return t.RelativePath
+	}
+	return "[" + t.Lang + "] " + t.RelativePath
+}
+
+// staleTempFileAge 是启动时清理目标目录中残留临时文件的最小文件年龄。
+// 写入一个文件只需要很短的时间，更新的临时文件可能属于同时运行的另一个进程，不删除。
+const staleTempFileAge = time.Minute
+
+// outcome 表示单个翻译任务的处理结果，用于更新统计数据。
+type outcome int
+
+const (
+	outcomeProcessed   outcome = iota // 翻译并写入成功
+	outcomeSkipped                    // 目标文件已存在或内容未变化，跳过
+	outcomeFailed                     // 处理过程中出错
+	outcomeDryRun                     // 空跑模式下模拟处理
+	outcomeInterrupted                // 处理过程中被取消
+	outcomeNotStarted                 // 收到取消信号时尚未开始
+	outcomeOverBudget                 // 剩余预算不足，未开始
+	outcomeResumed                    // 续跑时因上次运行已完成而跳过
+)
+
+// Counters 是一组按处理结果分类的计数器。
+type Counters struct {
 	Processed  atomic.Int32 // 成功处理的文件数 (成功调用API并写入或跳过)。
 	Skipped    atomic.Int32 // 因目标文件已存在且未设置覆盖而跳过的文件数。
 	Failed     atomic.Int32 // 处理过程中遇到错误的文件数。
 	DryRunHits atomic.Int32 // 在空跑模式下“模拟处理”的文件数。
+	NotStarted atomic.Int32 // 收到取消信号时尚未开始处理的文件数。
+	OverBudget atomic.Int32 // 因剩余预算不足而未翻译的文件数。
+	Warned     atomic.Int32 // 译文未通过结构校验但按 warn 策略仍被写入的文件数。
+	Resumed    atomic.Int32 // 续跑时因任务日志中记录为已完成而跳过的文件数。
+}
+
+// add 根据处理结果增加对应的计数。
+func (c *Counters) add(o outcome) {
+	switch o {
+	case outcomeProcessed:
+		c.Processed.Add(1)
+	case outcomeSkipped:
+		c.Skipped.Add(1)
+	case outcomeFailed:
+		c.Failed.Add(1)
+	case outcomeDryRun:
+		c.DryRunHits.Add(1)
+	case outcomeNotStarted:
+		c.NotStarted.Add(1)
+	case outcomeOverBudget:
+		c.OverBudget.Add(1)
+	case outcomeResumed:
+		c.Resumed.Add(1)
+	}
+}
+
+// Stats 结构体用于跟踪处理过程中的统计数据。
+// 内嵌的 Counters 是所有目标语言的合计，按语言的计数见 Language。
+type Stats struct {
+	TotalFiles int32 // 发现的总文件数。
+	TotalTasks int32 // 翻译任务总数 (文件数 × 目标语言数)。
+	Counters
+
+	byLang map[string]*Counters // 各目标语言的计数，创建后只读
+
+	mu          sync.Mutex             // 保护 interrupted、overBudget、fallback 列表和用量统计
+	interrupted []string               // 处理过程中因取消 (或排空超时) 而中断的文件 (相对路径)。
+	overBudget  []string               // 因剩余预算不足而未翻译的文件 (相对路径)。
+	fallback    []string               // 由备用提供商翻译的文件 (相对路径及提供商)。
+	usage       UsageTotals            // 所有目标语言的用量合计
+	langUsage   map[string]UsageTotals // 各目标语言的用量合计
+	files       []FileUsage            // 每个调用过 API 的任务的用量
+	failed      []FailedFile           // 失败的任务及原因
+}
+
+// newStats 为给定的文件数、任务数和目标语言创建统计对象。
+func newStats(numFiles, numTasks int, langs []string) *Stats {
+	stats := &Stats{
+		TotalFiles: int32(numFiles),
+		TotalTasks: int32(numTasks),
+		byLang:     make(map[string]*Counters, len(langs)),
+		langUsage:  make(map[string]UsageTotals, len(langs)),
+	}
+	for _, lang := range langs {
+		stats.byLang[lang] = &Counters{}
+	}
+	return stats
+}
+
+// Language 返回指定目标语言的计数。
+func (s *Stats) Language(lang string) *Counters {
+	return s.byLang[lang]
+}
+
+// record 将一个任务的处理结果计入合计和对应语言的计数。
+func (s *Stats) record(task TranslationTask, o outcome) {
+	s.Counters.add(o)
+	s.byLang[task.Lang].add(o)
+}
+
+// recordWarning 记录一个译文未通过结构校验但仍被写入的任务。
+func (s *Stats) recordWarning(task TranslationTask) {
+	s.Warned.Add(1)
+	s.byLang[task.Lang].Warned.Add(1)
+}
+
+// addInterrupted 记录一个在处理过程中被中断的文件。
+func (s *Stats) addInterrupted(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interrupted = append(s.interrupted, name)
+}
+
+// InterruptedFiles 返回处理过程中被中断的文件列表 (已排序)。
+func (s *Stats) InterruptedFiles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := slices.Clone(s.interrupted)
+	slices.Sort(files)
+	return files
+}
+
+// addOverBudget 记录一个因剩余预算不足而未翻译的文件。
+func (s *Stats) addOverBudget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overBudget = append(s.overBudget, name)
+}
+
+// OverBudgetFiles 返回因剩余预算不足而未翻译的文件列表 (已排序)。
+func (s *Stats) OverBudgetFiles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := slices.Clone(s.overBudget)
+	slices.Sort(files)
+	return files
+}
+
+// addFailed 记录一个失败的任务。
+func (s *Stats) addFailed(f FailedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = append(s.failed, f)
+}
+
+// FailedFiles 返回失败的任务及原因 (按路径和目标语言排序)。
+func (s *Stats) FailedFiles() []FailedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := slices.Clone(s.failed)
+	sortFailedFiles(files)
+	return files
+}
+
+// addFallback 记录一个 (部分) 由备用提供商翻译的文件。
+func (s *Stats) addFallback(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = append(s.fallback, name)
+}
+
+// FallbackFiles 返回由备用提供商翻译的文件列表 (已排序)，每项包含实际使用的提供商。
+func (s *Stats) FallbackFiles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := slices.Clone(s.fallback)
+	slices.Sort(files)
+	return files
+}
+
+// runState 是一次 ProcessFiles 运行中所有 Worker 共享的状态。
+type runState struct {
+	cfg        *config.Config
+	trans      translator.Translator   // 默认 Translator
+	routeTrans []translator.Translator // 与 cfg.Routes 对应的 Translator，nil 表示使用默认 Translator
+	manifests  map[string]*Manifest    // 各目标语言的翻译清单
+	ledger     *usageLedger            // 用量账本，无法打开时为 nil
+	journal    *journal                // 任务日志，空跑模式或无法打开时为 nil
+	budget     *budget                 // 费用 / token 预算，未设置上限时为 nil
+	stats      *Stats
+}
+
+// translatorFor 返回路由规则 route 使用的 Translator，规则没有独立的 Translator 时返回默认 Translator。
+func (r *runState) translatorFor(route *config.Route) translator.Translator {
+	for i := range r.cfg.Routes {
+		if &r.cfg.Routes[i] == route && i < len(r.routeTrans) && r.routeTrans[i] != nil {
+			return r.routeTrans[i]
+		}
+	}
+	return r.trans
 }
 
 // ProcessFiles 函数设置 Worker 池（一组 Goroutine），并将文件处理任务分发给它们。
-func ProcessFiles(cfg *config.Config, files []string, trans translator.Translator) *Stats {
-	numFiles := len(files)
-	stats := &Stats{TotalFiles: int32(numFiles)} // 初始化统计对象
-	log.Printf("开始处理 %d 个文件，使用 %d 个 Worker...\n", numFiles, cfg.Concurrency)
+// 每个文件会被翻译为 cfg.TargetLanguages 中的所有语言，文件 × 语言的任务共用同一个 Worker 池。
+// ctx 被取消 (例如收到 SIGINT/SIGTERM) 后，Worker 不再开始新的文件；
+// 正在处理中的文件最多再获得 cfg.DrainTimeout 的时间完成，超时后其 API 调用会被取消。
+// 设置了费用或 token 上限时，剩余预算不足以覆盖下一个文件的预估用量后不再开始新的文件，进行中的文件照常完成。
+// 每个文件按 cfg.Routes 选择路由规则; routeTrans 与 cfg.Routes 一一对应 (见 translator.NewRouteTranslators)，
+// 对应项为 nil (或 routeTrans 为 nil) 时使用 trans。
+// langs 不为 nil 时 (例如 --files-from 指定了失败报告)，每个文件只翻译为 langs[文件] 中的目标语言。
+func ProcessFiles(ctx context.Context, cfg *config.Config, files []string, langs map[string][]string, trans translator.Translator, routeTrans []translator.Translator) *Stats {
+	langsFor := func(relPath string) []string {
+		if langs == nil {
+			return cfg.TargetLanguages
+		}
+		return langs[relPath]
+	}
+	numTasks := 0
+	for _, relPath := range files {
+		numTasks += len(langsFor(relPath))
+	}
+	stats := newStats(len(files), numTasks, cfg.TargetLanguages) // 初始化统计对象
+	log.Printf("开始处理 %d 个文件 (%d 个目标语言，共 %d 个任务)，使用 %d 个 Worker...\n",
+		len(files), len(cfg.TargetLanguages), stats.TotalTasks, cfg.Concurrency)
+
+	// 清理之前的运行在写入过程中崩溃或被终止时留在目标目录中的临时文件
+	if !cfg.DryRun {
+		for _, lang := range cfg.TargetLanguages {
+			removed, err := utils.CleanTempFiles(cfg.TargetDirFor(lang), staleTempFileAge)
+			if err != nil {
+				log.Printf("警告: %v\n", err)
+			}
+			if removed > 0 {
+				log.Printf("已删除目标目录 %s 中 %d 个上次运行残留的临时文件。\n", cfg.TargetDirFor(lang), removed)
+			}
+		}
+	}
+
+	// 加载每个目标目录中的翻译清单，用于变更模式下的跳过判断，并在翻译成功后记录新的指纹。
+	manifests := make(map[string]*Manifest, len(cfg.TargetLanguages))
+	for _, lang := range cfg.TargetLanguages {
+		dir := cfg.TargetDirFor(lang)
+		manifest, err := LoadManifest(dir)
+		if err != nil {
+			// 清单损坏不应阻止翻译，但变更模式下会把所有文件视为已变化
+			log.Printf("警告: %v。将使用空的翻译清单。\n", err)
+			manifest = newManifest(dir)
+		}
+		manifests[lang] = manifest
+	}
+	if !cfg.DryRun {
+		// 运行结束时写入失败报告 (在关闭任务日志等之后执行，此时所有 Worker 已结束)
+		defer func() {
+			if err := writeFailureReports(cfg, stats); err != nil {
+				log.Printf("写入失败报告时出错: %v\n", err)
+			}
+		}()
+		defer func() {
+			for _, manifest := range manifests {
+				if err := manifest.Save(); err != nil {
+					log.Printf("保存翻译清单失败: %v\n", err)
+				}
+			}
+		}()
+	}
 
-	// 创建一个带缓冲区的 channel 用于传递任务。缓冲区大小设为文件数，避免发送者阻塞。
-	tasks := make(chan TranslationTask, numFiles)
+	// 打开用量账本，记录每个文件的 token 用量和费用。账本无法打开时只记录警告，不影响翻译。
+	var ledger *usageLedger
+	if !cfg.DryRun {
+		var err error
+		if ledger, err = openUsageLedger(cfg, time.Now()); err != nil {
+			log.Printf("警告: %v。本次运行不记录用量账本。\n", err)
+		} else {
+			defer func() {
+				if err := ledger.Close(); err != nil {
+					log.Printf("关闭用量账本失败: %v\n", err)
+				}
+			}()
+		}
+	}
+
+	// 打开任务日志，记录每个任务的状态变化。续跑时据此跳过上次运行已完成的任务。
+	// 空跑模式下只在续跑时读取，不写入。任务日志无法打开时只记录警告，不影响翻译。
+	jour, err := openJournal(cfg, time.Now(), !cfg.DryRun)
+	if err != nil {
+		log.Printf("警告: %v。本次运行不记录任务日志。\n", err)
+	}
+	defer func() {
+		if err := jour.Close(); err != nil {
+			log.Printf("关闭任务日志失败: %v\n", err)
+		}
+	}()
+
+	// workCtx 用于进行中的 API 调用。它不随 ctx 立即取消，而是在 ctx 取消后再等待一个排空期，
+	// 使已经开始的文件有机会正常完成并写入，而不是被半途丢弃。
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("收到取消请求: 停止分发新文件，最多等待 %v 让进行中的文件完成...\n", cfg.DrainTimeout)
+			timer := time.NewTimer(cfg.DrainTimeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				log.Println("排空等待超时，取消所有进行中的 API 调用。")
+				cancelWork()
+			case <-done:
+			}
+		case <-done:
+		}
+	}()
+
+	run := &runState{
+		cfg:        cfg,
+		trans:      trans,
+		routeTrans: routeTrans,
+		manifests:  manifests,
+		ledger:     ledger,
+		journal:    jour,
+		budget:     newBudget(cfg),
+		stats:      stats,
+	}
+
+	// 创建一个带缓冲区的 channel 用于传递任务。缓冲区大小设为任务数，避免发送者阻塞。
+	tasks := make(chan TranslationTask, stats.TotalTasks)
 	// 使用 sync.WaitGroup 等待所有 Worker Goroutine 完成任务。
 	var wg sync.WaitGroup
 
@@ -43,12 +350,47 @@ func ProcessFiles(cfg *config.Config, files []string, trans translator.Translato
 	for i := 0; i < cfg.Concurrency; i++ {
 		wg.Add(1) // 每启动一个 Worker，计数器加 1。
 		// 启动 Goroutine 执行 worker 函数，传入 Worker ID (用于日志区分) 和其他必要参数。
-		go worker(i+1, cfg, tasks, trans, &wg, stats)
+		go worker(i+1, ctx, workCtx, run, tasks, &wg)
 	}
 
-	// 将所有待处理的文件路径封装成 TranslationTask，发送到 tasks channel。
+	// 将所有待处理的文件 × 目标语言封装成 TranslationTask，发送到 tasks channel。
+	// 同一文件的各语言任务相邻，使它们大致同时完成。
+	// 续跑时跳过任务日志中已完成 (且目标文件仍存在) 的任务，以及按重试策略不重试的上次失败的任务。
+	var resumed, missing, notRetried int
 	for _, relPath := range files {
-		tasks <- TranslationTask{RelativePath: relPath}
+		for _, lang := range langsFor(relPath) {
+			task := TranslationTask{RelativePath: relPath, Lang: lang}
+			if previous, ok := jour.previous(task); ok {
+				switch {
+				case previous.State == journalDone && targetExists(cfg, task):
+					stats.record(task, outcomeResumed)
+					resumed++
+					continue
+				case previous.State == journalDone:
+					// 目标文件在上次运行后被删除，重新处理
+					log.Printf("续跑: %s 在上次运行中已完成，但目标文件不存在，将重新处理。\n", task.label(cfg))
+					missing++
+				case previous.State == journalFailed && !slices.Contains(cfg.ResumeRetry, previous.Class):
+					stats.record(task, outcomeFailed)
+					stats.addFailed(FailedFile{
+						File:    previous.File,
+						Lang:    task.Lang,
+						Stage:   previous.Stage,
+						Class:   previous.Class,
+						Message: previous.Message,
+					})
+					notRetried++
+					continue
+				}
+			}
+			if err := jour.record(task, journalQueued, nil); err != nil {
+				log.Printf("记录任务日志时出错: %v\n", err)
+			}
+			tasks <- task
+		}
+	}
+	if cfg.Resume {
+		log.Printf("续跑: 跳过 %d 个上次运行已完成的任务，重新处理 %d 个目标文件已不存在的任务，%d 个上次失败的任务按重试策略不重试。\n", resumed, missing, notRetried)
 	}
 	// 所有任务都已发送完毕，关闭 tasks channel。
 	// Worker 在读完 channel 中所有数据后会检测到 channel 关闭并退出循环。
@@ -63,7 +405,8 @@ func ProcessFiles(cfg *config.Config, files []string, trans translator.Translato
 
 // worker 函数是每个并发 Goroutine 执行的核心逻辑。
 // 它从 tasks channel 接收任务，处理单个文件的翻译，直到 channel 关闭。
-func worker(id int, cfg *config.Config, tasks <-chan TranslationTask, trans translator.Translator, wg *sync.WaitGroup, stats *Stats) {
+// ctx 取消或预算耗尽后不再开始新文件；workCtx 用于进行中的 API 调用 (见 ProcessFiles)。
+func worker(id int, ctx, workCtx context.Context, run *runState, tasks <-chan TranslationTask, wg *sync.WaitGroup) {
 	// defer 语句确保在 worker 函数退出前（无论是正常结束还是 panic），都会调用 wg.Done()。
 	defer wg.Done()
 	log.Printf("[Worker %d] 启动。\n", id)
@@ -71,91 +414,209 @@ func worker(id int, cfg *config.Config, tasks <-chan TranslationTask, trans tran
 	// 使用 for range 循环从 tasks channel 接收任务。
 	// 当 channel 关闭且所有数据都被读取后，循环会自动结束。
 	for task := range tasks {
-		// 构建源文件和目标文件的完整路径。
-		sourcePath := filepath.Join(cfg.SourceDir, task.RelativePath)
-		targetPath := filepath.Join(cfg.TargetDir, task.RelativePath)
-
-		log.Printf("[Worker %d] 正在处理: %s -> %s\n", id, task.RelativePath, targetPath)
-
-		// --- 检查目标文件是否存在以及是否需要跳过 ---
-		// 仅在非空跑模式且未设置覆盖模式时执行此检查。
-		if !cfg.Overwrite && !cfg.DryRun {
-			// os.Stat 返回文件信息。如果 error 为 nil，表示文件存在。
-			if _, err := os.Stat(targetPath); err == nil {
-				log.Printf("[Worker %d] 跳过已存在的文件: %s\n", id, targetPath)
-				stats.Skipped.Add(1) // 原子地增加跳过计数。
-				continue             // 跳过当前任务，处理下一个。
-			} else if !os.IsNotExist(err) {
-				// 如果 Stat 返回错误，但不是 "文件不存在" 错误 (例如权限问题)，则记录错误并跳过。
-				log.Printf("[Worker %d] 检查目标文件 %s 状态时出错: %v\n", id, targetPath, err)
-				stats.Failed.Add(1) // 原子地增加失败计数。
-				continue            // 处理下一个任务。
-			}
-			// 如果文件不存在 (os.IsNotExist(err) is true)，则继续后续处理。
+		// 收到取消信号后不再开始新的文件，只把剩余任务计为未开始，直到 channel 被读空。
+		if ctx.Err() != nil {
+			run.stats.record(task, outcomeNotStarted)
+			continue
 		}
-
-		// --- 读取源文件内容 ---
-		content, err := utils.ReadFile(sourcePath)
-		if err != nil {
-			log.Printf("[Worker %d] 读取源文件 %s 时出错: %v\n", id, sourcePath, err)
-			stats.Failed.Add(1)
-			continue // 跳过当前任务。
+		// 预算耗尽后同样不再开始新的文件，剩余任务计为预算不足
+		if run.budget.isExhausted() {
+			run.stats.addOverBudget(task.label(run.cfg))
+			run.stats.record(task, outcomeOverBudget)
+			continue
+		}
+		meter := newUsageMeter(run.cfg)
+		if err := run.journal.record(task, journalInProgress, nil); err != nil {
+			log.Printf("[Worker %d] 记录任务日志时出错: %v\n", id, err)
 		}
+		o, err := processFile(id, workCtx, run, task, meter)
+		var failure *FailedFile
+		if o == outcomeFailed {
+			f := newFailedFile(task, err)
+			run.stats.addFailed(f)
+			failure = &f
+		}
+		if err := run.journal.finish(task, o, failure); err != nil {
+			log.Printf("[Worker %d] 记录任务日志时出错: %v\n", id, err)
+		}
+		switch o {
+		case outcomeInterrupted:
+			run.stats.addInterrupted(task.label(run.cfg))
+		case outcomeOverBudget:
+			run.stats.addOverBudget(task.label(run.cfg))
+		}
+		run.stats.record(task, o)
 
-		// --- 处理空跑 (Dry Run) 模式 ---
-		if cfg.DryRun {
-			log.Printf("[Worker %d] [空跑模式] 将翻译并写入 (模拟): %s\n", id, targetPath)
-			stats.DryRunHits.Add(1) // 增加空跑命中计数。
-			// 在空跑模式下，我们认为这个文件被“处理”了，即使没有实际操作。
-			// stats.Processed.Add(1) // 可以选择也增加 Processed 计数，或仅用 DryRunHits。
-			continue // 跳过后续的 API 调用和文件写入。
+		// 调用过 API 或命中翻译缓存的任务 (无论成功与否) 都计入用量统计和账本
+		if meter.requests > 0 || meter.cacheHits > 0 {
+			usage := newFileUsage(run.cfg, task, meter, o)
+			log.Printf("[Worker %d] 文件 %s 用量: 请求 %d, 翻译缓存命中 %d, 输入 %d (缓存 %d), 输出 %d, 费用 %.4f\n",
+				id, task.label(run.cfg), usage.Requests, usage.CacheHits, usage.InputTokens, usage.CachedInputTokens, usage.OutputTokens, usage.Cost)
+			run.stats.recordUsage(usage)
+			if run.ledger != nil {
+				if err := run.ledger.Record(usage); err != nil {
+					log.Printf("[Worker %d] 记录用量账本时出错: %v\n", id, err)
+				}
+			}
 		}
+	} // 结束 for range 循环，当前 Worker 完成所有分配的任务。
+	log.Printf("[Worker %d] 结束。\n", id)
+} // Worker 函数返回，wg.Done() 被调用。
 
-		// --- 检查 Translator 实例是否有效 ---
-		// 在非空跑模式下，trans 不应为 nil。这是个健壮性检查。
-		if trans == nil {
-			log.Printf("[Worker %d] 错误: Translator 实例未初始化 (可能处于空跑模式但逻辑出错)。跳过 %s\n", id, task.RelativePath)
-			stats.Failed.Add(1)
-			continue
+// processFile 将单个文件翻译为任务指定的目标语言并写入目标目录，返回处理结果以及失败 (outcomeFailed) 的原因。
+// 该任务所有 API 请求的 token 用量累计到 meter 中。
+func processFile(id int, workCtx context.Context, run *runState, task TranslationTask, meter *usageMeter) (outcome, error) {
+	cfg, trans, manifest := run.cfg, run.trans, run.manifests[task.Lang]
+	name := task.label(cfg)
+
+	// 构建源文件和目标文件的完整路径。
+	sourcePath := filepath.Join(cfg.SourceDir, task.RelativePath)
+	targetPath := filepath.Join(cfg.TargetDirFor(task.Lang), task.RelativePath)
+
+	log.Printf("[Worker %d] 正在处理: %s -> %s\n", id, name, targetPath)
+
+	// --- 检查目标文件是否存在以及是否需要跳过 ---
+	// 仅在非空跑模式且未设置覆盖模式时执行此检查。变更模式下改为在读取源文件后比较清单中的哈希。
+	if !cfg.Overwrite && !cfg.ChangedOnly && !cfg.DryRun {
+		// os.Stat 返回文件信息。如果 error 为 nil，表示文件存在。
+		if _, err := os.Stat(targetPath); err == nil {
+			log.Printf("[Worker %d] 跳过已存在的文件: %s\n", id, targetPath)
+			return outcomeSkipped, nil // 跳过当前任务，处理下一个。
+		} else if !os.IsNotExist(err) {
+			// 如果 Stat 返回错误，但不是 "文件不存在" 错误 (例如权限问题)，则记录错误并跳过。
+			log.Printf("[Worker %d] 检查目标文件 %s 状态时出错: %v\n", id, targetPath, err)
+			return outcomeFailed, withStage(stageWrite, err)
 		}
+		// 如果文件不存在 (os.IsNotExist(err) is true)，则继续后续处理。
+	}
 
-		// --- 调用 LLM API 进行翻译 ---
-		// 创建一个带有超时的 Context，用于控制 API 调用时间。
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second) // 例如，设置 120 秒超时。
-		translatedRaw, err := trans.Translate(ctx, content)                       // 调用所选 Provider 的 Translate 方法。
-		cancel()                                                                  // 及时调用 cancel 释放 Context 相关资源。
+	// --- 读取源文件内容 ---
+	content, err := utils.ReadFile(sourcePath)
+	if err != nil {
+		log.Printf("[Worker %d] 读取源文件 %s 时出错: %v\n", id, sourcePath, err)
+		return outcomeFailed, withStage(stageRead, err)
+	}
 
-		if err != nil {
-			// 如果翻译过程中出错 (网络问题、API 错误等)，记录错误并跳过。
-			log.Printf("[Worker %d] 翻译文件 %s 时出错: %v\n", id, task.RelativePath, err)
-			stats.Failed.Add(1)
-			continue
+	// --- 按路由规则选择 Prompt、提供商配置和分片设置 (之后的步骤都使用该规则的配置) ---
+	if route := cfg.RouteFor(task.RelativePath, translator.EstimateTokens(content)); route != nil {
+		log.Printf("[Worker %d] 文件 %s 使用路由规则: %s\n", id, name, route.Name)
+		cfg, trans = cfg.ForRoute(route), run.translatorFor(route)
+		meter.primary = producer{cfg.LLMProvider, cfg.LLMModel}
+	}
+
+	// --- 变更模式: 源内容和 Prompt 都未变化且目标文件仍存在时跳过 ---
+	sourceHash := utils.HashString(content)
+	if cfg.ChangedOnly && manifest.Unchanged(task.RelativePath, sourceHash, cfg.PromptHash) {
+		if _, err := os.Stat(targetPath); err == nil {
+			log.Printf("[Worker %d] 跳过未变化的文件: %s\n", id, name)
+			return outcomeSkipped, nil
 		}
+	}
 
-		// --- 从 LLM 的原始响应中提取 <translate> 标签内的内容 ---
-		translatedContent, err := utils.ExtractTranslation(translatedRaw)
-		if err != nil {
-			// 如果提取失败 (例如 LLM 未按要求添加标签)，记录错误。
-			// ExtractTranslation 内部已经记录了详细的错误信息和预览。
-			log.Printf("[Worker %d] 提取文件 %s 的翻译内容失败: %v\n", id, task.RelativePath, err)
-			stats.Failed.Add(1)
-			continue
+	// --- 处理空跑 (Dry Run) 模式 ---
+	if cfg.DryRun {
+		log.Printf("[Worker %d] [空跑模式] 将翻译并写入 (模拟): %s\n", id, targetPath)
+		// 在空跑模式下，我们认为这个文件被“处理”了，即使没有实际操作。
+		return outcomeDryRun, nil // 跳过后续的 API 调用和文件写入。
+	}
+
+	// --- 检查 Translator 实例是否有效 ---
+	// 在非空跑模式下，trans 不应为 nil。这是个健壮性检查。
+	if trans == nil {
+		log.Printf("[Worker %d] 错误: Translator 实例未初始化 (可能处于空跑模式但逻辑出错)。跳过 %s\n", id, name)
+		return outcomeFailed, errors.New("Translator 实例未初始化")
+	}
+
+	// --- 预算检查: 按渲染后的 Prompt 预估本文件的用量并预留额度，剩余预算不足时不调用 API ---
+	estimate, err := estimateUsage(cfg, task, content)
+	if err != nil {
+		log.Printf("[Worker %d] 预估文件 %s 的用量时出错: %v\n", id, name, err)
+		return outcomeFailed, err
+	}
+	price, _ := cfg.PriceFor(cfg.LLMProvider, cfg.LLMModel)
+	reserved, ok := run.budget.reserve(estimate, price)
+	if !ok {
+		log.Printf("[Worker %d] 剩余预算不足，未翻译: %s\n", id, name)
+		return outcomeOverBudget, nil
+	}
+	// 无论翻译成功与否，都按实际提供商/模型的用量结算 (meter 在 translateAndValidate 返回后不再变化)。
+	// 实际使用的提供商/模型缺少价格时按预留时的价格估算，避免这部分费用不计入预算。
+	defer func() {
+		cost, priced := meter.cost(cfg)
+		if !priced {
+			u := meter.usage
+			cost = reserved.price.Cost(u.InputTokens, u.CachedInputTokens, u.OutputTokens)
 		}
+		run.budget.settle(reserved, meter.usage.Total(), cost)
+	}()
 
-		// --- 将提取到的翻译内容写入目标文件 ---
-		// 使用配置中的 Overwrite 标志。
-		err = utils.WriteFile(targetPath, translatedContent, cfg.Overwrite)
-		if err != nil {
-			// 如果写入失败 (例如磁盘空间不足、权限问题)，记录错误。
-			log.Printf("[Worker %d] 写入目标文件 %s 时出错: %v\n", id, targetPath, err)
-			stats.Failed.Add(1)
-			continue // 处理下一个任务。
+	// --- 调用 LLM API 进行翻译，并提取 <translate> 标签内的内容 ---
+	// 大文件会在 translateDocument 中按结构切分为多个片段分别翻译，再按顺序拼接。
+	// 单次请求的超时由 HTTP 客户端控制，包含重试在内的总耗时由重试层的 max_elapsed 限制，
+	// 因此这里不再额外设置整体超时，以免截断正在退避等待的重试。
+	// 使用 workCtx 而非 ctx: 收到退出信号后，进行中的请求仍可在排空期内完成。
+	// 译文随后与原文进行结构校验，不一致时按 cfg.ValidatePolicy 处理 (见 translateAndValidate)。
+	// 增量模式下只翻译有变化的段落 (见 translateFile)。
+	translatedContent, issues, err := translateFile(workCtx, cfg, trans, task, manifest, targetPath, content, meter)
+
+	if err != nil && workCtx.Err() != nil {
+		// 排空期结束后 API 调用被取消，文件未写入任何内容
+		log.Printf("[Worker %d] 文件 %s 的翻译已被取消。\n", id, name)
+		return outcomeInterrupted, nil
+	}
+	if err != nil {
+		// 如果翻译过程中出错 (网络问题、API 错误、LLM 未按要求添加标签等)，记录错误并跳过。
+		log.Printf("[Worker %d] 翻译文件 %s 时出错: %v\n", id, name, err)
+		return outcomeFailed, err
+	}
+	if meter.reused > 0 {
+		log.Printf("[Worker %d] 文件 %s 有 %d 个段落复用了翻译记忆中的译文。\n", id, name, meter.reused)
+	}
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			log.Printf("[Worker %d] 警告: 文件 %s 的译文结构与原文不一致: %s\n", id, name, issue)
 		}
-		// 如果 WriteFile 没有返回错误，表示写入成功或因未设置覆盖而已存在被跳过 (返回 nil)。
-		// 两种情况都表示这个文件处理成功。
-		log.Printf("[Worker %d] 成功处理并写入 (或已跳过): %s\n", id, targetPath)
-		stats.Processed.Add(1) // 原子地增加成功处理计数。
+		run.stats.recordWarning(task)
+	}
 
-	} // 结束 for range 循环，当前 Worker 完成所有分配的任务。
-	log.Printf("[Worker %d] 结束。\n", id)
-} // Worker 函数返回，wg.Done() 被调用。
+	// --- 将提取到的翻译内容写入目标文件 ---
+	// 使用配置中的 Overwrite 标志。变更模式下走到这里说明文件已变化，需要覆盖旧的翻译。
+	err = utils.WriteFile(targetPath, translatedContent, cfg.Overwrite || cfg.ChangedOnly)
+	if err != nil {
+		// 如果写入失败 (例如磁盘空间不足、权限问题)，记录错误。
+		log.Printf("[Worker %d] 写入目标文件 %s 时出错: %v\n", id, targetPath, err)
+		return outcomeFailed, withStage(stageWrite, err)
+	}
+	// 如果 WriteFile 没有返回错误，表示写入成功或因未设置覆盖而已存在被跳过 (返回 nil)。
+	// 两种情况都表示这个文件处理成功。
+	log.Printf("[Worker %d] 成功处理并写入 (或已跳过): %s\n", id, targetPath)
+
+	// --- 在翻译清单中记录本次翻译的输入指纹以及实际生成译文的提供商 ---
+	provider, model, fallback := meter.producedBy()
+	if fallback {
+		run.stats.addFallback(name + " (" + meter.label() + ")")
+	}
+	entry := ManifestEntry{
+		SourceHash:   sourceHash,
+		PromptHash:   cfg.PromptHash,
+		Provider:     provider,
+		Model:        model,
+		TranslatedAt: time.Now(),
+	}
+	if cfg.Incremental {
+		entry.Source = content
+	}
+	if err := manifest.Record(task.RelativePath, entry); err != nil {
+		log.Printf("[Worker %d] 更新翻译清单时出错: %v\n", id, err)
+	}
+
+	// --- 将按段落对齐的原文和译文写入翻译记忆 (未通过校验的译文不写入，以免污染记忆) ---
+	if cfg.Memory != nil && len(issues) == 0 {
+		if pairs, err := memory.Align(content, translatedContent); err != nil {
+			log.Printf("[Worker %d] 文件 %s 的原文与译文无法按段落对齐，不写入翻译记忆: %v\n", id, name, err)
+		} else if _, err := cfg.Memory.Add(cfg.SourceLang, task.Lang, task.RelativePath, pairs); err != nil {
+			log.Printf("[Worker %d] 写入翻译记忆时出错: %v\n", id, err)
+		}
+	}
+	return outcomeProcessed, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+
+	"Markdown-translator-go/config"
+	"Markdown-translator-go/memory"
+)
+
+// memoryUsage 是 tm 子命令的用法说明。
+const memoryUsage = `用法: %s tm <stats|export> [选项]
+
+  stats                       显示翻译记忆的条目数和语言对
+  export [-o <文件>]          以 TMX 1.4 格式导出翻译记忆 (默认输出到标准输出)
+
+选项:
+`
+
+// runMemoryCommand 执行 tm 子命令 (查看和导出翻译记忆) 并返回进程退出码。
+// 翻译记忆文件按 --tm、--config 中的 [translation_memory] file 的顺序确定。
+func runMemoryCommand(args []string) int {
+	fs := flag.NewFlagSet("tm", flag.ContinueOnError)
+	memoryFile := fs.String("tm", "", "翻译记忆文件路径")
+	configFile := fs.String("config", "", "TOML 配置文件路径 (读取其中的 [translation_memory] file)")
+	output := fs.String("o", "", "export: TMX 输出文件 (默认输出到标准输出)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), memoryUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return exitFailure
+	}
+	action := args[0]
+	if action != "stats" && action != "export" {
+		log.Printf("未知的 tm 子命令 '%s'", action)
+		fs.Usage()
+		return exitFailure
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitFailure
+	}
+
+	if *memoryFile == "" && *configFile != "" {
+		path, err := config.MemoryFileFromFile(*configFile)
+		if err != nil {
+			log.Printf("加载配置文件失败: %v", err)
+			return exitFailure
+		}
+		*memoryFile = path
+	}
+	if *memoryFile == "" {
+		log.Println("未指定翻译记忆文件 (--tm 或配置文件中的 [translation_memory] file)。")
+		return exitFailure
+	}
+	if _, err := os.Stat(*memoryFile); err != nil {
+		log.Printf("翻译记忆文件不可用: %v", err)
+		return exitFailure
+	}
+	tm, err := memory.Open(*memoryFile)
+	if err != nil {
+		log.Printf("打开翻译记忆失败: %v", err)
+		return exitFailure
+	}
+
+	switch action {
+	case "stats":
+		pairs := map[string]int{}
+		var order []string
+		for _, e := range tm.Entries() {
+			pair := e.SourceLang + " → " + e.TargetLang
+			if pairs[pair] == 0 {
+				order = append(order, pair)
+			}
+			pairs[pair]++
+		}
+		fmt.Printf("翻译记忆:  %s\n", *memoryFile)
+		fmt.Printf("条目数:    %d\n", tm.Len())
+		slices.Sort(order)
+		for _, pair := range order {
+			fmt.Printf("  %s: %d\n", pair, pairs[pair])
+		}
+	case "export":
+		var w io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				log.Printf("创建 TMX 文件失败: %v", err)
+				return exitFailure
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := tm.WriteTMX(w); err != nil {
+			log.Printf("导出翻译记忆失败: %v", err)
+			return exitFailure
+		}
+		if f, ok := w.(*os.File); ok && f != os.Stdout {
+			if err := f.Close(); err != nil {
+				log.Printf("写入 TMX 文件失败: %v", err)
+				return exitFailure
+			}
+			fmt.Printf("已导出 %d 个条目到 %s。\n", tm.Len(), *output)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,258 @@
+package translator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheFormatVersion 参与缓存键的计算，缓存条目格式或键的组成发生变化时递增，使旧条目自然失效。
+const cacheFormatVersion = 1
+
+// CacheKeyParams 是除 Prompt 之外参与缓存键计算的请求参数。
+// 任何可能影响 LLM 输出的设置 (提供商、模型、生成参数) 都应包含在内。
+type CacheKeyParams struct {
+	Provider string            `json:"provider"`
+	Model    string            `json:"model"`
+	Params   map[string]string `json:"params,omitempty"` // 生成参数，如 temperature、num_ctx
+}
+
+// Cache 是基于目录的内容寻址翻译缓存。每个条目是一个以缓存键 (SHA-256) 命名的 JSON 文件，
+// 按键的前两个字符分散到子目录中，例如 <dir>/ab/abcdef....json。
+// 命中时会更新条目的修改时间，因此 Prune 按 "最近一次使用" 清理。
+type Cache struct {
+	dir string
+}
+
+// cacheEntry 是缓存文件的内容。
+type cacheEntry struct {
+	Key       string    `json:"key"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Text      string    `json:"text"`  // LLM 返回的原始输出
+	Usage     Usage     `json:"usage"` // 生成该输出时的 token 用量 (命中时不再计费，仅供参考)
+}
+
+// CacheStats 是缓存目录的统计信息。
+type CacheStats struct {
+	Entries int       // 条目数
+	Bytes   int64     // 占用的字节数
+	Oldest  time.Time // 最早一次使用的时间
+	Newest  time.Time // 最近一次使用的时间
+}
+
+// OpenCache 打开 (必要时创建) 位于 dir 的翻译缓存。
+func OpenCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建翻译缓存目录 %s 失败: %w", dir, err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// CacheKey 计算一次请求的缓存键: hash(格式版本, 提供商, 模型, 生成参数, 渲染后的 Prompt)。
+func CacheKey(params CacheKeyParams, prompt string) string {
+	// json.Marshal 对 map 的键排序，保证相同参数得到相同的键
+	header, _ := json.Marshal(struct {
+		Version int `json:"version"`
+		CacheKeyParams
+	}{cacheFormatVersion, params})
+	h := sha256.New()
+	h.Write(header)
+	h.Write([]byte{0})
+	h.Write([]byte(prompt))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path 返回缓存键对应的文件路径。
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get 查找缓存条目。条目不存在或无法解析时返回 false。
+func (c *Cache) Get(key string) (*cacheEntry, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		log.Printf("缓存: 忽略损坏的缓存条目 %s\n", path)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now) // 记录最近一次使用的时间，供 Prune 使用
+	return &entry, true
+}
+
+// Put 写入缓存条目。先写入临时文件再重命名，避免并发读取到不完整的条目。
+func (c *Cache) Put(key string, params CacheKeyParams, result *Result) error {
+	data, err := json.Marshal(cacheEntry{
+		Key:       key,
+		Provider:  params.Provider,
+		Model:     params.Model,
+		CreatedAt: time.Now(),
+		Text:      result.Text,
+		Usage:     result.Usage,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化缓存条目失败: %w", err)
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入缓存条目失败: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入缓存条目失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入缓存条目失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("保存缓存条目失败: %w", err)
+	}
+	return nil
+}
+
+// walk 对缓存目录中的每个条目文件调用 fn。
+func (c *Cache) walk(fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+// Stats 统计缓存中的条目数和占用空间。
+func (c *Cache) Stats() (CacheStats, error) {
+	var s CacheStats
+	err := c.walk(func(_ string, info fs.FileInfo) error {
+		s.Entries++
+		s.Bytes += info.Size()
+		if t := info.ModTime(); s.Oldest.IsZero() || t.Before(s.Oldest) {
+			s.Oldest = t
+		}
+		if t := info.ModTime(); t.After(s.Newest) {
+			s.Newest = t
+		}
+		return nil
+	})
+	return s, err
+}
+
+// Prune 删除最近一次使用早于 before 的条目，返回删除的条目数和释放的字节数。
+func (c *Cache) Prune(before time.Time) (int, int64, error) {
+	var removed int
+	var freed int64
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
+
+// Clear 删除缓存中的所有条目 (保留缓存目录本身)，返回删除的条目数。
+func (c *Cache) Clear() (int, error) {
+	removed, _, err := c.Prune(time.Now().Add(time.Hour))
+	if err != nil {
+		return removed, err
+	}
+	// 清理空的子目录以及中断写入留下的临时文件
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return removed, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && len(e.Name()) == 2 {
+			if err := os.RemoveAll(filepath.Join(c.dir, e.Name())); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// cacheRefreshKey 是 Context 中 "跳过缓存查找" 标记的键。
+type cacheRefreshKey struct{}
+
+// WithCacheRefresh 返回一个要求 CachedTranslator 跳过缓存查找、重新调用 API 并覆盖缓存条目的 Context。
+// 用于缓存中的输出无法使用 (例如未通过校验) 而需要重新翻译的情况。
+func WithCacheRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheRefreshKey{}, true)
+}
+
+// CachedTranslator 是 Translator 的装饰器，将成功的 LLM 原始输出按缓存键保存到磁盘。
+// 命中缓存时直接返回保存的输出，不发起网络请求，也不产生费用。它位于装饰器链的最外层，
+// 因此命中的请求不会占用限流配额。
+type CachedTranslator struct {
+	inner  Translator
+	cache  *Cache
+	params CacheKeyParams
+}
+
+// NewCachedTranslator 创建一个包装 inner 的缓存装饰器。
+func NewCachedTranslator(inner Translator, cache *Cache, params CacheKeyParams) *CachedTranslator {
+	return &CachedTranslator{inner: inner, cache: cache, params: params}
+}
+
+// Translate 方法实现了 Translator 接口。
+func (t *CachedTranslator) Translate(ctx context.Context, prompt string) (*Result, error) {
+	key := CacheKey(t.params, prompt)
+	if refresh, _ := ctx.Value(cacheRefreshKey{}).(bool); !refresh {
+		if entry, ok := t.cache.Get(key); ok {
+			log.Printf("缓存: 命中 %s，跳过 API 调用。\n", key[:12])
+			return &Result{Text: entry.Text, Cached: true}, nil
+		}
+	}
+
+	result, err := t.inner.Translate(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.cache.Put(key, t.params, result); err != nil {
+		// 缓存写入失败不影响本次翻译
+		log.Printf("缓存: 警告: %v\n", err)
+	}
+	return result, nil
+}
+
+// Close 如果被包装的 Translator 支持关闭，则将调用转发给它。
+func (t *CachedTranslator) Close() error {
+	if closer, ok := t.inner.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
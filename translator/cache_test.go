@@ -0,0 +1,226 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"Markdown-translator-go/config"
+)
+
+func TestCacheKey(t *testing.T) {
+	base := CacheKeyParams{Provider: "openai", Model: "gpt-4o", Params: map[string]string{"temperature": "0.2", "top_p": "1"}}
+	key := CacheKey(base, "prompt")
+	if len(key) != 64 {
+		t.Fatalf("CacheKey() = %q, 期望 64 位十六进制", key)
+	}
+	// 参数 map 的构建顺序不影响缓存键
+	same := CacheKeyParams{Provider: "openai", Model: "gpt-4o", Params: map[string]string{"top_p": "1", "temperature": "0.2"}}
+	if CacheKey(same, "prompt") != key {
+		t.Error("相同的参数应得到相同的缓存键")
+	}
+
+	tests := []struct {
+		name   string
+		params CacheKeyParams
+		prompt string
+	}{
+		{"Prompt 不同", base, "prompt 2"},
+		{"提供商不同", CacheKeyParams{Provider: "claude", Model: "gpt-4o", Params: base.Params}, "prompt"},
+		{"模型不同", CacheKeyParams{Provider: "openai", Model: "gpt-4o-mini", Params: base.Params}, "prompt"},
+		{"参数值不同", CacheKeyParams{Provider: "openai", Model: "gpt-4o", Params: map[string]string{"temperature": "0.3", "top_p": "1"}}, "prompt"},
+		{"缺少参数", CacheKeyParams{Provider: "openai", Model: "gpt-4o", Params: map[string]string{"temperature": "0.2"}}, "prompt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if CacheKey(tt.params, tt.prompt) == key {
+				t.Error("不同的请求应得到不同的缓存键")
+			}
+		})
+	}
+}
+
+func TestCacheKeyParams(t *testing.T) {
+	claude := config.ProviderProfile{Provider: "claude", Model: "claude-sonnet"}
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		profile config.ProviderProfile
+		want    string // 期望的 max_tokens 参数，为空表示不包含
+	}{
+		{name: "claude 指定上限", cfg: config.Config{MaxOutputTokens: 1000}, profile: claude, want: "1000"},
+		{name: "claude 按片段大小计算", cfg: config.Config{ChunkMaxTokens: 3000}, profile: claude, want: "6000"},
+		{name: "claude 不切分", cfg: config.Config{}, profile: claude, want: strconv.Itoa(2 * config.MinOutputTokens)},
+		{
+			name:    "[api.params] 中的 max_tokens 优先",
+			cfg:     config.Config{MaxOutputTokens: 1000},
+			profile: config.ProviderProfile{Provider: "claude", Model: "claude-sonnet", Params: map[string]any{"max_tokens": 99}},
+			want:    "99",
+		},
+		{name: "其他提供商不包含", cfg: config.Config{MaxOutputTokens: 1000}, profile: config.ProviderProfile{Provider: "openai", Model: "gpt-4o"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cacheKeyParams(&tt.cfg, tt.profile).Params["max_tokens"]
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("max_tokens = %q (存在: %t), 期望 %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	// 输出 token 上限不同的请求不应共用缓存
+	small := CacheKey(cacheKeyParams(&config.Config{MaxOutputTokens: 1000}, claude), "prompt")
+	large := CacheKey(cacheKeyParams(&config.Config{MaxOutputTokens: 8000}, claude), "prompt")
+	if small == large {
+		t.Error("输出 token 上限不同时应得到不同的缓存键")
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := CacheKeyParams{Provider: "openai", Model: "m"}
+	key := CacheKey(params, "prompt")
+	if _, ok := cache.Get(key); ok {
+		t.Fatal("空缓存不应命中")
+	}
+	if err := cache.Put(key, params, &Result{Text: "<translate>x</translate>", Usage: Usage{InputTokens: 3, OutputTokens: 4}}); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := cache.Get(key)
+	if !ok || entry.Text != "<translate>x</translate>" || entry.Provider != "openai" || entry.Usage.OutputTokens != 4 {
+		t.Fatalf("Get() = %+v, %t", entry, ok)
+	}
+	if _, err := os.Stat(filepath.Join(cache.dir, key[:2], key+".json")); err != nil {
+		t.Errorf("缓存条目应位于以键的前两个字符命名的子目录中: %v", err)
+	}
+
+	// 损坏的条目和键不一致的条目都视为未命中
+	other := CacheKey(params, "other")
+	if err := os.MkdirAll(filepath.Dir(cache.path(other)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cache.path(other), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(other); ok {
+		t.Error("损坏的条目不应命中")
+	}
+	data, _ := os.ReadFile(cache.path(key))
+	if err := os.WriteFile(cache.path(other), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(other); ok {
+		t.Error("键不一致的条目不应命中")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	cache, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := CacheKeyParams{Provider: "openai"}
+	oldKey, newKey := CacheKey(params, "old"), CacheKey(params, "new")
+	for _, key := range []string{oldKey, newKey} {
+		if err := cache.Put(key, params, &Result{Text: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(cache.path(oldKey), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil || stats.Entries != 2 || stats.Bytes == 0 || !stats.Oldest.Before(stats.Newest) {
+		t.Fatalf("Stats() = %+v, %v", stats, err)
+	}
+	removed, freed, err := cache.Prune(time.Now().Add(-24 * time.Hour))
+	if err != nil || removed != 1 || freed == 0 {
+		t.Fatalf("Prune() = %d, %d, %v, 期望删除 1 个条目", removed, freed, err)
+	}
+	if _, ok := cache.Get(oldKey); ok {
+		t.Error("过期的条目应被删除")
+	}
+	if _, ok := cache.Get(newKey); !ok {
+		t.Error("最近使用的条目应保留")
+	}
+
+	if removed, err := cache.Clear(); err != nil || removed != 1 {
+		t.Fatalf("Clear() = %d, %v, 期望删除 1 个条目", removed, err)
+	}
+	if entries, _ := os.ReadDir(cache.dir); len(entries) != 0 {
+		t.Errorf("Clear() 后缓存目录中仍有 %d 项", len(entries))
+	}
+}
+
+// countingTranslator 记录调用次数，每次返回不同的输出; err 非 nil 时返回错误。
+type countingTranslator struct {
+	calls int
+	err   error
+}
+
+func (c *countingTranslator) Translate(context.Context, string) (*Result, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &Result{Text: "output " + string(rune('0'+c.calls)), Usage: Usage{InputTokens: 1, OutputTokens: 1}}, nil
+}
+
+func TestCachedTranslator(t *testing.T) {
+	cache, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingTranslator{}
+	tr := NewCachedTranslator(inner, cache, CacheKeyParams{Provider: "openai", Model: "m"})
+	ctx := context.Background()
+
+	steps := []struct {
+		name       string
+		ctx        context.Context
+		prompt     string
+		wantText   string
+		wantCached bool
+		wantCalls  int
+	}{
+		{"首次请求调用 API", ctx, "p", "output 1", false, 1},
+		{"相同的 Prompt 命中缓存", ctx, "p", "output 1", true, 1},
+		{"不同的 Prompt 未命中", ctx, "q", "output 2", false, 2},
+		{"刷新时跳过缓存并覆盖条目", WithCacheRefresh(ctx), "p", "output 3", false, 3},
+		{"刷新后命中新的条目", ctx, "p", "output 3", true, 3},
+	}
+	for _, s := range steps {
+		result, err := tr.Translate(s.ctx, s.prompt)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if result.Text != s.wantText || result.Cached != s.wantCached || inner.calls != s.wantCalls {
+			t.Errorf("%s: Translate() = %q (Cached=%t), 调用次数 %d, 期望 %q (Cached=%t), %d",
+				s.name, result.Text, result.Cached, inner.calls, s.wantText, s.wantCached, s.wantCalls)
+		}
+		if s.wantCached && result.Usage.Total() != 0 {
+			t.Errorf("%s: 命中缓存的结果不应计费: %+v", s.name, result.Usage)
+		}
+	}
+
+	// 失败的请求不写入缓存
+	failing := &countingTranslator{err: errors.New("失败")}
+	tr = NewCachedTranslator(failing, cache, CacheKeyParams{Provider: "openai", Model: "other"})
+	for range 2 {
+		if _, err := tr.Translate(ctx, "p"); err == nil {
+			t.Fatal("Translate() 应返回错误")
+		}
+	}
+	if failing.calls != 2 {
+		t.Errorf("调用次数 = %d, 期望失败的请求不被缓存", failing.calls)
+	}
+}
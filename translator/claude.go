@@ -8,7 +8,8 @@ import (
 	"io" // 导入 io 包
 	"log"
 	"net/http"
-	"text/template"
+
+	"Markdown-translator-go/config"
 )
 
 const (
@@ -26,11 +27,26 @@ type ClaudeClient struct {
 	apiKey      string
 	apiEndpoint string
 	model       string
-	promptTmpl  *template.Template
+	maxTokens   int  // 每次请求的输出 token 上限 (max_tokens)
+	stream      bool // 是否使用 SSE 流式响应
+	streamOpts  StreamOptions
+	reqOpts     RequestOptions // 附加的生成参数和 Header
+}
+
+// EnableStreaming 启用 SSE 流式响应 (实现 Streamer 接口)。
+func (c *ClaudeClient) EnableStreaming(opts StreamOptions) {
+	c.stream = true
+	c.streamOpts = opts
 }
 
-// NewClaudeClient 创建一个新的 Claude 客户端实例。
-func NewClaudeClient(client *http.Client, apiKey, apiEndpoint, model string, promptTmpl *template.Template) (*ClaudeClient, error) {
+// SetRequestOptions 设置附加的生成参数和 Header (实现 Customizer 接口)。
+func (c *ClaudeClient) SetRequestOptions(opts RequestOptions) {
+	c.reqOpts = opts
+}
+
+// NewClaudeClient 创建一个新的 Claude 客户端实例。maxTokens 是每次请求的输出 token 上限 (API 要求必须指定)，
+// 不大于 0 时使用 config.MinOutputTokens。
+func NewClaudeClient(client *http.Client, apiKey, apiEndpoint, model string, maxTokens int) (*ClaudeClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("Claude API 密钥不能为空")
 	}
@@ -40,23 +56,27 @@ func NewClaudeClient(client *http.Client, apiKey, apiEndpoint, model string, pro
 	if model == "" {
 		model = defaultClaudeModel
 	}
-	log.Printf("初始化 Claude 客户端: Endpoint=%s, Model=%s, APIVersion=%s\n", apiEndpoint, model, claudeAPIVersion)
+	if maxTokens <= 0 {
+		maxTokens = config.MinOutputTokens
+	}
+	log.Printf("初始化 Claude 客户端: Endpoint=%s, Model=%s, APIVersion=%s, MaxTokens=%d\n", apiEndpoint, model, claudeAPIVersion, maxTokens)
 	return &ClaudeClient{
 		httpClient:  client,
 		apiKey:      apiKey,
 		apiEndpoint: apiEndpoint,
 		model:       model,
-		promptTmpl:  promptTmpl,
+		maxTokens:   maxTokens,
 	}, nil
 }
 
 // --- Claude API 特有的请求和响应结构体 (Messages API) ---
 type claudeRequest struct {
-	Model     string           `json:"model"`              // 模型名称
-	Messages  []claudeMessage  `json:"messages"`           // 对话消息列表
-	System    string           `json:"system,omitempty"`   // Claude 使用独立的 system prompt 字段
-	MaxTokens int              `json:"max_tokens"`           // Claude API 要求此字段
-	Temperature float64        `json:"temperature,omitempty"` // 可选参数
+	Model       string          `json:"model"`                 // 模型名称
+	Messages    []claudeMessage `json:"messages"`              // 对话消息列表
+	System      string          `json:"system,omitempty"`      // Claude 使用独立的 system prompt 字段
+	MaxTokens   int             `json:"max_tokens"`            // Claude API 要求此字段
+	Temperature float64         `json:"temperature,omitempty"` // 可选参数
+	Stream      bool            `json:"stream,omitempty"`      // 使用 SSE 流式响应
 }
 
 type claudeMessage struct {
@@ -71,112 +91,226 @@ type claudeContentBlock struct {
 }
 
 type claudeErrorDetail struct { // 基于 Claude 文档可能出现的错误结构
-    Type    string `json:"type"`
-    Message string `json:"message"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type claudeUsage struct {
+	InputTokens              int `json:"input_tokens"`                // 未命中缓存的输入 token 数
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"` // 写入提示缓存的输入 token 数
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`     // 从提示缓存读取的输入 token 数
+	OutputTokens             int `json:"output_tokens"`               // 输出 token 数
+}
+
+// usage 将 Claude 的 usage 结构转换为通用的 Usage。
+// Claude 的 input_tokens 不包含缓存部分，这里将三者相加，使 InputTokens 与其他提供商一样表示全部输入。
+func (u claudeUsage) usage() Usage {
+	return Usage{
+		InputTokens:       u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+		CachedInputTokens: u.CacheReadInputTokens,
+		OutputTokens:      u.OutputTokens,
+	}
 }
 
 type claudeResponse struct {
-	Content      []claudeContentBlock `json:"content"`       // 模型生成的内容块列表
-	StopReason   string               `json:"stop_reason"`   // 完成原因，如 "end_turn", "max_tokens"
-	Usage        map[string]int       `json:"usage"`         // token 使用情况
-    Error        *claudeErrorDetail   `json:"error,omitempty"` // Claude 的错误结构
+	Content    []claudeContentBlock `json:"content"`         // 模型生成的内容块列表
+	StopReason string               `json:"stop_reason"`     // 完成原因，如 "end_turn", "max_tokens"
+	Usage      claudeUsage          `json:"usage"`           // token 使用情况
+	Error      *claudeErrorDetail   `json:"error,omitempty"` // Claude 的错误结构
+}
+
+// claudeStreamEvent 是流式响应中各类事件的数据结构 (只解析用到的字段)。
+// message_start 携带输入 token 数; content_block_delta 携带增量文本;
+// message_delta 携带停止原因和输出 token 数; error 携带错误信息。
+type claudeStreamEvent struct {
+	Type    string `json:"type"`
+	Message *struct {
+		Usage claudeUsage `json:"usage"`
+	} `json:"message,omitempty"`
+	Delta *struct {
+		Type       string `json:"type"` // content_block_delta 中为 "text_delta"
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *claudeUsage       `json:"usage,omitempty"`
+	Error *claudeErrorDetail `json:"error,omitempty"`
+}
+
+// claudeStreamErrorStatus 将流式响应中的错误类型映射为对应的 HTTP 状态码，使重试层能够正确判断。
+var claudeStreamErrorStatus = map[string]int{
+	"overloaded_error":      529,
+	"api_error":             http.StatusInternalServerError,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"invalid_request_error": http.StatusBadRequest,
 }
 
 // Translate 方法实现了 Translator 接口，用于 Claude。
 // !!! 重要: 此实现基于 Claude Messages API 文档，务必进行实际测试和调整 !!!
-func (c *ClaudeClient) Translate(ctx context.Context, markdownContent string) (string, error) {
-	// 步骤 1: 渲染 Prompt
+func (c *ClaudeClient) Translate(ctx context.Context, prompt string) (*Result, error) {
+	// 步骤 1: 构建 Claude API 请求体 (prompt 已由调用方通过 RenderPrompt 渲染)
 	// Claude 的 Messages API 可以接受独立的 System Prompt。
 	// 为了简化，我们暂时将所有内容放入 User Message，但最佳实践可能是
 	// 从模板中解析出系统级指令和用户内容。
-	var promptBuf bytes.Buffer
-	templateData := map[string]string{"Content": markdownContent}
-	if err := c.promptTmpl.Execute(&promptBuf, templateData); err != nil {
-		return "", fmt.Errorf("Claude: 执行 Prompt 模板失败: %w", err)
-	}
-	fullUserPrompt := promptBuf.String()
 	// systemPrompt := "You are a translation assistant..." // 理想情况下从模板提取
-
-	// 步骤 2: 构建 Claude API 请求体
 	apiRequest := claudeRequest{
 		Model: c.model,
 		// System: systemPrompt, // 如果提取了 System Prompt，在这里设置
 		Messages: []claudeMessage{
-			{Role: "user", Content: fullUserPrompt},
+			{Role: "user", Content: prompt},
 		},
-		MaxTokens: 4000, // 必须设置 MaxTokens，根据需要调整值
+		MaxTokens: c.maxTokens, // 必须设置 MaxTokens (可被 [api.params] 中的 max_tokens 覆盖)
 		// Temperature: 0.7,
+		Stream: c.stream,
 	}
 
-	reqBodyBytes, err := json.Marshal(apiRequest)
+	reqBodyBytes, err := c.reqOpts.encodeRequest(apiRequest, "")
 	if err != nil {
-		return "", fmt.Errorf("Claude: 序列化 API 请求失败: %w", err)
+		return nil, fmt.Errorf("Claude: 序列化 API 请求失败: %w", err)
 	}
 
-	// 步骤 3: 创建并发送 HTTP 请求
+	// 步骤 2: 创建并发送 HTTP 请求
+	// 流式模式下由空闲计时器代替整体超时: 超过 IdleTimeout 没有收到数据时取消请求
+	var watchdog *idleWatchdog
+	if c.stream {
+		ctx, watchdog = withIdleTimeout(ctx, c.streamOpts.IdleTimeout)
+		defer watchdog.stop()
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiEndpoint, bytes.NewBuffer(reqBodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("Claude: 创建 API 请求失败: %w", err)
+		return nil, fmt.Errorf("Claude: 创建 API 请求失败: %w", err)
 	}
 
 	// 设置 Claude 特有的 HTTP Headers
-	req.Header.Set("x-api-key", c.apiKey)                // API Key Header
+	req.Header.Set("x-api-key", c.apiKey)                 // API Key Header
 	req.Header.Set("anthropic-version", claudeAPIVersion) // API 版本 Header
 	req.Header.Set("content-type", "application/json")
-	req.Header.Set("accept", "application/json")
+	if c.stream {
+		req.Header.Set("accept", "text/event-stream")
+	} else {
+		req.Header.Set("accept", "application/json")
+	}
+	c.reqOpts.applyHeaders(req)
 
 	log.Printf("Claude: 发送请求到 %s (模型: %s)\n", c.apiEndpoint, c.model)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("Claude: API 请求执行失败: %w", err)
+		if watchdog != nil {
+			err = watchdog.wrap(err)
+		}
+		return nil, fmt.Errorf("Claude: API 请求执行失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-    // 步骤 4: 读取并解码响应体
+	if c.stream && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return c.readStream(resp.Body, watchdog)
+	}
+
+	// 步骤 3: 读取并解码响应体
 	respBodyBytes, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return "", fmt.Errorf("Claude: 读取 API 响应体失败 (状态码 %d): %w", resp.StatusCode, err)
-    }
+	if err != nil {
+		return nil, fmt.Errorf("Claude: 读取 API 响应体失败 (状态码 %d): %w", resp.StatusCode, err)
+	}
+
+	// 步骤 4: 处理响应状态码和内容
+	// 非成功状态码 (包括过载时的 529) 统一包装为 APIError，由重试层决定是否重试
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError("Claude", resp, respBodyBytes)
+	}
 
-	// 步骤 5: 处理响应状态码和内容
 	var apiResponse claudeResponse
 	if err := json.Unmarshal(respBodyBytes, &apiResponse); err != nil {
-		preview := string(respBodyBytes)
-		if len(preview) > 500 {
-			preview = preview[:500] + "..."
-		}
-		return "", fmt.Errorf("Claude: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", resp.StatusCode, err, preview)
+		return nil, fmt.Errorf("Claude: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", resp.StatusCode, err, previewBody(respBodyBytes))
 	}
 
-    // 检查响应体中的错误信息 (根据 Claude 文档调整结构)
-    if apiResponse.Error != nil {
-        return "", fmt.Errorf("Claude: API 返回错误: %s (类型: %s)", apiResponse.Error.Message, apiResponse.Error.Type)
-    }
-
-	// 检查 HTTP 状态码
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-        errMsg := fmt.Sprintf("Claude: API 返回非成功状态码 %d", resp.StatusCode)
-        if apiResponse.Error != nil {
-             errMsg = fmt.Sprintf("%s - %s", errMsg, apiResponse.Error.Message)
-		} else {
-			 preview := string(respBodyBytes)
-			 if len(preview) > 500 {
-				 preview = preview[:500] + "..."
-			 }
-			 errMsg = fmt.Sprintf("%s. 响应体预览: %s", errMsg, preview)
-		}
-		return "", fmt.Errorf(errMsg)
+	// 检查响应体中的错误信息 (根据 Claude 文档调整结构)
+	if apiResponse.Error != nil {
+		return nil, fmt.Errorf("Claude: API 返回错误: %s (类型: %s)", apiResponse.Error.Message, apiResponse.Error.Type)
 	}
 
-	// 步骤 6: 提取翻译结果
+	// 步骤 5: 提取翻译结果
 	// Claude 的响应内容是一个列表，通常第一个是 text 类型
-	if len(apiResponse.Content) == 0 || apiResponse.Content[0].Type != "text" || apiResponse.Content[0].Text == "" {
+	translatedText := ""
+	if len(apiResponse.Content) > 0 && apiResponse.Content[0].Type == "text" {
+		translatedText = apiResponse.Content[0].Text
+	}
+	if err := truncated("Claude", apiResponse.StopReason == "max_tokens", translatedText, c.truncationHint()); err != nil {
+		return nil, err
+	}
+	if translatedText == "" {
 		log.Printf("Claude: API 响应不包含有效文本内容。停止原因: %s\n", apiResponse.StopReason)
-		return "", fmt.Errorf("Claude: API 响应未包含有效翻译内容 (停止原因: %s)", apiResponse.StopReason)
+		return nil, fmt.Errorf("Claude: API 响应未包含有效翻译内容 (停止原因: %s)", apiResponse.StopReason)
 	}
-
-	translatedText := apiResponse.Content[0].Text
 	log.Printf("Claude: 成功接收并解析响应。\n")
 
-	return translatedText, nil
+	return &Result{
+		Text:  translatedText,
+		Usage: apiResponse.Usage.usage(),
+	}, nil
+}
+
+// readStream 读取 Messages API 的流式事件，拼接文本增量。检测到 </translate> 结束标签后提前结束读取。
+func (c *ClaudeClient) readStream(body io.Reader, watchdog *idleWatchdog) (*Result, error) {
+	var text streamText
+	var usage Usage
+	stopReason := ""
+	err := readSSE(body, watchdog, func(_, data string) error {
+		var event claudeStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("Claude: 解码流式事件失败: %w. 数据预览: %s", err, previewBody([]byte(data)))
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				start := event.Message.Usage.usage()
+				usage.InputTokens, usage.CachedInputTokens = start.InputTokens, start.CachedInputTokens
+			}
+		case "content_block_delta":
+			if event.Delta != nil && event.Delta.Type == "text_delta" && text.append(event.Delta.Text) {
+				text.stopped = true
+				return errStopStream
+			}
+		case "message_delta":
+			if event.Delta != nil {
+				stopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "message_stop":
+			return errStopStream
+		case "error":
+			// 流式响应已经返回 200，错误 (如过载) 以事件形式出现，转换为 APIError 交给重试层判断
+			if event.Error != nil {
+				status, ok := claudeStreamErrorStatus[event.Error.Type]
+				if !ok {
+					status = http.StatusBadRequest
+				}
+				return &APIError{Provider: "Claude", StatusCode: status, Message: event.Error.Message}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Claude: 读取流式响应失败: %w", err)
+	}
+
+	if err := truncated("Claude", stopReason == "max_tokens", text.String(), c.truncationHint()); err != nil {
+		return nil, err
+	}
+	if text.String() == "" {
+		log.Printf("Claude: 流式响应不包含有效文本内容。停止原因: %s\n", stopReason)
+		return nil, fmt.Errorf("Claude: API 响应未包含有效翻译内容 (停止原因: %s)", stopReason)
+	}
+	if text.stopped {
+		log.Printf("Claude: 已收到 </translate> 结束标签，提前结束流式响应。\n")
+	} else {
+		log.Printf("Claude: 成功接收流式响应。\n")
+	}
+	return &Result{Text: text.String(), Usage: text.finishUsage(usage)}, nil
+}
+
+// truncationHint 返回输出被截断时的处理建议。
+func (c *ClaudeClient) truncationHint() string {
+	return fmt.Sprintf("max_tokens=%d，可增大 --max-output-tokens 或减小 --chunk-size", c.maxTokens)
 }
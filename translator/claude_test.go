@@ -0,0 +1,132 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Markdown-translator-go/config"
+)
+
+// claudeServer 返回一个模拟 Messages API 的服务器: 按请求是否为流式返回 text 和 stopReason，
+// 并将请求体中的 max_tokens 记录到 *maxTokens。
+func claudeServer(t *testing.T, text, stopReason string, maxTokens *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			MaxTokens int  `json:"max_tokens"`
+			Stream    bool `json:"stream"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("解析请求体失败: %v", err)
+		}
+		*maxTokens = req.MaxTokens
+		textJSON, _ := json.Marshal(text)
+		if !req.Stream {
+			fmt.Fprintf(w, `{"content":[{"type":"text","text":%s}],"stop_reason":%q,"usage":{"input_tokens":5,"output_tokens":7}}`, textJSON, stopReason)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":5}}}\n\n")
+		fmt.Fprintf(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":%s}}\n\n", textJSON)
+		fmt.Fprintf(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":%q},\"usage\":{\"output_tokens\":7}}\n\n", stopReason)
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+}
+
+// truncationCase 描述一次模拟响应: 返回的文本、完成原因以及是否应报告为截断。
+type truncationCase struct {
+	name          string
+	text          string
+	reason        string
+	wantTruncated bool
+}
+
+// truncationCases 返回各提供商共用的截断测试用例。stop 为正常结束的完成原因，limit 为达到输出上限的完成原因。
+func truncationCases(stop, limit string) []truncationCase {
+	return []truncationCase{
+		{name: "正常结束", text: "<translate>译文</translate>", reason: stop},
+		{name: "达到上限", text: "<translate>译文被截", reason: limit, wantTruncated: true},
+		{name: "达到上限但译文已完整", text: "<translate>译文</translate>\n说明被截", reason: limit},
+		{name: "没有标签时达到上限", text: "译文被截", reason: limit, wantTruncated: true},
+	}
+}
+
+// checkTruncation 检查 Translate 的结果是否符合用例的期望。
+func checkTruncation(t *testing.T, tt truncationCase, result *Result, err error) {
+	t.Helper()
+	if tt.wantTruncated {
+		if !errors.Is(err, ErrOutputTruncated) {
+			t.Fatalf("Translate() 错误 = %v, 期望 ErrOutputTruncated", err)
+		}
+		if IsRetryable(err) {
+			t.Error("输出被截断不应重试")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Translate() 错误: %v", err)
+	}
+	if !strings.Contains(result.Text, "译文") {
+		t.Errorf("Translate() = %q", result.Text)
+	}
+}
+
+func TestClaudeTruncation(t *testing.T) {
+	for _, tt := range truncationCases("end_turn", "max_tokens") {
+		for _, stream := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stream=%t", tt.name, stream), func(t *testing.T) {
+				var maxTokens int
+				srv := claudeServer(t, tt.text, tt.reason, &maxTokens)
+				defer srv.Close()
+				c, err := NewClaudeClient(srv.Client(), "key", srv.URL, "model", 1234)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stream {
+					c.EnableStreaming(StreamOptions{})
+				}
+
+				result, err := c.Translate(context.Background(), "prompt")
+				if maxTokens != 1234 {
+					t.Errorf("请求的 max_tokens = %d, 期望 1234", maxTokens)
+				}
+				checkTruncation(t, tt, result, err)
+			})
+		}
+	}
+}
+
+func TestClaudeMaxTokens(t *testing.T) {
+	var maxTokens int
+	srv := claudeServer(t, "<translate>x</translate>", "end_turn", &maxTokens)
+	defer srv.Close()
+
+	// 未指定时使用最小值
+	c, err := NewClaudeClient(srv.Client(), "key", srv.URL, "model", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Translate(context.Background(), "prompt"); err != nil {
+		t.Fatal(err)
+	}
+	if maxTokens != config.MinOutputTokens {
+		t.Errorf("默认 max_tokens = %d, 期望 %d", maxTokens, config.MinOutputTokens)
+	}
+
+	// [api.params] 中的 max_tokens 优先
+	c.SetRequestOptions(RequestOptions{Params: map[string]any{"max_tokens": 99}})
+	if _, err := c.Translate(context.Background(), "prompt"); err != nil {
+		t.Fatal(err)
+	}
+	if maxTokens != 99 {
+		t.Errorf("max_tokens = %d, 期望 [api.params] 中的 99", maxTokens)
+	}
+}
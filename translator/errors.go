@@ -0,0 +1,145 @@
+package translator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError 表示 LLM API 返回了非成功 (非 2xx) 的 HTTP 响应。
+// 各提供商的实现统一返回该类型，便于重试层根据状态码判断是否可以重试。
+type APIError struct {
+	Provider   string        // 提供商名称，如 "OpenAI"
+	StatusCode int           // HTTP 状态码
+	Message    string        // API 返回的错误信息 (无法解析时为响应体预览)
+	RetryAfter time.Duration // 服务端建议的等待时间 (来自 Retry-After 等 Header)，0 表示未提供
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: API 返回非成功状态码 %d - %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Retryable 报告该 API 错误是否属于临时性错误 (限流、超时、服务端错误)。
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	case 529: // Anthropic 在服务过载时返回的非标准状态码
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// ErrOutputTruncated 表示 LLM 的输出达到输出 token 上限 (max_tokens) 被截断。
+// 它不可重试: 相同的请求会再次被截断，应增大输出 token 上限或减小片段大小。
+var ErrOutputTruncated = errors.New("输出达到 token 上限被截断")
+
+// truncated 在 limited (输出因达到输出 token 上限而停止) 且 text 中没有完整的 <translate> 内容时，
+// 返回包装了 ErrOutputTruncated 的错误 (hint 提示如何调大上限)，否则返回 nil。
+// 达到上限前已输出完整译文的响应 (例如只截断了译文之后的说明) 仍然可用。
+func truncated(provider string, limited bool, text, hint string) error {
+	if !limited {
+		return nil
+	}
+	var complete streamText
+	if complete.append(text) {
+		return nil
+	}
+	log.Printf("%s: 输出达到 token 上限被截断。\n", provider)
+	return fmt.Errorf("%s: %w (%s)", provider, ErrOutputTruncated, hint)
+}
+
+// IsRetryable 判断 Translate 返回的错误是否值得重试。
+// 可重试: 限流 (429)、服务端错误 (5xx)、网络错误、读取响应体时连接中断以及流式响应空闲超时。
+// 不可重试: 认证失败、请求格式错误、内容被过滤等其他错误。
+// 注意: 调用方应先检查自身 Context 是否已取消，再调用本函数。
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrStreamIdle)
+}
+
+// newAPIError 根据非成功的 HTTP 响应构建 APIError。
+// OpenAI、Claude 和 Gemini 的错误响应都包含 {"error": {"message": ...}} 结构，因此可以统一解析;
+// Ollama 和部分本地模型服务返回的是 {"error": "..."}，同样可以识别。
+func newAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	var payload struct {
+		Error json.RawMessage `json:"error"`
+	}
+	message := "响应体预览: " + previewBody(body)
+	if err := json.Unmarshal(body, &payload); err == nil && len(payload.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		var text string
+		if json.Unmarshal(payload.Error, &detail) == nil && detail.Message != "" {
+			message = detail.Message
+		} else if json.Unmarshal(payload.Error, &text) == nil && text != "" {
+			message = text
+		}
+	}
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header, time.Now()),
+	}
+}
+
+// parseRetryAfter 从响应 Header 中解析服务端建议的重试等待时间。
+// 优先使用标准的 Retry-After (秒数或 HTTP 日期)，其次是 OpenAI 的 retry-after-ms，
+// 最后是各提供商的限流重置 Header (x-ratelimit-reset-*、anthropic-ratelimit-*-reset)。
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			return time.Duration(secs * float64(time.Second))
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	// OpenAI 兼容接口: 值为 Go 风格的时长，例如 "1s"、"6m0s"、"20ms"
+	var wait time.Duration
+	for _, key := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if d, err := time.ParseDuration(h.Get(key)); err == nil && d > wait {
+			wait = d
+		}
+	}
+	// Anthropic: 值为 RFC 3339 格式的重置时间点
+	for _, key := range []string{"Anthropic-Ratelimit-Requests-Reset", "Anthropic-Ratelimit-Tokens-Reset"} {
+		if t, err := time.Parse(time.RFC3339, h.Get(key)); err == nil && t.Sub(now) > wait {
+			wait = t.Sub(now)
+		}
+	}
+	return wait
+}
+
+// previewBody 返回响应体的前 500 个字节，用于错误信息和日志。
+func previewBody(body []byte) string {
+	preview := string(body)
+	if len(preview) > 500 {
+		preview = preview[:500] + "..."
+	}
+	return preview
+}
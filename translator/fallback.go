@@ -0,0 +1,80 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"Markdown-translator-go/config"
+)
+
+// fallbackMember 是备用提供商链中的一个提供商及其完整的 Translator (含限流、重试和缓存装饰器)。
+type fallbackMember struct {
+	profile config.ProviderProfile
+	trans   Translator
+}
+
+// FallbackTranslator 是由多个提供商组成的组合 Translator。
+// 它按顺序尝试各提供商: 前一个提供商返回错误 (不可重试的错误，如 Gemini 因 SAFETY/RECITATION 停止，
+// 或经过重试仍失败，如 Claude 持续过载) 时改用下一个。成功结果的 Provider/Model 记录实际使用的提供商。
+type FallbackTranslator struct {
+	members []fallbackMember
+}
+
+// newFallbackTranslator 创建按 members 顺序尝试的组合 Translator，第一个为主提供商。
+func newFallbackTranslator(members []fallbackMember) *FallbackTranslator {
+	return &FallbackTranslator{members: members}
+}
+
+// Translate 方法实现了 Translator 接口。
+func (t *FallbackTranslator) Translate(ctx context.Context, prompt string) (*Result, error) {
+	var errs []error
+	for i, m := range t.members {
+		result, err := m.trans.Translate(ctx, prompt)
+		if err == nil {
+			if i > 0 {
+				log.Printf("备用提供商 %s 翻译成功。\n", m.profile.Label())
+			}
+			result.Provider, result.Model = m.profile.Provider, m.profile.Model
+			return result, nil
+		}
+		// Context 已取消 (例如排空期结束) 时，尝试其他提供商也没有意义
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.profile.Label(), err))
+		if i+1 < len(t.members) {
+			log.Printf("提供商 %s 失败 (%v)，改用备用提供商 %s。\n", m.profile.Label(), err, t.members[i+1].profile.Label())
+		}
+	}
+	return nil, fmt.Errorf("所有提供商均失败: %w", errors.Join(errs...))
+}
+
+// Close 关闭所有支持关闭的提供商。
+func (t *FallbackTranslator) Close() error {
+	return closeMembers(t.members)
+}
+
+// closeMembers 关闭 members 中所有支持关闭的 Translator，返回第一个错误。
+func closeMembers(members []fallbackMember) error {
+	var firstErr error
+	for _, m := range members {
+		if closer, ok := m.trans.(Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// memberLabels 返回提供商链的描述，例如 "gemini/gemini-1.5-pro -> claude/claude-3-5-haiku"。
+func memberLabels(members []fallbackMember) string {
+	labels := make([]string, len(members))
+	for i, m := range members {
+		labels[i] = m.profile.Label()
+	}
+	return strings.Join(labels, " -> ")
+}
@@ -0,0 +1,113 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"Markdown-translator-go/config"
+)
+
+// namedTranslator 记录是否被调用，并返回 err 或以 name 为内容的结果。
+type namedTranslator struct {
+	name   string
+	err    error
+	calls  *[]string
+	closed bool
+}
+
+func (n *namedTranslator) Translate(context.Context, string) (*Result, error) {
+	*n.calls = append(*n.calls, n.name)
+	if n.err != nil {
+		return nil, n.err
+	}
+	return &Result{Text: n.name}, nil
+}
+
+func (n *namedTranslator) Close() error {
+	n.closed = true
+	return nil
+}
+
+func TestFallbackTranslator(t *testing.T) {
+	overloaded := &RetryError{Attempts: 3, Err: &APIError{Provider: "Claude", StatusCode: 529}}
+	blocked := errors.New("Gemini: 生成因 'SAFETY' 原因停止")
+	profiles := []config.ProviderProfile{
+		{Provider: "gemini", Model: "gemini-1.5-pro"},
+		{Provider: "claude", Model: "claude-3-5-haiku"},
+		{Provider: "ollama"},
+	}
+	tests := []struct {
+		name         string
+		errs         []error // 各提供商返回的错误
+		wantCalls    []string
+		wantProvider string
+		wantModel    string
+		wantErr      bool
+	}{
+		{"主提供商成功", []error{nil, nil, nil}, []string{"gemini"}, "gemini", "gemini-1.5-pro", false},
+		{"主提供商失败时使用下一个", []error{blocked, nil, nil}, []string{"gemini", "claude"}, "claude", "claude-3-5-haiku", false},
+		{"按顺序尝试到最后一个", []error{blocked, overloaded, nil}, []string{"gemini", "claude", "ollama"}, "ollama", "", false},
+		{"全部失败", []error{blocked, overloaded, blocked}, []string{"gemini", "claude", "ollama"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var members []fallbackMember
+			for i, p := range profiles {
+				members = append(members, fallbackMember{profile: p, trans: &namedTranslator{name: p.Provider, err: tt.errs[i], calls: &calls}})
+			}
+			result, err := newFallbackTranslator(members).Translate(context.Background(), "prompt")
+			if strings.Join(calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("调用顺序 = %q, 期望 %q", calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Translate() 应返回错误")
+				}
+				// 错误中包含每个提供商的错误，且仍可识别各自的类型
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || !errors.Is(err, blocked) || !strings.Contains(err.Error(), "claude/claude-3-5-haiku") {
+					t.Errorf("Translate() 错误 = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Translate() 错误: %v", err)
+			}
+			if result.Text != tt.wantProvider || result.Provider != tt.wantProvider || result.Model != tt.wantModel {
+				t.Errorf("Translate() = %+v, 期望由 %s/%s 生成", result, tt.wantProvider, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestFallbackTranslatorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls []string
+	members := []fallbackMember{
+		{profile: config.ProviderProfile{Provider: "openai"}, trans: &namedTranslator{name: "openai", err: &APIError{StatusCode: http.StatusServiceUnavailable}, calls: &calls}},
+		{profile: config.ProviderProfile{Provider: "claude"}, trans: &namedTranslator{name: "claude", calls: &calls}},
+	}
+	if _, err := newFallbackTranslator(members).Translate(ctx, "prompt"); err == nil || len(calls) != 1 {
+		t.Errorf("Translate() 错误 = %v, 调用 %q, 期望取消后不再尝试备用提供商", err, calls)
+	}
+}
+
+func TestFallbackTranslatorClose(t *testing.T) {
+	var calls []string
+	a, b := &namedTranslator{name: "a", calls: &calls}, &namedTranslator{name: "b", calls: &calls}
+	members := []fallbackMember{
+		{profile: config.ProviderProfile{Provider: "openai", Model: "gpt-4o"}, trans: a},
+		{profile: config.ProviderProfile{Provider: "ollama"}, trans: b},
+	}
+	if got := memberLabels(members); got != "openai/gpt-4o -> ollama" {
+		t.Errorf("memberLabels() = %q", got)
+	}
+	if err := newFallbackTranslator(members).Close(); err != nil || !a.closed || !b.closed {
+		t.Errorf("Close() = %v, 各提供商已关闭: %t, %t", err, a.closed, b.closed)
+	}
+}
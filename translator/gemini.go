@@ -9,7 +9,6 @@ import (
 	"log"
 	"net/http"
 	"strings"
-	"text/template"
 )
 
 const (
@@ -17,6 +16,8 @@ const (
 	defaultGeminiEndpointFormat = "https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent"
 	// 默认使用的 Gemini 模型 (请根据可用性和需求选择)
 	defaultGeminiModel = "gemini-1.5-flash-latest" // 或 gemini-pro
+	// 输出达到 maxOutputTokens 被截断时的处理建议
+	geminiTruncationHint = "可在 [api.params] 中增大 maxOutputTokens 或减小 --chunk-size"
 )
 
 // GeminiClient 结构体实现了 Translator 接口，用于与 Google Gemini API 交互。
@@ -24,11 +25,34 @@ type GeminiClient struct {
 	httpClient  *http.Client
 	apiKey      string
 	apiEndpoint string // 存储最终构建好的 API 端点 URL
-	promptTmpl  *template.Template
+	stream      bool   // 是否使用 SSE 流式响应 (streamGenerateContent)
+	streamOpts  StreamOptions
+	reqOpts     RequestOptions // 附加的生成参数 (合并到 generationConfig) 和 Header
+}
+
+// SetRequestOptions 设置附加的生成参数和 Header (实现 Customizer 接口)。
+// Gemini 的生成参数位于请求体的 generationConfig 中，例如 temperature、topP、maxOutputTokens。
+func (c *GeminiClient) SetRequestOptions(opts RequestOptions) {
+	c.reqOpts = opts
+}
+
+// EnableStreaming 启用 SSE 流式响应 (实现 Streamer 接口)。
+// 端点中的 :generateContent 会被替换为 :streamGenerateContent，并附加 alt=sse 参数。
+func (c *GeminiClient) EnableStreaming(opts StreamOptions) {
+	c.stream = true
+	c.streamOpts = opts
+}
+
+// endpoint 返回本次请求使用的端点 URL。
+func (c *GeminiClient) endpoint() string {
+	if c.stream {
+		return strings.Replace(c.apiEndpoint, ":generateContent", ":streamGenerateContent", 1)
+	}
+	return c.apiEndpoint
 }
 
 // NewGeminiClient 创建一个新的 Gemini 客户端实例。
-func NewGeminiClient(client *http.Client, apiKey, apiEndpoint, model string, promptTmpl *template.Template) (*GeminiClient, error) {
+func NewGeminiClient(client *http.Client, apiKey, apiEndpoint, model string) (*GeminiClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("Gemini API 密钥不能为空")
 	}
@@ -49,19 +73,18 @@ func NewGeminiClient(client *http.Client, apiKey, apiEndpoint, model string, pro
 		httpClient:  client,
 		apiKey:      apiKey,
 		apiEndpoint: apiEndpoint, // 保存最终使用的 URL
-		promptTmpl:  promptTmpl,
 	}, nil
 }
 
 // --- Gemini API 特有的请求和响应结构体 (基于 v1beta) ---
 type geminiRequest struct {
-	Contents         []geminiContent        `json:"contents"`                   // 主要内容
+	Contents         []geminiContent         `json:"contents"`                   // 主要内容
 	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"` // 生成参数配置
 	SafetySettings   []geminiSafetySetting   `json:"safetySettings,omitempty"`   // 安全设置
 }
 
 type geminiContent struct {
-	Parts []geminiPart `json:"parts"`            // 内容块列表 (通常只有一个 text part)
+	Parts []geminiPart `json:"parts"`          // 内容块列表 (通常只有一个 text part)
 	Role  string       `json:"role,omitempty"` // 角色: "user" 或 "model"
 }
 
@@ -97,32 +120,37 @@ type geminiResponse struct {
 		BlockReason string `json:"blockReason"` // 如 "SAFETY"
 		// SafetyRatings []...
 	} `json:"promptFeedback,omitempty"`
+	// UsageMetadata 记录本次请求的 token 使用情况
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata,omitempty"`
 	// Gemini 可能在顶层返回错误，例如认证失败
-    Error *struct {
-        Code    int    `json:"code"`    // HTTP status code mapped
-        Message string `json:"message"` // Error message
-        Status  string `json:"status"`  // e.g., "UNAUTHENTICATED"
+	Error *struct {
+		Code    int    `json:"code"`    // HTTP status code mapped
+		Message string `json:"message"` // Error message
+		Status  string `json:"status"`  // e.g., "UNAUTHENTICATED"
 	} `json:"error,omitempty"`
 }
 
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`        // 输入 token 数 (包含缓存内容)
+	CachedContentTokenCount int `json:"cachedContentTokenCount"` // 输入中来自缓存内容的 token 数
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`    // 输出 token 数
+}
+
+// usage 将 Gemini 的 usageMetadata 转换为通用的 Usage。
+func (u *geminiUsageMetadata) usage() Usage {
+	return Usage{InputTokens: u.PromptTokenCount, CachedInputTokens: u.CachedContentTokenCount, OutputTokens: u.CandidatesTokenCount}
+}
+
 // Translate 方法实现了 Translator 接口，用于 Gemini。
 // !!! 重要: 此实现基于 Gemini API v1beta 文档，务必进行实际测试和调整 !!!
-func (c *GeminiClient) Translate(ctx context.Context, markdownContent string) (string, error) {
-	// 步骤 1: 渲染 Prompt
-	var promptBuf bytes.Buffer
-	templateData := map[string]string{"Content": markdownContent}
-	if err := c.promptTmpl.Execute(&promptBuf, templateData); err != nil {
-		return "", fmt.Errorf("Gemini: 执行 Prompt 模板失败: %w", err)
-	}
-	finalPrompt := promptBuf.String()
-
-	// 步骤 2: 构建 Gemini API 请求体
+func (c *GeminiClient) Translate(ctx context.Context, prompt string) (*Result, error) {
+	// 步骤 1: 构建 Gemini API 请求体 (prompt 已由调用方通过 RenderPrompt 渲染)
 	apiRequest := geminiRequest{
 		Contents: []geminiContent{
 			{
 				// 对于简单的单轮请求，可以省略 'user' 角色
 				Parts: []geminiPart{
-					{Text: finalPrompt},
+					{Text: prompt},
 				},
 			},
 		},
@@ -138,97 +166,170 @@ func (c *GeminiClient) Translate(ctx context.Context, markdownContent string) (s
 		// },
 	}
 
-	reqBodyBytes, err := json.Marshal(apiRequest)
+	reqBodyBytes, err := c.reqOpts.encodeRequest(apiRequest, "generationConfig")
 	if err != nil {
-		return "", fmt.Errorf("Gemini: 序列化 API 请求失败: %w", err)
+		return nil, fmt.Errorf("Gemini: 序列化 API 请求失败: %w", err)
 	}
 
-	// 步骤 3: 创建并发送 HTTP 请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiEndpoint, bytes.NewBuffer(reqBodyBytes))
+	// 步骤 2: 创建并发送 HTTP 请求
+	// 流式模式下由空闲计时器代替整体超时: 超过 IdleTimeout 没有收到数据时取消请求
+	var watchdog *idleWatchdog
+	if c.stream {
+		ctx, watchdog = withIdleTimeout(ctx, c.streamOpts.IdleTimeout)
+		defer watchdog.stop()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewBuffer(reqBodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("Gemini: 创建 API 请求失败: %w", err)
+		return nil, fmt.Errorf("Gemini: 创建 API 请求失败: %w", err)
 	}
 
 	// 设置 Gemini 特有的请求参数 (API Key 通常作为 URL Query 参数)
 	q := req.URL.Query()
 	q.Add("key", c.apiKey)
+	if c.stream {
+		q.Set("alt", "sse")
+	}
 	req.URL.RawQuery = q.Encode()
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	if c.stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	c.reqOpts.applyHeaders(req)
 
-	log.Printf("Gemini: 发送请求到 %s\n", c.apiEndpoint) // API Key 在 URL 中，不直接打印
+	log.Printf("Gemini: 发送请求到 %s\n", c.endpoint()) // API Key 在 URL 中，不直接打印
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("Gemini: API 请求执行失败: %w", err)
+		if watchdog != nil {
+			err = watchdog.wrap(err)
+		}
+		return nil, fmt.Errorf("Gemini: API 请求执行失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 步骤 4: 读取并解码响应体
+	if c.stream && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return c.readStream(resp.Body, watchdog)
+	}
+
+	// 步骤 3: 读取并解码响应体
 	respBodyBytes, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return "", fmt.Errorf("Gemini: 读取 API 响应体失败 (状态码 %d): %w", resp.StatusCode, err)
-    }
+	if err != nil {
+		return nil, fmt.Errorf("Gemini: 读取 API 响应体失败 (状态码 %d): %w", resp.StatusCode, err)
+	}
+
+	// 步骤 4: 处理响应状态码和内容
+	// 非成功状态码 (认证失败、配额耗尽等) 统一包装为 APIError，由重试层决定是否重试
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError("Gemini", resp, respBodyBytes)
+	}
 
-	// 步骤 5: 处理响应状态码和内容
 	var apiResponse geminiResponse
 	if err := json.Unmarshal(respBodyBytes, &apiResponse); err != nil {
-		preview := string(respBodyBytes)
-		if len(preview) > 500 {
-			preview = preview[:500] + "..."
-		}
-		return "", fmt.Errorf("Gemini: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", resp.StatusCode, err, preview)
+		return nil, fmt.Errorf("Gemini: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", resp.StatusCode, err, previewBody(respBodyBytes))
 	}
 
 	// 检查顶层错误 (例如认证、权限问题)
-    if apiResponse.Error != nil {
-        return "", fmt.Errorf("Gemini: API 返回顶层错误: %s (Code: %d, Status: %s)", apiResponse.Error.Message, apiResponse.Error.Code, apiResponse.Error.Status)
-    }
-
-	// 检查 HTTP 状态码 (应该在检查顶层错误之后)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-        errMsg := fmt.Sprintf("Gemini: API 返回非成功状态码 %d", resp.StatusCode)
-		preview := string(respBodyBytes)
-		if len(preview) > 500 {
-			preview = preview[:500] + "..."
-		}
-		errMsg = fmt.Sprintf("%s. 响应体预览: %s", errMsg, preview)
-		return "", fmt.Errorf(errMsg)
+	if apiResponse.Error != nil {
+		return nil, fmt.Errorf("Gemini: API 返回顶层错误: %s (Code: %d, Status: %s)", apiResponse.Error.Message, apiResponse.Error.Code, apiResponse.Error.Status)
 	}
 
-
 	// 检查 Prompt 是否因安全等原因被阻止
 	if apiResponse.PromptFeedback != nil && apiResponse.PromptFeedback.BlockReason != "" {
-		return "", fmt.Errorf("Gemini: 请求被阻止，原因: %s", apiResponse.PromptFeedback.BlockReason)
+		return nil, fmt.Errorf("Gemini: 请求被阻止，原因: %s", apiResponse.PromptFeedback.BlockReason)
 	}
 
 	// 检查是否有候选结果以及完成原因是否正常
 	if len(apiResponse.Candidates) == 0 {
 		// 即使没有错误，也可能没有候选结果 (例如，prompt 被过滤但未报告 blockReason)
 		log.Printf("Gemini: API 响应不包含候选结果。PromptFeedback: %+v\n", apiResponse.PromptFeedback)
-		return "", fmt.Errorf("Gemini: API 响应未包含候选结果")
+		return nil, fmt.Errorf("Gemini: API 响应未包含候选结果")
 	}
 
 	// 检查第一个候选者的完成原因
 	finishReason := apiResponse.Candidates[0].FinishReason
 	if finishReason != "STOP" && finishReason != "MAX_TOKENS" {
 		// 其他原因如 "SAFETY", "RECITATION", "OTHER" 都表示有问题
-		return "", fmt.Errorf("Gemini: 生成因 '%s' 原因停止", finishReason)
+		return nil, fmt.Errorf("Gemini: 生成因 '%s' 原因停止", finishReason)
 	}
 
-	// 步骤 6: 提取翻译结果 (通常在第一个候选者的第一个 Part 中)
-	if len(apiResponse.Candidates[0].Content.Parts) == 0 || apiResponse.Candidates[0].Content.Parts[0].Text == "" {
-		log.Printf("Gemini: API 响应的候选结果中不包含有效文本内容。FinishReason: %s\n", finishReason)
-		return "", fmt.Errorf("Gemini: API 响应未包含有效翻译内容 (FinishReason: %s)", finishReason)
-	}
-
-	// 拼接所有 Parts (虽然通常只有一个)
+	// 步骤 5: 提取翻译结果 (通常在第一个候选者的第一个 Part 中)，拼接所有 Parts (虽然通常只有一个)
 	var builder strings.Builder
 	for _, part := range apiResponse.Candidates[0].Content.Parts {
 		builder.WriteString(part.Text)
 	}
 	translatedText := builder.String()
+	// MAX_TOKENS 表示输出达到了 maxOutputTokens 上限，译文不完整时视为截断
+	if err := truncated("Gemini", finishReason == "MAX_TOKENS", translatedText, geminiTruncationHint); err != nil {
+		return nil, err
+	}
+	if translatedText == "" {
+		log.Printf("Gemini: API 响应的候选结果中不包含有效文本内容。FinishReason: %s\n", finishReason)
+		return nil, fmt.Errorf("Gemini: API 响应未包含有效翻译内容 (FinishReason: %s)", finishReason)
+	}
 
 	log.Printf("Gemini: 成功接收并解析响应。\n")
 
-	return translatedText, nil
+	result := &Result{Text: translatedText}
+	if apiResponse.UsageMetadata != nil {
+		result.Usage = apiResponse.UsageMetadata.usage()
+	}
+	return result, nil
+}
+
+// readStream 读取 streamGenerateContent 的 SSE 响应。每个事件都是一个 geminiResponse，
+// 其中的候选结果只包含增量文本，usageMetadata 为截至当前的累计用量。检测到 </translate> 结束标签后提前结束读取。
+func (c *GeminiClient) readStream(body io.Reader, watchdog *idleWatchdog) (*Result, error) {
+	var text streamText
+	var usage Usage
+	finishReason := ""
+	err := readSSE(body, watchdog, func(_, data string) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("Gemini: 解码流式数据失败: %w. 数据预览: %s", err, previewBody([]byte(data)))
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("Gemini: API 返回顶层错误: %s (Code: %d, Status: %s)", chunk.Error.Message, chunk.Error.Code, chunk.Error.Status)
+		}
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			return fmt.Errorf("Gemini: 请求被阻止，原因: %s", chunk.PromptFeedback.BlockReason)
+		}
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata.usage()
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		candidate := chunk.Candidates[0]
+		if candidate.FinishReason != "" {
+			finishReason = candidate.FinishReason
+		}
+		for _, part := range candidate.Content.Parts {
+			if text.append(part.Text) {
+				text.stopped = true
+				return errStopStream
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Gemini: 读取流式响应失败: %w", err)
+	}
+
+	if !text.stopped && finishReason != "" && finishReason != "STOP" && finishReason != "MAX_TOKENS" {
+		return nil, fmt.Errorf("Gemini: 生成因 '%s' 原因停止", finishReason)
+	}
+	if err := truncated("Gemini", !text.stopped && finishReason == "MAX_TOKENS", text.String(), geminiTruncationHint); err != nil {
+		return nil, err
+	}
+	if text.String() == "" {
+		log.Printf("Gemini: 流式响应不包含有效文本内容。FinishReason: %s\n", finishReason)
+		return nil, fmt.Errorf("Gemini: API 响应未包含有效翻译内容 (FinishReason: %s)", finishReason)
+	}
+	if text.stopped {
+		log.Printf("Gemini: 已收到 </translate> 结束标签，提前结束流式响应。\n")
+	} else {
+		log.Printf("Gemini: 成功接收流式响应。\n")
+	}
+	return &Result{Text: text.String(), Usage: text.finishUsage(usage)}, nil
 }
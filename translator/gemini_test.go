@@ -0,0 +1,50 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// geminiServer 返回一个模拟 generateContent / streamGenerateContent 的服务器，返回 text 和 finishReason。
+func geminiServer(t *testing.T, text, finishReason string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		textJSON, _ := json.Marshal(text)
+		if !strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			fmt.Fprintf(w, `{"candidates":[{"content":{"parts":[{"text":%s}],"role":"model"},"finishReason":%q}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":7}}`, textJSON, finishReason)
+			return
+		}
+		if r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("流式请求缺少 alt=sse: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":%s}],\"role\":\"model\"}}]}\n\n", textJSON)
+		fmt.Fprintf(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"\"}],\"role\":\"model\"},\"finishReason\":%q}],\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":7}}\n\n", finishReason)
+	}))
+}
+
+func TestGeminiTruncation(t *testing.T) {
+	for _, tt := range truncationCases("STOP", "MAX_TOKENS") {
+		for _, stream := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stream=%t", tt.name, stream), func(t *testing.T) {
+				srv := geminiServer(t, tt.text, tt.reason)
+				defer srv.Close()
+				c, err := NewGeminiClient(srv.Client(), "key", srv.URL+"/v1beta/models/model:generateContent", "model")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stream {
+					c.EnableStreaming(StreamOptions{})
+				}
+
+				result, err := c.Translate(context.Background(), "prompt")
+				checkTruncation(t, tt, result, err)
+			})
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// 默认的本地 OpenAI 兼容端点 (llama.cpp server 的默认端口; vLLM 默认为 8000，Ollama 为 11434)
+const defaultLocalEndpoint = "http://localhost:8080/v1/chat/completions"
+
+// Preparer 由需要在开始翻译前检查服务状态的 Translator 实现，例如本地模型服务的健康检查和模型拉取。
+// NewTranslator 在创建具体实现后调用 Prepare，检查失败时程序不会开始翻译。
+type Preparer interface {
+	Prepare(ctx context.Context) error
+}
+
+// NewLocalClient 创建一个用于本地 OpenAI 兼容服务 (llama.cpp server、vLLM、LM Studio、Ollama 的 /v1 接口等) 的客户端。
+// 与 OpenAI 客户端使用相同的请求格式，但不需要 API 密钥: 未提供密钥时不发送 Authorization Header。
+// model 为空时，Prepare 会使用服务端 /v1/models 返回的第一个模型。
+func NewLocalClient(client *http.Client, apiKey, apiEndpoint, model string) (*LocalClient, error) {
+	if apiEndpoint == "" {
+		apiEndpoint = defaultLocalEndpoint
+	}
+	log.Printf("初始化本地 OpenAI 兼容客户端: Endpoint=%s, Model=%s\n", apiEndpoint, model)
+	return &LocalClient{OpenAIClient{
+		name:        "Local",
+		httpClient:  client,
+		apiKey:      apiKey,
+		apiEndpoint: apiEndpoint,
+		model:       model,
+	}}, nil
+}
+
+// LocalClient 是无需 API 密钥的 OpenAI 兼容客户端，并在启动时检查服务是否可用。
+type LocalClient struct {
+	OpenAIClient
+}
+
+// modelsURL 根据 chat/completions 端点推导出模型列表端点，例如 .../v1/chat/completions -> .../v1/models。
+func (c *LocalClient) modelsURL() string {
+	base := strings.TrimSuffix(strings.TrimRight(c.apiEndpoint, "/"), "/chat/completions")
+	return base + "/models"
+}
+
+// Prepare 请求 /v1/models 检查服务是否可用，并确认配置的模型已加载。
+// 未指定模型时使用服务端返回的第一个模型; 指定的模型不在列表中时只记录警告，因为部分服务支持别名。
+func (c *LocalClient) Prepare(ctx context.Context) error {
+	url := c.modelsURL()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("Local: 创建健康检查请求失败: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	c.reqOpts.applyHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Local: 无法连接本地模型服务 %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Local: 读取模型列表失败: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError("Local", resp, body)
+	}
+
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &models); err != nil {
+		return fmt.Errorf("Local: 解码模型列表失败: %w. 响应体预览: %s", err, previewBody(body))
+	}
+	ids := make([]string, len(models.Data))
+	for i, m := range models.Data {
+		ids[i] = m.ID
+	}
+
+	switch {
+	case c.model == "" && len(ids) == 0:
+		return fmt.Errorf("Local: 本地模型服务没有可用的模型，请通过 --model 指定模型")
+	case c.model == "":
+		c.model = ids[0]
+		log.Printf("Local: 未指定模型，使用服务端的第一个模型: %s\n", c.model)
+	case !slices.Contains(ids, c.model):
+		log.Printf("Local: 警告: 模型 %s 不在服务端的模型列表中 (%s)，将按原样发送\n", c.model, strings.Join(ids, ", "))
+	}
+	log.Printf("Local: 本地模型服务可用 (%s)\n", url)
+	return nil
+}
@@ -0,0 +1,227 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Ollama 服务的默认地址
+const defaultOllamaEndpoint = "http://localhost:11434"
+
+// OllamaOptions 是 Ollama 特有的运行参数。
+type OllamaOptions struct {
+	KeepAlive     string // 请求结束后模型在内存中保留的时间，例如 "10m"、"-1" (一直保留)、"0" (立即卸载); 空表示使用服务端默认值
+	ContextLength int    // 上下文长度 (num_ctx)，0 表示使用模型默认值
+	PullModel     bool   // 启动时模型不存在是否自动拉取
+}
+
+// OllamaClient 结构体实现了 Translator 接口，使用 Ollama 的原生 /api/chat 接口。
+type OllamaClient struct {
+	httpClient *http.Client // 共享的 HTTP 客户端
+	baseURL    string       // Ollama 服务地址，例如 http://localhost:11434
+	model      string       // 使用的模型名称，例如 "qwen2.5:14b"
+	opts       OllamaOptions
+	reqOpts    RequestOptions // 附加的生成参数 (合并到 options) 和 Header
+}
+
+// SetRequestOptions 设置附加的生成参数和 Header (实现 Customizer 接口)。
+// Ollama 的生成参数位于请求体的 options 中，例如 temperature、top_p、num_predict。
+func (c *OllamaClient) SetRequestOptions(opts RequestOptions) {
+	c.reqOpts = opts
+}
+
+// NewOllamaClient 创建一个新的 Ollama 客户端实例。Ollama 不需要 API 密钥，但必须指定模型。
+// apiEndpoint 可以是服务地址，也可以是完整的 /api/chat 地址。
+func NewOllamaClient(client *http.Client, apiEndpoint, model string, opts OllamaOptions) (*OllamaClient, error) {
+	if model == "" {
+		return nil, fmt.Errorf("Ollama 提供商必须指定模型 (--model)，例如 qwen2.5:14b")
+	}
+	if apiEndpoint == "" {
+		apiEndpoint = defaultOllamaEndpoint
+	}
+	baseURL := strings.TrimSuffix(strings.TrimRight(apiEndpoint, "/"), "/api/chat")
+	log.Printf("初始化 Ollama 客户端: Endpoint=%s, Model=%s, KeepAlive=%q, ContextLength=%d\n", baseURL, model, opts.KeepAlive, opts.ContextLength)
+	return &OllamaClient{
+		httpClient: client,
+		baseURL:    baseURL,
+		model:      model,
+		opts:       opts,
+	}, nil
+}
+
+// --- Ollama API 特有的请求和响应结构体 ---
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []openAIMessage `json:"messages"` // 与 OpenAI 的消息格式相同
+	Stream    bool            `json:"stream"`   // 必须显式设为 false，Ollama 默认使用流式响应
+	KeepAlive any             `json:"keep_alive,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`       // 完成原因，如 "stop", "length"
+	PromptEvalCount int    `json:"prompt_eval_count"` // 输入 token 数
+	EvalCount       int    `json:"eval_count"`        // 输出 token 数
+	Error           string `json:"error,omitempty"`
+}
+
+// keepAlive 返回 keep_alive 字段的 JSON 值。Ollama 接受时长字符串 ("10m") 或表示秒数的数字 (-1、0)。
+func (c *OllamaClient) keepAlive() any {
+	if c.opts.KeepAlive == "" {
+		return nil
+	}
+	if secs, err := strconv.Atoi(c.opts.KeepAlive); err == nil {
+		return secs
+	}
+	return c.opts.KeepAlive
+}
+
+// Translate 方法实现了 Translator 接口，用于 Ollama。
+func (c *OllamaClient) Translate(ctx context.Context, prompt string) (*Result, error) {
+	// 步骤 1: 构建 Ollama API 请求体
+	apiRequest := ollamaChatRequest{
+		Model:     c.model,
+		Messages:  []openAIMessage{{Role: "user", Content: prompt}},
+		KeepAlive: c.keepAlive(),
+	}
+	if c.opts.ContextLength > 0 || len(c.reqOpts.Params) > 0 {
+		apiRequest.Options = map[string]any{}
+		if c.opts.ContextLength > 0 {
+			apiRequest.Options["num_ctx"] = c.opts.ContextLength
+		}
+		for k, v := range c.reqOpts.Params {
+			apiRequest.Options[k] = v
+		}
+	}
+
+	var apiResponse ollamaChatResponse
+	body, err := c.post(ctx, "/api/chat", apiRequest, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// 步骤 2: 提取翻译结果
+	if apiResponse.Error != "" {
+		return nil, fmt.Errorf("Ollama: API 返回错误: %s", apiResponse.Error)
+	}
+	// 完成原因为 "length" 表示输出达到了上下文长度 (或 num_predict) 的限制
+	if err := truncated("Ollama", apiResponse.DoneReason == "length", apiResponse.Message.Content, "可增大上下文长度 --num-ctx 或减小片段大小 --chunk-size"); err != nil {
+		return nil, err
+	}
+	if apiResponse.Message.Content == "" {
+		log.Printf("Ollama: API 响应不包含有效内容。完成原因: %s, 响应体预览: %s\n", apiResponse.DoneReason, previewBody(body))
+		return nil, fmt.Errorf("Ollama: API 响应未包含有效翻译内容 (完成原因: %s)", apiResponse.DoneReason)
+	}
+	log.Printf("Ollama: 成功接收并解析响应。\n")
+
+	return &Result{
+		Text:  apiResponse.Message.Content,
+		Usage: Usage{InputTokens: apiResponse.PromptEvalCount, OutputTokens: apiResponse.EvalCount},
+	}, nil
+}
+
+// Prepare 检查 Ollama 服务是否可用，并确认模型已存在; 模型不存在且启用了 PullModel 时自动拉取。
+func (c *OllamaClient) Prepare(ctx context.Context) error {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := c.get(ctx, "/api/version", &version); err != nil {
+		return fmt.Errorf("Ollama: 无法连接 Ollama 服务 %s (请确认 ollama serve 正在运行): %w", c.baseURL, err)
+	}
+	log.Printf("Ollama: 服务可用 (版本 %s)\n", version.Version)
+
+	_, err := c.post(ctx, "/api/show", map[string]string{"model": c.model}, nil)
+	if err == nil {
+		log.Printf("Ollama: 模型 %s 已存在\n", c.model)
+		return nil
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("Ollama: 查询模型 %s 失败: %w", c.model, err)
+	}
+	if !c.opts.PullModel {
+		return fmt.Errorf("Ollama: 模型 %s 不存在，请先运行 ollama pull %s (或启用 --pull)", c.model, c.model)
+	}
+
+	// 拉取模型可能需要很长时间，不受单次请求超时限制，但仍可通过 ctx 取消 (Ctrl+C)
+	log.Printf("Ollama: 模型 %s 不存在，正在拉取 (可能需要较长时间)...\n", c.model)
+	pullClient := *c.httpClient
+	pullClient.Timeout = 0
+	var status struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if _, err := c.do(ctx, &pullClient, http.MethodPost, "/api/pull", map[string]any{"model": c.model, "stream": false}, &status); err != nil {
+		return fmt.Errorf("Ollama: 拉取模型 %s 失败: %w", c.model, err)
+	}
+	if status.Error != "" {
+		return fmt.Errorf("Ollama: 拉取模型 %s 失败: %s", c.model, status.Error)
+	}
+	log.Printf("Ollama: 模型 %s 拉取完成 (%s)\n", c.model, status.Status)
+	return nil
+}
+
+// get 发送 GET 请求并将 JSON 响应解码到 out。
+func (c *OllamaClient) get(ctx context.Context, path string, out any) error {
+	_, err := c.do(ctx, c.httpClient, http.MethodGet, path, nil, out)
+	return err
+}
+
+// post 发送 JSON POST 请求并将响应解码到 out (out 为 nil 时不解码)，返回原始响应体。
+func (c *OllamaClient) post(ctx context.Context, path string, in, out any) ([]byte, error) {
+	return c.do(ctx, c.httpClient, http.MethodPost, path, in, out)
+}
+
+// do 发送请求到 Ollama 服务。非成功状态码返回 *APIError，由重试层判断是否可以重试。
+func (c *OllamaClient) do(ctx context.Context, client *http.Client, method, path string, in, out any) ([]byte, error) {
+	var reqBody io.Reader
+	if in != nil {
+		reqBodyBytes, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("Ollama: 序列化 API 请求失败: %w", err)
+		}
+		reqBody = bytes.NewReader(reqBodyBytes)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama: 创建 API 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	c.reqOpts.applyHeaders(req)
+
+	if path == "/api/chat" {
+		log.Printf("Ollama: 发送请求到 %s (模型: %s)\n", c.baseURL+path, c.model)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama: API 请求执行失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama: 读取 API 响应体失败 (状态码 %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError("Ollama", resp, respBodyBytes)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBodyBytes, out); err != nil {
+			return nil, fmt.Errorf("Ollama: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", resp.StatusCode, err, previewBody(respBodyBytes))
+		}
+	}
+	return respBodyBytes, nil
+}
@@ -0,0 +1,38 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ollamaServer 返回一个模拟 /api/chat 的服务器，返回 text 和 doneReason。
+func ollamaServer(t *testing.T, text, doneReason string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("请求路径 = %s, 期望 /api/chat", r.URL.Path)
+		}
+		textJSON, _ := json.Marshal(text)
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":%s},"done":true,"done_reason":%q,"prompt_eval_count":5,"eval_count":7}`, textJSON, doneReason)
+	}))
+}
+
+func TestOllamaTruncation(t *testing.T) {
+	for _, tt := range truncationCases("stop", "length") {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ollamaServer(t, tt.text, tt.reason)
+			defer srv.Close()
+			c, err := NewOllamaClient(srv.Client(), srv.URL, "model", OllamaOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := c.Translate(context.Background(), "prompt")
+			checkTruncation(t, tt, result, err)
+		})
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"io" // 导入 io 包
 	"log"
 	"net/http"
-	"text/template"
 )
 
 const (
@@ -16,19 +15,35 @@ const (
 	defaultOpenAIEndpoint = "https://api.openai.com/v1/chat/completions"
 	// 默认使用的 OpenAI 模型
 	defaultOpenAIModel = "gpt-3.5-turbo" // 或者选择更新的默认模型，如 gpt-4o-mini
+	// 输出达到 max_tokens 被截断时的处理建议
+	openAITruncationHint = "可在 [api.params] 中增大 max_tokens 或减小 --chunk-size"
 )
 
 // OpenAIClient 结构体实现了 Translator 接口，用于与 OpenAI API 进行交互。
 type OpenAIClient struct {
-	httpClient  *http.Client       // 共享的 HTTP 客户端
-	apiKey      string             // OpenAI API 密钥
-	apiEndpoint string             // 使用的 API 端点 URL
-	model       string             // 使用的模型名称
-	promptTmpl  *template.Template // 已解析的 Prompt 模板
+	name        string       // 日志和错误信息中使用的提供商名称
+	httpClient  *http.Client // 共享的 HTTP 客户端
+	apiKey      string       // OpenAI API 密钥
+	apiEndpoint string       // 使用的 API 端点 URL
+	model       string       // 使用的模型名称
+	stream      bool         // 是否使用 SSE 流式响应
+	streamOpts  StreamOptions
+	reqOpts     RequestOptions // 附加的生成参数和 Header
+}
+
+// EnableStreaming 启用 SSE 流式响应 (实现 Streamer 接口)。
+func (c *OpenAIClient) EnableStreaming(opts StreamOptions) {
+	c.stream = true
+	c.streamOpts = opts
+}
+
+// SetRequestOptions 设置附加的生成参数和 Header (实现 Customizer 接口)。
+func (c *OpenAIClient) SetRequestOptions(opts RequestOptions) {
+	c.reqOpts = opts
 }
 
 // NewOpenAIClient 创建一个新的 OpenAI 客户端实例。
-func NewOpenAIClient(client *http.Client, apiKey, apiEndpoint, model string, promptTmpl *template.Template) (*OpenAIClient, error) {
+func NewOpenAIClient(client *http.Client, apiKey, apiEndpoint, model string) (*OpenAIClient, error) {
 	// 校验必需的 API Key
 	if apiKey == "" {
 		return nil, fmt.Errorf("OpenAI API 密钥不能为空")
@@ -43,11 +58,11 @@ func NewOpenAIClient(client *http.Client, apiKey, apiEndpoint, model string, pro
 	}
 	log.Printf("初始化 OpenAI 客户端: Endpoint=%s, Model=%s\n", apiEndpoint, model)
 	return &OpenAIClient{
+		name:        "OpenAI",
 		httpClient:  client,
 		apiKey:      apiKey,
 		apiEndpoint: apiEndpoint,
 		model:       model,
-		promptTmpl:  promptTmpl,
 	}, nil
 }
 
@@ -57,6 +72,30 @@ type openAIRequest struct {
 	Messages    []openAIMessage `json:"messages"`              // 对话消息列表
 	Temperature float64         `json:"temperature,omitempty"` // 可选参数：控制创造性，0 表示更确定性
 	MaxTokens   int             `json:"max_tokens,omitempty"`  // 可选参数：限制生成内容的最大长度
+	// 流式响应: stream_options.include_usage 使最后一个数据块携带 token 用量
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`     // 输入 token 数 (包含命中缓存的部分)
+	CompletionTokens    int `json:"completion_tokens"` // 输出 token 数
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"` // 命中提示缓存的输入 token 数
+	} `json:"prompt_tokens_details,omitempty"`
+}
+
+// usage 将 OpenAI 的 usage 结构转换为通用的 Usage。
+func (u *openAIUsage) usage() Usage {
+	result := Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+	if u.PromptTokensDetails != nil {
+		result.CachedInputTokens = u.PromptTokensDetails.CachedTokens
+	}
+	return result
 }
 
 type openAIMessage struct {
@@ -78,109 +117,182 @@ type openAIResponse struct {
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"` // 完成原因，如 "stop", "length"
 	} `json:"choices"`
+	Usage *openAIUsage       `json:"usage,omitempty"` // token 使用情况 (部分兼容接口可能不返回)
 	Error *openAIErrorDetail `json:"error,omitempty"` // API 返回的错误信息结构
 }
 
-// Translate 方法实现了 Translator 接口，用于 OpenAI。
-func (c *OpenAIClient) Translate(ctx context.Context, markdownContent string) (string, error) {
-	// 步骤 1: 使用模板渲染最终的 Prompt
-	var promptBuf bytes.Buffer
-	templateData := map[string]string{"Content": markdownContent}
-	if err := c.promptTmpl.Execute(&promptBuf, templateData); err != nil {
-		return "", fmt.Errorf("OpenAI: 执行 Prompt 模板失败: %w", err)
-	}
-	finalPrompt := promptBuf.String()
+// openAIStreamChunk 是流式响应中每个 data 事件的结构 (chat.completion.chunk)。
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"` // 本次增量生成的内容
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage       `json:"usage,omitempty"` // 仅在最后一个数据块中出现
+	Error *openAIErrorDetail `json:"error,omitempty"`
+}
 
-	// 步骤 2: 构建 OpenAI API 请求体
+// Translate 方法实现了 Translator 接口，用于 OpenAI 及兼容接口。
+func (c *OpenAIClient) Translate(ctx context.Context, prompt string) (*Result, error) {
+	// 步骤 1: 构建 OpenAI API 请求体 (prompt 已由调用方通过 RenderPrompt 渲染)
 	apiRequest := openAIRequest{
 		Model: c.model,
 		Messages: []openAIMessage{
 			// OpenAI 通常将用户的主要输入放在 "user" 角色的消息中
-			{Role: "user", Content: finalPrompt},
+			{Role: "user", Content: prompt},
 			// 如果需要，可以在这里添加 "system" 角色的消息
 			// {Role: "system", Content: "You are translating markdown pages."},
 		},
 		// Temperature: 0.7, // 如果需要，在这里设置其他参数
 	}
+	if c.stream {
+		apiRequest.Stream = true
+		apiRequest.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
 
-	reqBodyBytes, err := json.Marshal(apiRequest)
+	reqBodyBytes, err := c.reqOpts.encodeRequest(apiRequest, "")
 	if err != nil {
-		return "", fmt.Errorf("OpenAI: 序列化 API 请求失败: %w", err)
+		return nil, fmt.Errorf("%s: 序列化 API 请求失败: %w", c.name, err)
 	}
 
-	// 步骤 3: 创建并发送 HTTP POST 请求
+	// 步骤 2: 创建并发送 HTTP POST 请求
+	// 流式模式下由空闲计时器代替整体超时: 超过 IdleTimeout 没有收到数据时取消请求
+	var watchdog *idleWatchdog
+	if c.stream {
+		ctx, watchdog = withIdleTimeout(ctx, c.streamOpts.IdleTimeout)
+		defer watchdog.stop()
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiEndpoint, bytes.NewBuffer(reqBodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("OpenAI: 创建 API 请求失败: %w", err)
+		return nil, fmt.Errorf("%s: 创建 API 请求失败: %w", c.name, err)
 	}
 
-	// 设置必要的 HTTP Headers (Authorization 使用 Bearer Token)
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	// 设置必要的 HTTP Headers (Authorization 使用 Bearer Token; 无需密钥的本地服务不发送)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	if c.stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	c.reqOpts.applyHeaders(req)
 
-	log.Printf("OpenAI: 发送请求到 %s (模型: %s)\n", c.apiEndpoint, c.model)
+	log.Printf("%s: 发送请求到 %s (模型: %s)\n", c.name, c.apiEndpoint, c.model)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		// 处理网络层面的错误 (如超时、连接失败)
-		return "", fmt.Errorf("OpenAI: API 请求执行失败: %w", err)
+		if watchdog != nil {
+			err = watchdog.wrap(err)
+		}
+		return nil, fmt.Errorf("%s: API 请求执行失败: %w", c.name, err)
 	}
 	defer resp.Body.Close()
 
-	// 步骤 4: 读取并解码 API 响应体
+	if c.stream && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return c.readStream(resp.Body, watchdog)
+	}
+
+	// 步骤 3: 读取并解码 API 响应体
 	respBodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI: 读取 API 响应体失败 (状态码 %d): %w", resp.StatusCode, err)
+		return nil, fmt.Errorf("%s: 读取 API 响应体失败 (状态码 %d): %w", c.name, resp.StatusCode, err)
+	}
+
+	// 步骤 4: 处理响应状态码和内容
+	// 非成功状态码统一包装为 APIError，由重试层根据状态码和 Retry-After 决定是否重试
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.name, resp, respBodyBytes)
 	}
 
-	// 步骤 5: 处理响应状态码和内容
 	var apiResponse openAIResponse
-	// 尝试解码 JSON，即使状态码可能是错误的，因为错误信息也可能在 JSON 体中
 	if err := json.Unmarshal(respBodyBytes, &apiResponse); err != nil {
 		// 如果解码失败，返回原始状态码和部分响应体以供调试
-		preview := string(respBodyBytes)
-		if len(preview) > 500 {
-			preview = preview[:500] + "..."
-		}
-		return "", fmt.Errorf("OpenAI: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", resp.StatusCode, err, preview)
+		return nil, fmt.Errorf("%s: 解码 API 响应失败 (状态码 %d): %w. 响应体预览: %s", c.name, resp.StatusCode, err, previewBody(respBodyBytes))
 	}
 
-	// 检查响应体中是否包含 API 级别的错误信息
+	// 部分兼容接口即使返回 2xx，也可能在响应体中携带错误信息
 	if apiResponse.Error != nil {
-		return "", fmt.Errorf("OpenAI: API 返回错误: %s (类型: %s, Code: %v)", apiResponse.Error.Message, apiResponse.Error.Type, apiResponse.Error.Code)
+		return nil, fmt.Errorf("%s: API 返回错误: %s (类型: %s, Code: %v)", c.name, apiResponse.Error.Message, apiResponse.Error.Type, apiResponse.Error.Code)
 	}
 
-	// 检查 HTTP 状态码是否表示成功 (2xx)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// 如果有错误结构体但之前未返回，这里可以再次尝试使用它提供信息
-		errMsg := fmt.Sprintf("OpenAI: API 返回非成功状态码 %d", resp.StatusCode)
-		if apiResponse.Error != nil { // 确认错误结构体已填充
-			errMsg = fmt.Sprintf("%s - %s", errMsg, apiResponse.Error.Message)
-		} else {
-			preview := string(respBodyBytes)
-			if len(preview) > 500 {
-				preview = preview[:500] + "..."
-			}
-			errMsg = fmt.Sprintf("%s. 响应体预览: %s", errMsg, preview)
+	// 步骤 5: 提取翻译结果
+	// 完成原因为 "length" 表示输出达到了 max_tokens 上限 (或模型的上下文长度)
+	if len(apiResponse.Choices) > 0 {
+		choice := apiResponse.Choices[0]
+		if err := truncated(c.name, choice.FinishReason == "length", choice.Message.Content, openAITruncationHint); err != nil {
+			return nil, err
 		}
-		return "", fmt.Errorf(errMsg)
 	}
-
-	// 步骤 6: 提取翻译结果
 	if len(apiResponse.Choices) == 0 || apiResponse.Choices[0].Message.Content == "" {
 		// 可能是因为内容过滤或其他原因导致没有有效输出
 		finishReason := "未知"
 		if len(apiResponse.Choices) > 0 {
 			finishReason = apiResponse.Choices[0].FinishReason
 		}
-		log.Printf("OpenAI: API 响应不包含有效内容。完成原因: %s\n", finishReason)
-		return "", fmt.Errorf("OpenAI: API 响应未包含有效翻译内容 (完成原因: %s)", finishReason)
+		log.Printf("%s: API 响应不包含有效内容。完成原因: %s\n", c.name, finishReason)
+		return nil, fmt.Errorf("%s: API 响应未包含有效翻译内容 (完成原因: %s)", c.name, finishReason)
 	}
 
 	translatedText := apiResponse.Choices[0].Message.Content
-	log.Printf("OpenAI: 成功接收并解析响应。\n")
+	log.Printf("%s: 成功接收并解析响应。\n", c.name)
 
 	// 注意: 从这里返回的是 LLM 的原始输出。
 	// <translate> 标签的提取将在调用此函数之后 (在 processor/worker.go 中) 进行。
-	return translatedText, nil
+	result := &Result{Text: translatedText}
+	if apiResponse.Usage != nil {
+		result.Usage = apiResponse.Usage.usage()
+	}
+	return result, nil
+}
+
+// readStream 读取 SSE 流式响应，拼接增量内容。检测到 </translate> 结束标签后提前结束读取。
+func (c *OpenAIClient) readStream(body io.Reader, watchdog *idleWatchdog) (*Result, error) {
+	var text streamText
+	var usage Usage
+	finishReason := ""
+	err := readSSE(body, watchdog, func(_, data string) error {
+		if data == "[DONE]" {
+			return errStopStream
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("%s: 解码流式数据失败: %w. 数据预览: %s", c.name, err, previewBody([]byte(data)))
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("%s: API 返回错误: %s (类型: %s, Code: %v)", c.name, chunk.Error.Message, chunk.Error.Type, chunk.Error.Code)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			if text.append(choice.Delta.Content) {
+				text.stopped = true
+				return errStopStream
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: 读取流式响应失败: %w", c.name, err)
+	}
+
+	if err := truncated(c.name, finishReason == "length", text.String(), openAITruncationHint); err != nil {
+		return nil, err
+	}
+	if text.String() == "" {
+		log.Printf("%s: 流式响应不包含有效内容。完成原因: %s\n", c.name, finishReason)
+		return nil, fmt.Errorf("%s: API 响应未包含有效翻译内容 (完成原因: %s)", c.name, finishReason)
+	}
+	if text.stopped {
+		log.Printf("%s: 已收到 </translate> 结束标签，提前结束流式响应。\n", c.name)
+	} else {
+		log.Printf("%s: 成功接收流式响应。\n", c.name)
+	}
+	return &Result{Text: text.String(), Usage: text.finishUsage(usage)}, nil
 }
@@ -0,0 +1,56 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// openAIServer 返回一个模拟 Chat Completions API 的服务器: 按请求是否为流式返回 text 和 finishReason。
+func openAIServer(t *testing.T, text, finishReason string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("解析请求体失败: %v", err)
+		}
+		textJSON, _ := json.Marshal(text)
+		if !req.Stream {
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%s},"finish_reason":%q}],"usage":{"prompt_tokens":5,"completion_tokens":7}}`, textJSON, finishReason)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%s}}]}\n\n", textJSON)
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":%q}]}\n\n", finishReason)
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":7}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestOpenAITruncation(t *testing.T) {
+	for _, tt := range truncationCases("stop", "length") {
+		for _, stream := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stream=%t", tt.name, stream), func(t *testing.T) {
+				srv := openAIServer(t, tt.text, tt.reason)
+				defer srv.Close()
+				c, err := NewOpenAIClient(srv.Client(), "key", srv.URL, "model")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stream {
+					c.EnableStreaming(StreamOptions{})
+				}
+
+				result, err := c.Translate(context.Background(), "prompt")
+				checkTruncation(t, tt, result, err)
+			})
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RequestOptions 是附加到每个 API 请求的生成参数和 HTTP Header (来自提供商配置，见 config.ProviderProfile)。
+type RequestOptions struct {
+	Params  map[string]any    // 生成参数，如 temperature、top_p、max_tokens，合并到请求体中 (覆盖同名字段)
+	Headers map[string]string // 附加的 HTTP Header (覆盖同名的默认 Header)
+}
+
+// Customizer 由支持附加生成参数和 Header 的 Translator 实现。
+// NewTranslator 在创建具体实现后调用 SetRequestOptions。
+type Customizer interface {
+	SetRequestOptions(opts RequestOptions)
+}
+
+// applyHeaders 将附加的 Header 设置到请求上。
+func (o RequestOptions) applyHeaders(req *http.Request) {
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// encodeRequest 序列化请求体，并将生成参数合并到 section 指定的子对象中 (section 为空表示顶层)，
+// 例如 Gemini 的参数位于 "generationConfig"，Ollama 的参数位于 "options"。
+func (o RequestOptions) encodeRequest(v any, section string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(o.Params) == 0 {
+		return data, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	target := body
+	if section != "" {
+		sub, ok := body[section].(map[string]any)
+		if !ok {
+			if body[section] != nil {
+				return nil, fmt.Errorf("请求体中的 %s 不是对象，无法合并生成参数", section)
+			}
+			sub = make(map[string]any)
+			body[section] = sub
+		}
+		target = sub
+	}
+	for k, v := range o.Params {
+		target[k] = v
+	}
+	return json.Marshal(body)
+}
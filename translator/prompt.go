@@ -0,0 +1,28 @@
+package translator
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// PromptData 是渲染 Prompt 模板时可以引用的数据，例如模板中的 {{.Content}}。
+type PromptData struct {
+	Content    string // 待翻译的 Markdown 内容
+	Context    string // 上一个片段的原文，仅供 LLM 理解上下文 (文档未切分时为空)
+	Protected  bool   // Content 中是否包含 @@MT001@@ 形式的受保护内容占位符
+	Glossary   string // Content 中出现的术语及其规定译法，每行一个 (未使用术语表或没有出现术语时为空)
+	References string // 翻译记忆中与 Content 的段落相似的原文及其译文，每行一个 (没有模糊匹配时为空)
+	SourceLang string // 源语言名称，例如 "English"
+	TargetLang string // 目标语言名称，例如 "Simplified Chinese"
+}
+
+// RenderPrompt 使用已解析的模板渲染最终发送给 LLM 的 Prompt。
+// 渲染在调用 Translate 之前完成，这样限流、缓存等装饰器都能看到完整的 Prompt。
+func RenderPrompt(tmpl *template.Template, data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("执行 Prompt 模板失败: %w", err)
+	}
+	return buf.String(), nil
+}
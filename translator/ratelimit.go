@@ -0,0 +1,188 @@
+package translator
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RateLimiter 是所有 Worker 共享的客户端限流器，同时限制每分钟请求数 (RPM) 和每分钟 token 数 (TPM)。
+// 两个维度各使用一个令牌桶，桶容量等于每分钟配额，按配额匀速补充。
+// 采用 "预留" 方式: 请求先扣除令牌 (允许为负)，再等待令牌桶回到非负，保证先到先得。
+type RateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket // 为 nil 表示不限制 RPM
+	tokens   *tokenBucket // 为 nil 表示不限制 TPM
+}
+
+// tokenBucket 是一个简单的令牌桶。
+type tokenBucket struct {
+	capacity float64   // 桶容量 (每分钟配额)
+	rate     float64   // 每秒补充的令牌数
+	level    float64   // 当前令牌数，预留后可能为负
+	last     time.Time // 上次补充的时间
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		level:    float64(perMinute),
+		last:     now,
+	}
+}
+
+// refill 根据距上次补充经过的时间补充令牌，最多补满。
+func (b *tokenBucket) refill(now time.Time) {
+	b.level += now.Sub(b.last).Seconds() * b.rate
+	if b.level > b.capacity {
+		b.level = b.capacity
+	}
+	b.last = now
+}
+
+// wait 返回令牌数回到非负所需的等待时间。
+func (b *tokenBucket) wait() time.Duration {
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(-b.level / b.rate * float64(time.Second))
+}
+
+// NewRateLimiter 创建一个限流器。rpm 或 tpm 小于等于 0 表示不限制对应维度，两者都不限制时返回 nil。
+func NewRateLimiter(rpm, tpm int) *RateLimiter {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &RateLimiter{
+		requests: newTokenBucket(rpm, now),
+		tokens:   newTokenBucket(tpm, now),
+	}
+}
+
+// Wait 为一次预计消耗 estimatedTokens 个 token 的请求预留配额，并阻塞直到配额可用或 ctx 被取消。
+// ctx 被取消时已预留的配额会被归还。
+func (l *RateLimiter) Wait(ctx context.Context, estimatedTokens int) error {
+	l.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if l.requests != nil {
+		l.requests.refill(now)
+		l.requests.level--
+		delay = max(delay, l.requests.wait())
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		l.tokens.level -= float64(estimatedTokens)
+		delay = max(delay, l.tokens.wait())
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	log.Printf("限流: 等待 %v 以满足 RPM/TPM 配额...\n", delay.Round(time.Millisecond))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.release(1, estimatedTokens)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Adjust 在拿到实际用量后修正 TPM 令牌桶: delta 为正表示实际消耗多于预估，为负表示归还多扣的部分。
+func (l *RateLimiter) Adjust(delta int) {
+	if l.tokens == nil || delta == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens.refill(time.Now())
+	l.tokens.level -= float64(delta)
+	if l.tokens.level > l.tokens.capacity {
+		l.tokens.level = l.tokens.capacity
+	}
+}
+
+// release 归还已预留但未实际使用的配额。
+func (l *RateLimiter) release(requests, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.requests != nil {
+		l.requests.refill(now)
+		l.requests.level = min(l.requests.capacity, l.requests.level+float64(requests))
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		l.tokens.level = min(l.tokens.capacity, l.tokens.level+float64(tokens))
+	}
+}
+
+// EstimateTokens 粗略估算文本的 token 数: ASCII 字符约 4 个对应 1 个 token，
+// 中日韩等非 ASCII 字符约 1 个字符对应 1 个 token。用于请求发出前的预估，偏保守即可。
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// RateLimitedTranslator 是 Translator 的装饰器，在每次调用被包装的 Translator 之前向共享的 RateLimiter 申请配额。
+// 它应位于重试装饰器的内层，使每一次重试都同样受限流约束。
+type RateLimitedTranslator struct {
+	inner   Translator
+	limiter *RateLimiter
+}
+
+// NewRateLimitedTranslator 创建一个包装 inner 的限流装饰器。
+func NewRateLimitedTranslator(inner Translator, limiter *RateLimiter) *RateLimitedTranslator {
+	return &RateLimitedTranslator{inner: inner, limiter: limiter}
+}
+
+// Translate 方法实现了 Translator 接口。
+// 发送前按 Prompt 长度预估 token (输入 + 与输入等量的输出)，拿到响应后用 usage 修正预估值。
+func (t *RateLimitedTranslator) Translate(ctx context.Context, prompt string) (*Result, error) {
+	promptTokens := EstimateTokens(prompt)
+	estimated := promptTokens * 2
+	if err := t.limiter.Wait(ctx, estimated); err != nil {
+		return nil, err
+	}
+
+	result, err := t.inner.Translate(ctx, prompt)
+	if err != nil {
+		// 请求失败时没有产生输出，只保留请求次数的消耗，归还预估的 token
+		t.limiter.release(0, estimated)
+		return nil, err
+	}
+
+	actual := result.Usage.Total()
+	if actual == 0 {
+		// 提供商未返回 usage 时，用实际输出长度修正
+		actual = promptTokens + EstimateTokens(result.Text)
+	}
+	t.limiter.Adjust(actual - estimated)
+	return result, nil
+}
+
+// Close 如果被包装的 Translator 支持关闭，则将调用转发给它。
+func (t *RateLimitedTranslator) Close() error {
+	if closer, ok := t.inner.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
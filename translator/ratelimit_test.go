@@ -0,0 +1,137 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	if newTokenBucket(0, start) != nil {
+		t.Error("配额为 0 时不应限制")
+	}
+	b := newTokenBucket(60, start) // 每秒补充 1 个
+	if b.level != 60 || b.wait() != 0 {
+		t.Fatalf("新的令牌桶应是满的: level = %v, wait = %v", b.level, b.wait())
+	}
+
+	steps := []struct {
+		elapsed   time.Duration // 距 start 的时间
+		take      float64
+		wantLevel float64
+		wantWait  time.Duration
+	}{
+		{0, 50, 10, 0},
+		{5 * time.Second, 0, 15, 0},                // 补充 5 个
+		{5 * time.Second, 20, -5, 5 * time.Second}, // 预留后为负，需要等待补充
+		{8 * time.Second, 0, -2, 2 * time.Second},
+		{10 * time.Minute, 0, 60, 0}, // 最多补满
+	}
+	for i, s := range steps {
+		b.refill(start.Add(s.elapsed))
+		b.level -= s.take
+		if math.Abs(b.level-s.wantLevel) > 1e-9 || b.wait() != s.wantWait {
+			t.Errorf("第 %d 步: level = %v, wait = %v, 期望 %v, %v", i+1, b.level, b.wait(), s.wantLevel, s.wantWait)
+		}
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	if NewRateLimiter(0, 0) != nil {
+		t.Error("RPM 和 TPM 都不限制时应返回 nil")
+	}
+	l := NewRateLimiter(10, 0)
+	if l.requests == nil || l.tokens != nil {
+		t.Errorf("只限制 RPM: requests = %v, tokens = %v", l.requests, l.tokens)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(600, 6000)
+	if err := l.Wait(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if l.requests.level > 599.1 || l.tokens.level > 5000.1 {
+		t.Errorf("预留后 requests = %v, tokens = %v, 期望约 599 和 5000", l.requests.level, l.tokens.level)
+	}
+
+	// 配额不足时等待; ctx 被取消则归还预留的配额
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, 100000); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() 错误 = %v, 期望 context.Canceled", err)
+	}
+	if l.requests.level < 598.9 || l.tokens.level < 4999.9 {
+		t.Errorf("取消后 requests = %v, tokens = %v, 期望归还预留的配额", l.requests.level, l.tokens.level)
+	}
+
+	// 实际用量多于预估时扣除差额，少于预估时归还 (不超过容量)
+	l.Adjust(500)
+	if l.tokens.level > 4500.1 {
+		t.Errorf("Adjust(500) 后 tokens = %v, 期望约 4500", l.tokens.level)
+	}
+	l.Adjust(-100000)
+	if l.tokens.level != l.tokens.capacity {
+		t.Errorf("Adjust(-100000) 后 tokens = %v, 期望不超过容量 %v", l.tokens.level, l.tokens.capacity)
+	}
+}
+
+// usageTranslator 返回固定用量的结果，err 非 nil 时返回错误。
+type usageTranslator struct {
+	usage Usage
+	err   error
+}
+
+func (u usageTranslator) Translate(context.Context, string) (*Result, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	return &Result{Text: "<translate>译文</translate>", Usage: u.usage}, nil
+}
+
+func TestRateLimitedTranslator(t *testing.T) {
+	prompt := "0123456789abcdefghij0123456789abcdefghij" // 40 个 ASCII 字符，约 10 个 token，预估 20 个
+	tests := []struct {
+		name      string
+		inner     usageTranslator
+		wantSpent float64 // 结束后 TPM 令牌桶中被扣除的 token 数
+	}{
+		{"按实际用量结算", usageTranslator{usage: Usage{InputTokens: 30, OutputTokens: 20}}, 50},
+		{"没有 usage 时按输出长度估算", usageTranslator{}, 10 + float64(EstimateTokens("<translate>译文</translate>"))},
+		{"失败时归还预估的 token", usageTranslator{err: errors.New("失败")}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(0, 100000)
+			tr := NewRateLimitedTranslator(tt.inner, l)
+			_, err := tr.Translate(context.Background(), prompt)
+			if (err != nil) != (tt.inner.err != nil) {
+				t.Fatalf("Translate() 错误 = %v", err)
+			}
+			if spent := l.tokens.capacity - l.tokens.level; math.Abs(spent-tt.wantSpent) > 0.5 {
+				t.Errorf("扣除的 token = %v, 期望 %v", spent, tt.wantSpent)
+			}
+		})
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"你好", 2},
+		{"hello 世界", 4},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, 期望 %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// 指数退避的初始等待时间
+	defaultRetryBaseDelay = 1 * time.Second
+	// 单次退避等待时间的上限 (服务端通过 Retry-After 明确要求更长时间时除外)
+	defaultRetryMaxDelay = 60 * time.Second
+)
+
+// RetryOptions 配置重试装饰器的行为。
+type RetryOptions struct {
+	MaxAttempts int           // 最大尝试次数 (包含首次请求)，<= 1 表示不重试
+	MaxElapsed  time.Duration // 从首次请求开始允许的最长总耗时，0 表示不限制
+	BaseDelay   time.Duration // 指数退避的初始等待时间，0 时使用默认值
+	MaxDelay    time.Duration // 单次退避等待时间上限，0 时使用默认值
+}
+
+// RetryError 表示经过多次尝试后最终仍然失败的翻译请求。
+type RetryError struct {
+	Attempts int   // 实际尝试的次数
+	Err      error // 最后一次尝试返回的错误
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("经过 %d 次尝试后仍失败: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// Attempts 返回产生该错误的请求共尝试了多少次。未经过重试层的错误视为 1 次。
+func Attempts(err error) int {
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		return retryErr.Attempts
+	}
+	return 1
+}
+
+// RetryTranslator 是 Translator 的装饰器 (Decorator Pattern)。
+// 遇到可重试错误 (见 IsRetryable) 时，它按带抖动的指数退避等待后重新调用被包装的 Translator，
+// 并优先遵循服务端通过 Retry-After / x-ratelimit-reset-* 等 Header 给出的等待时间。
+type RetryTranslator struct {
+	inner Translator
+	opts  RetryOptions
+}
+
+// NewRetryTranslator 创建一个包装 inner 的重试装饰器。
+func NewRetryTranslator(inner Translator, opts RetryOptions) *RetryTranslator {
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = defaultRetryBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaultRetryMaxDelay
+	}
+	return &RetryTranslator{inner: inner, opts: opts}
+}
+
+// Translate 方法实现了 Translator 接口。
+func (r *RetryTranslator) Translate(ctx context.Context, prompt string) (*Result, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		result, err := r.inner.Translate(ctx, prompt)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("重试: 第 %d 次尝试成功。\n", attempt)
+			}
+			return result, nil
+		}
+
+		// 调用方已取消 (例如收到退出信号)，或错误本身不可重试时，立即返回
+		if ctx.Err() != nil || !IsRetryable(err) {
+			return nil, wrapAttempts(attempt, err)
+		}
+		if attempt >= r.opts.MaxAttempts {
+			return nil, wrapAttempts(attempt, err)
+		}
+
+		delay := r.backoff(attempt, err)
+		if r.opts.MaxElapsed > 0 && time.Since(start)+delay > r.opts.MaxElapsed {
+			log.Printf("重试: 等待 %v 将超出最长重试时间 %v，放弃重试。\n", delay.Round(time.Millisecond), r.opts.MaxElapsed)
+			return nil, wrapAttempts(attempt, err)
+		}
+		log.Printf("重试: 第 %d/%d 次尝试失败 (%v)，%v 后重试...\n", attempt, r.opts.MaxAttempts, err, delay.Round(time.Millisecond))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, wrapAttempts(attempt, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// Close 如果被包装的 Translator 支持关闭，则将调用转发给它。
+func (r *RetryTranslator) Close() error {
+	if closer, ok := r.inner.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// backoff 计算第 attempt 次失败后的等待时间。
+// 使用 "等量抖动" (equal jitter): 在 [d/2, d] 区间随机取值，避免多个 Worker 同时重试。
+// 如果服务端给出的 RetryAfter 更长，则以服务端为准。
+func (r *RetryTranslator) backoff(attempt int, err error) time.Duration {
+	d := r.opts.MaxDelay
+	if shift := attempt - 1; shift < 30 {
+		if exp := r.opts.BaseDelay << shift; exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	d = d/2 + rand.N(d/2+1)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+	}
+	return d
+}
+
+// wrapAttempts 在发生过重试时用 RetryError 包装错误，以保留尝试次数。
+func wrapAttempts(attempt int, err error) error {
+	if attempt <= 1 {
+		return err
+	}
+	return &RetryError{Attempts: attempt, Err: err}
+}
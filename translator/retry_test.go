@@ -0,0 +1,172 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// scriptedTranslator 依次返回 errs 中的错误，用完后返回成功的结果。
+type scriptedTranslator struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedTranslator) Translate(context.Context, string) (*Result, error) {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return nil, s.errs[s.calls-1]
+	}
+	return &Result{Text: "ok"}, nil
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"429", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"408", &APIError{StatusCode: http.StatusRequestTimeout}, true},
+		{"500", &APIError{StatusCode: http.StatusInternalServerError}, true},
+		{"503 被包装", fmt.Errorf("片段 1/2: %w", &APIError{StatusCode: http.StatusServiceUnavailable}), true},
+		{"529 过载", &APIError{StatusCode: 529}, true},
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, false},
+		{"401", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"404", &APIError{StatusCode: http.StatusNotFound}, false},
+		{"网络错误", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"连接中断", fmt.Errorf("读取响应体失败: %w", io.ErrUnexpectedEOF), true},
+		{"流式响应空闲超时", fmt.Errorf("读取流式响应失败: %w", ErrStreamIdle), true},
+		{"输出被截断", fmt.Errorf("Claude: %w", ErrOutputTruncated), false},
+		{"其他错误", errors.New("缺少 <translate> 标签"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %t, 期望 %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{"没有 Header", nil, 0},
+		{"秒数", map[string]string{"Retry-After": "3"}, 3 * time.Second},
+		{"小数秒", map[string]string{"Retry-After": "1.5"}, 1500 * time.Millisecond},
+		{"HTTP 日期", map[string]string{"Retry-After": now.Add(10 * time.Second).Format(http.TimeFormat)}, 10 * time.Second},
+		{"过去的 HTTP 日期", map[string]string{"Retry-After": now.Add(-10 * time.Second).Format(http.TimeFormat)}, 0},
+		{"无效的值", map[string]string{"Retry-After": "soon"}, 0},
+		{"Retry-After 优先", map[string]string{"Retry-After": "2", "Retry-After-Ms": "500"}, 2 * time.Second},
+		{"毫秒", map[string]string{"Retry-After-Ms": "250"}, 250 * time.Millisecond},
+		{"OpenAI 限流重置取较大值", map[string]string{"X-Ratelimit-Reset-Requests": "1s", "X-Ratelimit-Reset-Tokens": "6m0s"}, 6 * time.Minute},
+		{"Anthropic 限流重置", map[string]string{"Anthropic-Ratelimit-Tokens-Reset": now.Add(7 * time.Second).Format(time.RFC3339)}, 7 * time.Second},
+		{"多种重置 Header 取最大值", map[string]string{"X-Ratelimit-Reset-Requests": "20ms", "Anthropic-Ratelimit-Requests-Reset": now.Add(time.Second).Format(time.RFC3339)}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			if got := parseRetryAfter(h, now); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTranslator(t *testing.T) {
+	retryable := &APIError{StatusCode: http.StatusTooManyRequests}
+	fatal := &APIError{StatusCode: http.StatusUnauthorized}
+	tests := []struct {
+		name         string
+		errs         []error
+		maxAttempts  int
+		wantCalls    int
+		wantErr      error
+		wantAttempts int
+	}{
+		{"首次成功", nil, 3, 1, nil, 1},
+		{"重试后成功", []error{retryable, retryable}, 3, 3, nil, 1},
+		{"用完尝试次数", []error{retryable, retryable, retryable}, 3, 3, retryable, 3},
+		{"不可重试的错误立即返回", []error{fatal}, 3, 1, fatal, 1},
+		{"重试后遇到不可重试的错误", []error{retryable, fatal}, 3, 2, fatal, 2},
+		{"不重试", []error{retryable}, 1, 1, retryable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &scriptedTranslator{errs: tt.errs}
+			r := NewRetryTranslator(inner, RetryOptions{MaxAttempts: tt.maxAttempts, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+			result, err := r.Translate(context.Background(), "prompt")
+			if inner.calls != tt.wantCalls {
+				t.Errorf("调用次数 = %d, 期望 %d", inner.calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil || result.Text != "ok" {
+					t.Fatalf("Translate() = %v, %v", result, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Translate() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if got := Attempts(err); got != tt.wantAttempts {
+				t.Errorf("Attempts() = %d, 期望 %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTranslatorMaxElapsed(t *testing.T) {
+	// 服务端要求的等待时间超出最长重试时间时放弃重试
+	inner := &scriptedTranslator{errs: []error{&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}}}
+	r := NewRetryTranslator(inner, RetryOptions{MaxAttempts: 3, MaxElapsed: time.Second, BaseDelay: time.Millisecond})
+	if _, err := r.Translate(context.Background(), "prompt"); err == nil || inner.calls != 1 {
+		t.Errorf("Translate() 错误 = %v, 调用次数 = %d, 期望放弃重试", err, inner.calls)
+	}
+}
+
+func TestRetryTranslatorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	inner := &scriptedTranslator{errs: []error{&APIError{StatusCode: http.StatusServiceUnavailable}}}
+	r := NewRetryTranslator(inner, RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	if _, err := r.Translate(ctx, "prompt"); err == nil || inner.calls != 1 {
+		t.Errorf("Translate() 错误 = %v, 调用次数 = %d, 期望取消后不再重试", err, inner.calls)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := NewRetryTranslator(nil, RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	tests := []struct {
+		attempt  int
+		err      error
+		min, max time.Duration
+	}{
+		{1, errors.New("x"), 50 * time.Millisecond, 100 * time.Millisecond},
+		{3, errors.New("x"), 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, errors.New("x"), 500 * time.Millisecond, time.Second},
+		{100, errors.New("x"), 500 * time.Millisecond, time.Second},
+		{1, &APIError{StatusCode: 429, RetryAfter: 5 * time.Second}, 5 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := r.backoff(tt.attempt, tt.err); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d, %v) = %v, 期望在 [%v, %v] 之间", tt.attempt, tt.err, d, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
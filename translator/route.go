@@ -0,0 +1,60 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"Markdown-translator-go/config"
+)
+
+// NewRouteTranslators 为改变了提供商配置或 temperature 的路由规则 (见 config.Route) 创建各自的 Translator。
+// 返回的切片与 cfg.Routes 一一对应; 不需要独立 Translator 的规则对应 nil，使用默认 Translator。
+// 提供商配置和 temperature 都相同的规则共享同一个 Translator; 所有 Translator 的限流器和缓存都取自 shared，
+// 应与创建默认 Translator 时使用的 Shared 相同，使同一提供商端点只有一份 RPM/TPM 配额。
+func NewRouteTranslators(ctx context.Context, cfg *config.Config, shared *Shared) ([]Translator, error) {
+	translators := make([]Translator, len(cfg.Routes))
+	byKey := make(map[string]Translator)
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if !r.NeedsTranslator() {
+			continue
+		}
+		key := r.Profile
+		if r.Temperature != nil {
+			key += "|" + strconv.FormatFloat(*r.Temperature, 'g', -1, 64)
+		}
+		if trans, ok := byKey[key]; ok {
+			translators[i] = trans
+			continue
+		}
+		log.Printf("初始化路由规则 %s 的 LLM 翻译器...\n", r.Name)
+		trans, err := NewTranslator(ctx, cfg.ForRoute(r), shared)
+		if err != nil {
+			CloseAll(translators)
+			return nil, fmt.Errorf("路由规则 %s: %w", r.Name, err)
+		}
+		byKey[key] = trans
+		translators[i] = trans
+	}
+	return translators, nil
+}
+
+// CloseAll 关闭 translators 中所有支持关闭的 Translator (同一实例只关闭一次)，返回第一个错误。
+func CloseAll(translators []Translator) error {
+	var firstErr error
+	closed := make(map[Translator]bool)
+	for _, t := range translators {
+		if t == nil || closed[t] {
+			continue
+		}
+		closed[t] = true
+		if closer, ok := t.(Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
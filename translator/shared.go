@@ -0,0 +1,53 @@
+package translator
+
+import (
+	"sync"
+
+	"Markdown-translator-go/config"
+)
+
+// Shared 保存一次运行中所有 Translator 共用的资源: 按提供商和端点区分的限流器，以及按目录区分的翻译缓存。
+// 默认 Translator 和路由规则的 Translator (见 NewRouteTranslators) 应使用同一个 Shared，
+// 否则只改变了 temperature 或 Prompt 的路由规则会获得独立的 RPM/TPM 配额，实际请求速率可能成倍超出限制。
+type Shared struct {
+	mu       sync.Mutex
+	limiters map[string]*RateLimiter // "提供商|端点" -> 限流器
+	caches   map[string]*Cache       // 缓存目录 -> 缓存
+}
+
+// NewShared 创建一组空的共享资源。
+func NewShared() *Shared {
+	return &Shared{
+		limiters: make(map[string]*RateLimiter),
+		caches:   make(map[string]*Cache),
+	}
+}
+
+// limiter 返回 profile 对应的提供商和端点的限流器，第一次请求时创建。未设置 RPM/TPM 时返回 nil。
+// created 报告限流器是否是本次新创建的。
+func (s *Shared) limiter(cfg *config.Config, profile config.ProviderProfile) (limiter *RateLimiter, created bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := profile.Provider + "|" + profile.Endpoint
+	if limiter, ok := s.limiters[key]; ok {
+		return limiter, false
+	}
+	limiter = NewRateLimiter(cfg.RequestsPerMinute, cfg.TokensPerMinute)
+	s.limiters[key] = limiter
+	return limiter, limiter != nil
+}
+
+// cache 返回目录 dir 的翻译缓存，第一次请求时打开。
+func (s *Shared) cache(dir string) (*Cache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cache, ok := s.caches[dir]; ok {
+		return cache, nil
+	}
+	cache, err := OpenCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.caches[dir] = cache
+	return cache, nil
+}
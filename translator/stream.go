@@ -0,0 +1,171 @@
+package translator
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrStreamIdle 表示流式响应在空闲超时时间内没有收到任何数据。它被视为可重试的错误。
+var ErrStreamIdle = errors.New("流式响应空闲超时")
+
+// StreamOptions 是流式响应的设置。
+type StreamOptions struct {
+	// IdleTimeout 是两次收到数据之间允许的最长间隔 (包括等待响应头)，0 表示不限制。
+	// 流式模式下不再使用固定的整体超时，只要模型仍在持续输出，长文档就不会超时。
+	IdleTimeout time.Duration
+}
+
+// Streamer 由支持 SSE 流式响应的 Translator 实现。NewTranslator 在启用流式模式时调用 EnableStreaming。
+type Streamer interface {
+	EnableStreaming(opts StreamOptions)
+}
+
+// idleWatchdog 在指定时间内没有收到数据时取消请求的 Context。
+type idleWatchdog struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// withIdleTimeout 返回一个在空闲超时后被取消的 Context。调用方必须在请求结束后调用 stop。
+func withIdleTimeout(ctx context.Context, timeout time.Duration) (context.Context, *idleWatchdog) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &idleWatchdog{ctx: ctx, cancel: cancel, timeout: timeout}
+	if timeout > 0 {
+		w.timer = time.AfterFunc(timeout, func() { cancel(ErrStreamIdle) })
+	}
+	return ctx, w
+}
+
+// kick 在收到数据时重置空闲计时。
+func (w *idleWatchdog) kick() {
+	if w.timer != nil {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// stop 停止计时并释放 Context。
+func (w *idleWatchdog) stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel(nil)
+}
+
+// wrap 将空闲超时导致的 Context 取消错误转换为 ErrStreamIdle，其他错误原样返回。
+func (w *idleWatchdog) wrap(err error) error {
+	if err != nil && errors.Is(context.Cause(w.ctx), ErrStreamIdle) {
+		return fmt.Errorf("%w (%v 内没有收到数据)", ErrStreamIdle, w.timeout)
+	}
+	return err
+}
+
+// errStopStream 由事件处理函数返回，表示已收到所需的全部内容，应提前结束读取。
+var errStopStream = errors.New("stop stream")
+
+// readSSE 逐个读取 Server-Sent Events 并调用 onEvent (event 为事件类型，没有 event 字段时为空)。
+// 每收到一行数据都会重置空闲计时。onEvent 返回 errStopStream 时停止读取并返回 nil。
+func readSSE(body io.Reader, w *idleWatchdog, onEvent func(event, data string) error) error {
+	reader := bufio.NewReader(body)
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := onEvent(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			w.kick()
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "" && err == nil:
+			// 空行表示一个事件结束
+			if err := dispatch(); err != nil {
+				return stopOrErr(err)
+			}
+		case strings.HasPrefix(line, ":"):
+			// 注释 (常用作心跳)，忽略
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+
+		if err == io.EOF {
+			return stopOrErr(dispatch())
+		}
+		if err != nil {
+			return w.wrap(err)
+		}
+	}
+}
+
+func stopOrErr(err error) error {
+	if errors.Is(err, errStopStream) {
+		return nil
+	}
+	return err
+}
+
+const (
+	openTranslateTag  = "<translate>"
+	closeTranslateTag = "</translate>"
+)
+
+// streamText 累积流式输出的文本，并增量检测 </translate> 结束标签。
+// 检测到 <translate> 之后的第一个结束标签时，丢弃其后的内容并标记完成，调用方可以提前结束请求。
+type streamText struct {
+	buf     []byte
+	done    bool // 已检测到结束标签
+	stopped bool // 因检测到结束标签而提前结束了读取
+}
+
+// append 追加一段增量文本，返回是否已检测到结束标签。
+func (s *streamText) append(delta string) bool {
+	if s.done {
+		return true
+	}
+	// 只需在新追加的内容 (以及可能跨越边界的标签前缀) 中查找
+	start := max(0, len(s.buf)-len(closeTranslateTag)+1)
+	s.buf = append(s.buf, delta...)
+	for {
+		i := strings.Index(string(s.buf[start:]), closeTranslateTag)
+		if i < 0 {
+			return false
+		}
+		end := start + i + len(closeTranslateTag)
+		if strings.Contains(string(s.buf[:start+i]), openTranslateTag) {
+			s.buf = s.buf[:end]
+			s.done = true
+			return true
+		}
+		start = end
+	}
+}
+
+// String 返回累积的文本。
+func (s *streamText) String() string {
+	return string(s.buf)
+}
+
+// finishUsage 在提前结束读取且提供商尚未报告输出 token 数时，根据已收到的文本估算输出用量。
+func (s *streamText) finishUsage(u Usage) Usage {
+	if s.stopped && u.OutputTokens == 0 {
+		u.OutputTokens = EstimateTokens(s.String())
+	}
+	return u
+}
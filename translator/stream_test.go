@@ -0,0 +1,212 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sseEvent 是 readSSE 传给回调的一个事件。
+type sseEvent struct {
+	event, data string
+}
+
+// errReader 在读完 data 后返回 err，模拟连接中断。
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		return n, e.err
+	}
+	return n, err
+}
+
+func TestReadSSE(t *testing.T) {
+	errCallback := errors.New("callback failed")
+	tests := []struct {
+		name    string
+		body    string
+		stopAt  int // 第几个事件 (从 1 开始) 返回 errStopStream，0 表示不提前结束
+		failAt  int // 第几个事件返回 errCallback，0 表示不返回错误
+		want    []sseEvent
+		wantErr error // 期望的错误 (errors.Is)
+	}{
+		{
+			name: "多个事件",
+			body: "data: a\n\ndata: b\n\n",
+			want: []sseEvent{{"", "a"}, {"", "b"}},
+		},
+		{
+			name: "带类型的事件和多行数据",
+			body: "event: message_start\ndata: {\"a\":1}\n\nevent: delta\ndata: line1\ndata: line2\n\n",
+			want: []sseEvent{{"message_start", `{"a":1}`}, {"delta", "line1\nline2"}},
+		},
+		{
+			name: "忽略注释、空事件和未知字段",
+			body: ": ping\n\nid: 1\nretry: 10\n\ndata: x\n\n",
+			want: []sseEvent{{"", "x"}},
+		},
+		{
+			name: "CRLF 换行以及冒号后没有空格",
+			body: "event:e\r\ndata:x\r\n\r\n",
+			want: []sseEvent{{"e", "x"}},
+		},
+		{
+			name: "结尾缺少空行时仍分发最后一个事件",
+			body: "data: a\n\ndata: [DONE]",
+			want: []sseEvent{{"", "a"}, {"", "[DONE]"}},
+		},
+		{
+			name: "没有数据的事件类型不会带到下一个事件",
+			body: "event: ping\n\ndata: a\n\n",
+			want: []sseEvent{{"", "a"}},
+		},
+		{
+			name:   "回调要求提前结束",
+			body:   "data: a\n\ndata: b\n\ndata: c\n\n",
+			stopAt: 2,
+			want:   []sseEvent{{"", "a"}, {"", "b"}},
+		},
+		{
+			name:    "回调返回错误",
+			body:    "data: a\n\ndata: b\n\n",
+			failAt:  1,
+			want:    []sseEvent{{"", "a"}},
+			wantErr: errCallback,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, w := withIdleTimeout(context.Background(), 0)
+			defer w.stop()
+			var got []sseEvent
+			err := readSSE(strings.NewReader(tt.body), w, func(event, data string) error {
+				got = append(got, sseEvent{event, data})
+				switch len(got) {
+				case tt.stopAt:
+					return errStopStream
+				case tt.failAt:
+					return errCallback
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("readSSE() 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readSSE() 事件 = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSSEConnectionError(t *testing.T) {
+	_, w := withIdleTimeout(context.Background(), 0)
+	defer w.stop()
+	body := &errReader{r: strings.NewReader("data: a\n\ndata: b"), err: io.ErrUnexpectedEOF}
+	var got []string
+	err := readSSE(body, w, func(_, data string) error {
+		got = append(got, data)
+		return nil
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readSSE() 错误 = %v, 期望 %v", err, io.ErrUnexpectedEOF)
+	}
+	// 中断前完整的事件已经分发，不完整的事件被丢弃
+	if !slices.Equal(got, []string{"a"}) {
+		t.Errorf("readSSE() 事件 = %q, 期望 [a]", got)
+	}
+	if !IsRetryable(err) {
+		t.Error("连接中断应被视为可重试的错误")
+	}
+}
+
+func TestStreamTextAppend(t *testing.T) {
+	tests := []struct {
+		name     string
+		deltas   []string
+		doneAt   int // 第几个增量 (从 1 开始) 之后检测到结束标签，0 表示没有检测到
+		wantText string
+	}{
+		{
+			name:     "一次收到完整的标签并丢弃之后的内容",
+			deltas:   []string{"<translate>abc</translate> trailing"},
+			doneAt:   1,
+			wantText: "<translate>abc</translate>",
+		},
+		{
+			name:     "结束标签跨越多个增量",
+			deltas:   []string{"<translate>ab", "c</tra", "nsl", "ate> more"},
+			doneAt:   4,
+			wantText: "<translate>abc</translate>",
+		},
+		{
+			name:     "开始标签跨越多个增量",
+			deltas:   []string{"<trans", "late>x", "</translate>"},
+			doneAt:   3,
+			wantText: "<translate>x</translate>",
+		},
+		{
+			name:     "开始标签之前的结束标签不算",
+			deltas:   []string{"see </translate> then <translate>y", "</translate>z"},
+			doneAt:   2,
+			wantText: "see </translate> then <translate>y</translate>",
+		},
+		{
+			name:     "没有开始标签",
+			deltas:   []string{"abc</translate>", "def"},
+			wantText: "abc</translate>def",
+		},
+		{
+			name:     "没有结束标签",
+			deltas:   []string{"<translate>", "中文", "内容"},
+			wantText: "<translate>中文内容",
+		},
+		{
+			name:     "检测到结束标签后忽略之后的增量",
+			deltas:   []string{"<translate>a</translate>", "b", "</translate>"},
+			doneAt:   1,
+			wantText: "<translate>a</translate>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s streamText
+			doneAt := 0
+			for i, delta := range tt.deltas {
+				if s.append(delta) && doneAt == 0 {
+					doneAt = i + 1
+				}
+			}
+			if doneAt != tt.doneAt {
+				t.Errorf("在第 %d 个增量后检测到结束标签, 期望第 %d 个", doneAt, tt.doneAt)
+			}
+			if got := s.String(); got != tt.wantText {
+				t.Errorf("String() = %q, 期望 %q", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestStreamTextFinishUsage(t *testing.T) {
+	s := streamText{}
+	s.append("<translate>hello world</translate>")
+	reported := Usage{InputTokens: 10, OutputTokens: 3}
+	if got := s.finishUsage(reported); got != reported {
+		t.Errorf("未提前结束时 finishUsage() = %+v, 期望不变", got)
+	}
+	s.stopped = true
+	if got := s.finishUsage(Usage{InputTokens: 10}); got.OutputTokens != EstimateTokens(s.String()) {
+		t.Errorf("提前结束时 finishUsage() = %+v, 期望按文本估算输出 token 数", got)
+	}
+	if got := s.finishUsage(reported); got != reported {
+		t.Errorf("提供商已报告输出用量时 finishUsage() = %+v, 期望不变", got)
+	}
+}
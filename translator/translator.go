@@ -2,8 +2,11 @@ package translator
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"Markdown-translator-go/config" // 根据你的实际项目路径调整
@@ -12,45 +15,202 @@ import (
 // Translator 接口定义了所有 LLM 翻译提供商必须实现的方法。
 // 这是策略模式 (Strategy Pattern) 的核心。
 type Translator interface {
-	// Translate 方法接收原始 Markdown 内容，并返回翻译后的 Markdown 字符串。
-	// 每个实现负责处理各自的 Prompt 格式化、API 调用、错误处理以及从响应中提取最终结果。
-	Translate(ctx context.Context, markdownContent string) (string, error)
+	// Translate 方法接收已渲染好的完整 Prompt (见 RenderPrompt)，并返回 LLM 的原始输出及 token 用量。
+	// 每个实现负责处理各自的 API 请求格式、调用、错误处理以及从响应中提取生成的文本。
+	Translate(ctx context.Context, prompt string) (*Result, error)
+}
+
+// Result 是一次翻译请求的结果。
+type Result struct {
+	Text   string // LLM 返回的原始输出 (<translate> 标签的提取在 processor 中进行)
+	Usage  Usage  // 本次请求的 token 用量，提供商未返回时为零值
+	Cached bool   // 结果来自翻译缓存 (见 CachedTranslator)，没有发起 API 请求，Usage 为零值
+	// Provider 和 Model 是生成该结果的提供商和模型，由 FallbackTranslator 设置; 为空表示主提供商 (cfg.LLMProvider / cfg.LLMModel)
+	Provider string
+	Model    string
+}
+
+// Usage 记录一次 (或累计多次) 请求消耗的 token 数。
+type Usage struct {
+	InputTokens       int // 输入 (Prompt) token 数，包含命中提示缓存的部分
+	CachedInputTokens int // 输入中命中提供商提示缓存的 token 数 (通常按更低的价格计费)
+	OutputTokens      int // 输出 (生成内容) token 数
+}
+
+// Total 返回输入与输出 token 的总和。
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Add 返回两份用量之和，用于按文件和按运行累计用量。
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		InputTokens:       u.InputTokens + o.InputTokens,
+		CachedInputTokens: u.CachedInputTokens + o.CachedInputTokens,
+		OutputTokens:      u.OutputTokens + o.OutputTokens,
+	}
 }
 
 // Closer 接口定义了一个可关闭的资源
 type Closer interface {
-    Close() error
+	Close() error
 }
 
-
 // --- 工厂函数 (Factory Function) ---
 
 // NewTranslator 函数充当一个工厂，根据配置信息创建并返回合适的 Translator 实例。
 // 这是工厂模式 (Factory Pattern) 的应用。
-func NewTranslator(cfg *config.Config) (Translator, error) {
-	// 创建一个共享的 HTTP 客户端实例，可以根据需要进行更复杂的配置 (例如重试逻辑)
+// 对于实现了 Preparer 的提供商 (本地模型服务)，会在返回前执行健康检查和模型拉取，ctx 用于取消这些操作。
+// 配置了备用提供商 (cfg.Fallbacks) 时，返回按顺序尝试各提供商的 FallbackTranslator。
+// 限流器和翻译缓存取自 shared (见 Shared)，shared 为 nil 时使用新的一组共享资源。
+func NewTranslator(ctx context.Context, cfg *config.Config, shared *Shared) (Translator, error) {
+	if shared == nil {
+		shared = NewShared()
+	}
+	profiles := cfg.Profiles()
+	members := make([]fallbackMember, 0, len(profiles))
+	for i, profile := range profiles {
+		trans, err := newProfileTranslator(ctx, cfg, profile, shared)
+		if err != nil {
+			closeMembers(members)
+			if i > 0 {
+				return nil, fmt.Errorf("初始化备用提供商 %s 失败: %w", profile.Label(), err)
+			}
+			return nil, err
+		}
+		members = append(members, fallbackMember{profile: profile, trans: trans})
+	}
+	if len(members) == 1 {
+		return members[0].trans, nil
+	}
+	log.Printf("启用备用提供商链: %s\n", memberLabels(members))
+	return newFallbackTranslator(members), nil
+}
+
+// newProfileTranslator 为单个提供商创建完整的 Translator: 具体实现及其限流、重试和缓存装饰器。
+// 每个提供商使用独立的 HTTP 客户端; 限流器按提供商和端点取自 shared，因为配额是按提供商 (端点) 计算的，
+// 同一端点的所有 Translator (默认和路由规则的) 共用同一份配额。
+func newProfileTranslator(ctx context.Context, cfg *config.Config, profile config.ProviderProfile, shared *Shared) (Translator, error) {
+	// 为该提供商创建 HTTP 客户端 (供所有 Worker 共享)。Timeout 限制的是单次请求，重试由 RetryTranslator 负责
 	httpClient := &http.Client{
 		Timeout: 120 * time.Second, // 为 LLM API 调用设置较长的超时时间 (例如 120 秒)
 	}
+	if cfg.Stream {
+		// 流式模式下不使用整体超时，改由各客户端的空闲超时 (StreamIdleTimeout) 控制
+		httpClient.Timeout = 0
+	}
+
+	base, err := newProviderTranslator(cfg, profile, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Stream {
+		if s, ok := base.(Streamer); ok {
+			log.Printf("启用流式响应: 空闲超时 %v\n", cfg.StreamIdleTimeout)
+			s.EnableStreaming(StreamOptions{IdleTimeout: cfg.StreamIdleTimeout})
+		} else {
+			log.Printf("警告: 提供商 %s 不支持流式响应，将使用普通请求 (单次请求超时 120s)\n", profile.Provider)
+			httpClient.Timeout = 120 * time.Second
+		}
+	}
+	if len(profile.Params) > 0 || len(profile.Headers) > 0 {
+		if c, ok := base.(Customizer); ok {
+			c.SetRequestOptions(RequestOptions{Params: profile.Params, Headers: profile.Headers})
+		} else {
+			log.Printf("警告: 提供商 %s 不支持附加生成参数和 Header，已忽略\n", profile.Provider)
+		}
+	}
+	if p, ok := base.(Preparer); ok {
+		if err := p.Prepare(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// 由内到外依次包装装饰器: 具体实现 -> 限流 -> 重试 -> 缓存。
+	// 限流位于重试内层，使每一次重试请求都同样受 RPM/TPM 配额约束。
+	// 所有 Worker 以及使用同一提供商端点的路由规则共享同一个限流器。
+	trans := base
+	if limiter, created := shared.limiter(cfg, profile); limiter != nil {
+		if created {
+			log.Printf("启用客户端限流 (%s): RPM=%d, TPM=%d (0 表示不限制)\n", profile.Label(), cfg.RequestsPerMinute, cfg.TokensPerMinute)
+		}
+		trans = NewRateLimitedTranslator(trans, limiter)
+	}
+
+	// 使用重试装饰器包装具体实现，使所有提供商共享同一套重试与退避逻辑
+	if cfg.RetryMaxAttempts > 1 {
+		log.Printf("启用重试: 最多尝试 %d 次, 最长重试时间 %v\n", cfg.RetryMaxAttempts, cfg.RetryMaxElapsed)
+		trans = NewRetryTranslator(trans, RetryOptions{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			MaxElapsed:  cfg.RetryMaxElapsed,
+		})
+	}
+
+	// 缓存位于最外层: 命中的请求既不发起网络请求，也不占用限流配额和重试预算
+	if cfg.CacheDir != "" {
+		cache, err := shared.cache(cfg.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("启用翻译缓存: %s\n", cfg.CacheDir)
+		trans = NewCachedTranslator(trans, cache, cacheKeyParams(cfg, profile))
+	}
+	return trans, nil
+}
+
+// cacheKeyParams 返回参与缓存键计算的提供商、模型和生成参数。
+// 只包含会影响输出内容的设置; 流式、超时、重试、限流和附加 Header 等传输层设置不影响结果，因此不参与计算。
+func cacheKeyParams(cfg *config.Config, profile config.ProviderProfile) CacheKeyParams {
+	params := CacheKeyParams{Provider: profile.Provider, Model: profile.Model, Params: map[string]string{}}
+	for k, v := range profile.Params {
+		// 生成参数的值可能是数字、字符串或布尔值，统一以 JSON 形式参与计算
+		value, _ := json.Marshal(v)
+		params.Params[k] = string(value)
+	}
+	if profile.Model == "" {
+		// 未指定模型时实际使用的是端点上的默认模型 (例如本地服务加载的模型)，以端点区分
+		params.Params["endpoint"] = profile.Endpoint
+	}
+	if profile.Provider == "ollama" && cfg.ContextLength > 0 {
+		params.Params["num_ctx"] = strconv.Itoa(cfg.ContextLength)
+	}
+	if _, ok := params.Params["max_tokens"]; !ok && profile.Provider == "claude" {
+		// Claude 的输出 token 上限随 --max-output-tokens 和片段大小变化，上限过小时译文会被截断
+		params.Params["max_tokens"] = strconv.Itoa(cfg.OutputTokenLimit())
+	}
+	return params
+}
 
-	// 根据配置中的 LLMProvider 决定创建哪个具体的 Translator 实现
-	switch cfg.LLMProvider {
+// newProviderTranslator 根据 profile 中的提供商类型创建具体提供商的 Translator 实现。
+func newProviderTranslator(cfg *config.Config, profile config.ProviderProfile, httpClient *http.Client) (Translator, error) {
+	switch profile.Provider {
 	case "openai":
 		// 创建 OpenAI 客户端实例
-		// 需要 API Key, Endpoint (可选), Model (可选), HTTP Client, Prompt 模板
-		return NewOpenAIClient(httpClient, cfg.LLMAPIKey, cfg.LLMAPIEndpoint, cfg.LLMModel, cfg.PromptTemplate)
+		// 需要 API Key, Endpoint (可选), Model (可选), HTTP Client
+		return NewOpenAIClient(httpClient, profile.Key, profile.Endpoint, profile.Model)
 	case "claude":
 		// 创建 Claude 客户端实例
-		// 需要 API Key, Endpoint (可选), Model (可选), HTTP Client, Prompt 模板
+		// 需要 API Key, Endpoint (可选), Model (可选), HTTP Client
 		// 注意: Claude 可能需要特定的 HTTP Header (如 'anthropic-version')
-		return NewClaudeClient(httpClient, cfg.LLMAPIKey, cfg.LLMAPIEndpoint, cfg.LLMModel, cfg.PromptTemplate)
+		// 输出 token 上限取自 --max-output-tokens，未指定时按片段大小计算
+		return NewClaudeClient(httpClient, profile.Key, profile.Endpoint, profile.Model, cfg.OutputTokenLimit())
 	case "gemini":
 		// 创建 Gemini 客户端实例
-		// 需要 API Key, Endpoint (可能包含模型名称), Model (用于构建 URL), HTTP Client, Prompt 模板
-		return NewGeminiClient(httpClient, cfg.LLMAPIKey, cfg.LLMAPIEndpoint, cfg.LLMModel, cfg.PromptTemplate)
+		// 需要 API Key, Endpoint (可能包含模型名称), Model (用于构建 URL), HTTP Client
+		return NewGeminiClient(httpClient, profile.Key, profile.Endpoint, profile.Model)
+	case "ollama":
+		// 使用 Ollama 原生接口，不需要 API Key，支持 keep_alive 和上下文长度设置
+		return NewOllamaClient(httpClient, profile.Endpoint, profile.Model, OllamaOptions{
+			KeepAlive:     cfg.KeepAlive,
+			ContextLength: cfg.ContextLength,
+			PullModel:     cfg.PullModel,
+		})
+	case "local":
+		// 本地 OpenAI 兼容服务 (llama.cpp、vLLM 等)，API Key 可选
+		return NewLocalClient(httpClient, profile.Key, profile.Endpoint, profile.Model)
 	default:
 		// 这个分支理论上不应该被触及，因为配置加载时已经校验过 Provider
 		// 但作为代码健壮性的保证，还是加上错误处理
-		return nil, fmt.Errorf("内部错误: 不支持的 LLM 提供商 '%s' 传入工厂函数", cfg.LLMProvider)
+		return nil, fmt.Errorf("内部错误: 不支持的 LLM 提供商 '%s' 传入工厂函数", profile.Provider)
 	}
 }
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MatchGlob 报告以 "/" 分隔的相对路径 name 是否匹配 pattern。
+// 除 path.Match 支持的 *、?、[...] 之外，独立的 "**" 路径段匹配零个或多个目录，
+// 例如 "common/**" 匹配 common 下的所有文件，"**/README.md" 匹配任意目录中的 README.md。
+// 与 path.Match 相同，"*" 不会跨越 "/"。
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// ValidateGlob 检查 pattern 的语法是否正确 (path.Match 会在匹配时才报告错误，这里提前在加载配置时检查)。
+func ValidateGlob(pattern string) error {
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("无效的匹配模式 '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchSegments 逐段匹配模式和路径。
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// 合并连续的 "**"，然后尝试让它匹配 0..len(name) 个路径段
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashString 返回字符串内容的 SHA-256 哈希 (十六进制)，用于检测源文件或 Prompt 是否发生变化。
+func HashString(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	// "log" // 如果需要记录跳过信息，取消此行注释
+	"strings"
+	"time"
 )
 
 // ReadFile 函数读取指定路径文件的全部内容，并以字符串形式返回。
@@ -17,22 +20,34 @@ func ReadFile(path string) (string, error) {
 	return string(content), nil
 }
 
+// TempFileSuffix 是 WriteFile 写入过程中使用的临时文件的后缀。
+// 临时文件与目标文件位于同一目录，名称形如 ".README.md.123456.mdtranslate-tmp"。
+const TempFileSuffix = ".mdtranslate-tmp"
+
 // WriteFile 函数将字符串内容写入指定路径的文件。
 // 它会确保目标文件的父目录存在。
 // overwrite 参数控制是否覆盖已存在的文件。
+// 写入是原子的: 内容先写入同一目录中的临时文件并同步到磁盘，再重命名为目标文件，
+// 程序崩溃或被终止时目标文件要么是旧内容，要么是完整的新内容，不会留下被截断的文件。
+// 覆盖已存在的文件时保留其权限位，新文件使用 0644。
 func WriteFile(path string, content string, overwrite bool) error {
-	// --- 文件存在性检查 (仅当不允许覆盖时) ---
-	if !overwrite {
-		// os.Stat 获取文件信息。如果 err 为 nil，表示文件已存在。
-		if _, err := os.Stat(path); err == nil {
+	// --- 文件存在性检查 ---
+	// os.Stat 获取文件信息。如果 err 为 nil，表示文件已存在。
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		if !overwrite {
 			// 文件存在，且不允许覆盖，则直接返回 nil (表示成功跳过，不是错误)
-			// log.Printf("信息: 跳过已存在的文件 (未设置覆盖): %s\n", path) // 可选日志
 			return nil
-		} else if !os.IsNotExist(err) {
-			// 如果 Stat 返回错误，但不是 "文件不存在" (例如权限问题)，则这是一个需要报告的错误。
-			return fmt.Errorf("检查目标文件 %s 状态失败: %w", path, err)
 		}
-		// 如果 err 是 os.IsNotExist(err)，则表示文件不存在，可以继续写入。
+		// 覆盖时保留原文件的权限
+		perm = info.Mode().Perm()
+		// 目标是符号链接时写入它指向的文件，而不是用普通文件替换链接本身
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+	} else if !os.IsNotExist(err) {
+		// 如果 Stat 返回错误，但不是 "文件不存在" (例如权限问题)，则这是一个需要报告的错误。
+		return fmt.Errorf("检查目标文件 %s 状态失败: %w", path, err)
 	}
 
 	// --- 确保目录存在 ---
@@ -44,14 +59,76 @@ func WriteFile(path string, content string, overwrite bool) error {
 		return fmt.Errorf("创建目录 %s 失败: %w", dir, err)
 	}
 
-	// --- 写入文件 ---
-	// os.WriteFile 将内容写入文件。如果文件已存在，它会被覆盖 (这是 WriteFile 的默认行为)。
-	// 0644 是常用的文件权限 (owner: rw, group: r, others: r)。
-	err := os.WriteFile(path, []byte(content), 0644)
+	// --- 写入临时文件 ---
+	// 临时文件必须与目标文件在同一目录 (同一文件系统)，重命名才是原子的。
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*"+TempFileSuffix)
 	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	// 任何一步失败都删除临时文件；重命名成功后 Remove 返回的错误被忽略
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入文件 %s 失败: %w", path, err)
+	}
+	// CreateTemp 创建的文件权限为 0600，这里改为目标文件应有的权限
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("设置文件 %s 权限失败: %w", path, err)
+	}
+	// 重命名之前将内容同步到磁盘，否则系统崩溃后可能得到一个空的目标文件
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("同步文件 %s 失败: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入文件 %s 失败: %w", path, err)
+	}
+
+	// --- 重命名为目标文件 ---
+	if err := os.Rename(tmpPath, path); err != nil {
 		return fmt.Errorf("写入文件 %s 失败: %w", path, err)
 	}
+	// 同步目录，使重命名本身也持久化 (部分平台不支持对目录调用 Sync，忽略错误)
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
 
 	// 写入成功
 	return nil
 }
+
+// CleanTempFiles 删除 dir 下 (递归) 修改时间早于 olderThan 之前的 WriteFile 临时文件，返回删除的文件数。
+// 这些文件是之前的运行在写入过程中崩溃或被终止时留下的。跳过较新的临时文件，避免影响同时运行的其他进程。
+// dir 不存在时不做任何事。
+func CleanTempFiles(dir string, olderThan time.Duration) (int, error) {
+	removed := 0
+	cutoff := time.Now().Add(-olderThan)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), TempFileSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("删除临时文件 %s 失败: %w", path, err)
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("清理目录 %s 中的临时文件失败: %w", dir, err)
+	}
+	return removed, nil
+}
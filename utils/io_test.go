@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempFiles 返回 dir 下 (递归) 所有 WriteFile 临时文件。
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(d.Name(), TempFileSuffix) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+func TestWriteFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string      // 目标文件原有的内容，为空表示目标文件不存在
+		mode      os.FileMode // 原有文件的权限
+		overwrite bool
+		want      string
+		wantMode  os.FileMode
+	}{
+		{name: "新文件", overwrite: false, want: "new", wantMode: 0644},
+		{name: "已存在且不覆盖", existing: "old", mode: 0644, overwrite: false, want: "old", wantMode: 0644},
+		{name: "覆盖", existing: "old", mode: 0644, overwrite: true, want: "new", wantMode: 0644},
+		{name: "覆盖时保留权限", existing: "old", mode: 0600, overwrite: true, want: "new", wantMode: 0600},
+		{name: "覆盖可执行文件", existing: "old", mode: 0755, overwrite: true, want: "new", wantMode: 0755},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "sub", "a.md")
+			if tt.existing != "" {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.existing), tt.mode); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(path, tt.mode); err != nil { // 不受 umask 影响
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteFile(path, "new", tt.overwrite); err != nil {
+				t.Fatalf("WriteFile() 错误: %v", err)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("文件内容 = %q, 期望 %q", content, tt.want)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.wantMode {
+				t.Errorf("文件权限 = %v, 期望 %v", info.Mode().Perm(), tt.wantMode)
+			}
+			if left := tempFiles(t, dir); len(left) > 0 {
+				t.Errorf("残留临时文件: %q", left)
+			}
+		})
+	}
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.md")
+	link := filepath.Join(dir, "link.md")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("不支持符号链接: %v", err)
+	}
+
+	if err := WriteFile(link, "new", true); err != nil {
+		t.Fatalf("WriteFile() 错误: %v", err)
+	}
+	// 写入链接指向的文件，链接本身保持不变
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("符号链接被替换: %v, %v", info, err)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "new" {
+		t.Errorf("链接指向的文件内容 = %q, 期望 %q", content, "new")
+	}
+}
+
+func TestWriteFileError(t *testing.T) {
+	dir := t.TempDir()
+	// 父路径是普通文件，无法创建目录
+	parent := filepath.Join(dir, "file")
+	if err := os.WriteFile(parent, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(filepath.Join(parent, "a.md"), "x", true); err == nil {
+		t.Error("WriteFile() 期望返回错误")
+	}
+}
+
+func TestCleanTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	files := []struct {
+		path    string
+		modTime time.Time
+		removed bool
+	}{
+		{".a.md.1" + TempFileSuffix, old, true},
+		{filepath.Join("sub", ".b.md.2"+TempFileSuffix), old, true},
+		{".c.md.3" + TempFileSuffix, time.Now(), false}, // 可能属于正在运行的其他进程
+		{"a.md", old, false},
+		{"notes" + TempFileSuffix + ".md", old, false},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, f.modTime, f.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := CleanTempFiles(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("CleanTempFiles() 错误: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("CleanTempFiles() = %d, 期望 2", removed)
+	}
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(dir, f.path))
+		if exists := err == nil; exists == f.removed {
+			t.Errorf("%s: 存在 = %t, 期望 %t", f.path, exists, !f.removed)
+		}
+	}
+
+	if removed, err := CleanTempFiles(filepath.Join(dir, "missing"), time.Minute); removed != 0 || err != nil {
+		t.Errorf("目录不存在时 CleanTempFiles() = %d, %v, 期望 0, nil", removed, err)
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"log" // 导入 log 包用于记录详细错误
 	"regexp"
@@ -28,8 +29,8 @@ func ExtractTranslation(rawLLMOutput string) (string, error) {
 				preview = preview[:300] + "..."
 			}
 			errMsg := fmt.Sprintf("无法在 LLM 输出中找到 <translate>...</translate> 标签。输出预览 (最多300字符): %s", preview)
-			log.Println("错误: " + errMsg) // 使用 log 记录更详细的信息
-			return "", fmt.Errorf(errMsg) // 返回错误
+			log.Println("错误: " + errMsg)  // 使用 log 记录更详细的信息
+			return "", errors.New(errMsg) // 返回错误
 		}
 	}
 
@@ -0,0 +1,218 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"Markdown-translator-go/markdown"
+)
+
+// Policy 决定结构校验发现不一致时如何处理。
+type Policy string
+
+const (
+	PolicyOff   Policy = "off"   // 不校验
+	PolicyWarn  Policy = "warn"  // 记录警告，仍然写入译文
+	PolicyFail  Policy = "fail"  // 将该文件视为失败，不写入译文
+	PolicyRetry Policy = "retry" // 重新翻译一次，仍不一致时视为失败
+)
+
+// Policies 列出了所有支持的校验策略。
+var Policies = []Policy{PolicyOff, PolicyWarn, PolicyFail, PolicyRetry}
+
+// ParsePolicy 解析校验策略名称 (不区分大小写)。
+func ParsePolicy(name string) (Policy, error) {
+	p := Policy(strings.ToLower(strings.TrimSpace(name)))
+	if slices.Contains(Policies, p) {
+		return p, nil
+	}
+	names := make([]string, len(Policies))
+	for i, p := range Policies {
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("不支持的校验策略 '%s'. 支持的策略: %s", name, strings.Join(names, ", "))
+}
+
+// Issue 描述原文与译文之间的一处结构不一致。
+type Issue struct {
+	Check   string // 检查项，如 "标题"、"代码块"
+	Message string // 具体的不一致描述
+}
+
+func (i Issue) String() string {
+	return i.Check + ": " + i.Message
+}
+
+// Error 表示译文未通过结构校验。
+type Error struct {
+	Issues []Issue
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.String()
+	}
+	return fmt.Sprintf("译文结构校验失败 (%d 处不一致): %s", len(e.Issues), strings.Join(msgs, "; "))
+}
+
+var (
+	// 列表项 (包括嵌套的列表项)
+	listItemRegex = regexp.MustCompile(`^\s*(?:[-*+]|\d{1,9}[.)])[ \t]+\S`)
+	// 行内链接和图片: [text](target "title") / ![alt](src)
+	inlineLinkRegex = regexp.MustCompile(`(!?)\[(?:[^\[\]]|\[[^\[\]]*\])*\]\(\s*<?([^)\s>]*)>?(?:\s+["'(][^)]*)?\)`)
+	// 自动链接: <https://...>
+	autolinkRegex = regexp.MustCompile(`<((?:https?|ftp|mailto):[^\s<>]+)>`)
+	// 链接引用定义: [ref]: target
+	linkDefRegex = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:\s*<?(\S+?)>?(?:\s|$)`)
+)
+
+// structure 是从一篇 Markdown 文档中提取的、翻译前后应保持不变的结构信息。
+type structure struct {
+	headings   []int    // 各标题的级别，按出现顺序
+	listItems  int      // 列表项数量
+	codeBlocks []string // 围栏代码块内容，按出现顺序
+	codeSpans  []string // 行内代码
+	links      []string // 链接目标
+	images     []string // 图片地址
+	quoteLines int      // 引用块行数
+}
+
+// extract 提取文档的结构信息。围栏代码块先被整体移除，避免其中的内容被误判为标题或链接。
+func extract(content string) structure {
+	var s structure
+	rest, blocks := markdown.FencedBlocks(content)
+	for _, b := range blocks {
+		s.codeBlocks = append(s.codeBlocks, normalizeCode(b))
+	}
+
+	for _, b := range markdown.ParseBlocks(rest) {
+		switch b.Kind {
+		case markdown.BlockHeading:
+			s.headings = append(s.headings, b.Level)
+		case markdown.BlockList:
+			for _, line := range strings.Split(b.Text, "\n") {
+				if listItemRegex.MatchString(line) {
+					s.listItems++
+				}
+			}
+		case markdown.BlockQuote:
+			s.quoteLines += strings.Count(b.Text, "\n") + 1
+		}
+	}
+
+	// 行内元素: 先提取行内代码，再在去掉行内代码的文本中查找链接，避免代码中的方括号干扰
+	s.codeSpans = markdown.CodeSpans(rest)
+	text := rest
+	for _, span := range s.codeSpans {
+		text = strings.Replace(text, span, "", 1)
+	}
+	for _, m := range inlineLinkRegex.FindAllStringSubmatch(text, -1) {
+		if m[1] == "!" {
+			s.images = append(s.images, m[2])
+		} else {
+			s.links = append(s.links, m[2])
+		}
+	}
+	for _, m := range autolinkRegex.FindAllStringSubmatch(text, -1) {
+		s.links = append(s.links, m[1])
+	}
+	for _, m := range linkDefRegex.FindAllStringSubmatch(text, -1) {
+		s.links = append(s.links, m[1])
+	}
+	return s
+}
+
+// Compare 比较原文与译文的 Markdown 结构，返回所有不一致之处 (完全一致时返回 nil)。
+// 检查项: 标题数量与级别、列表项数量、代码块数量与内容、行内代码、链接目标、图片地址和引用行数。
+func Compare(source, translated string) []Issue {
+	src, dst := extract(source), extract(translated)
+	var issues []Issue
+
+	if len(src.headings) != len(dst.headings) {
+		issues = append(issues, Issue{"标题", fmt.Sprintf("原文 %d 个，译文 %d 个", len(src.headings), len(dst.headings))})
+	} else if i := firstDiff(src.headings, dst.headings); i >= 0 {
+		issues = append(issues, Issue{"标题", fmt.Sprintf("第 %d 个标题级别不同: 原文 H%d，译文 H%d", i+1, src.headings[i], dst.headings[i])})
+	}
+
+	if src.listItems != dst.listItems {
+		issues = append(issues, Issue{"列表", fmt.Sprintf("原文 %d 项，译文 %d 项", src.listItems, dst.listItems)})
+	}
+
+	if len(src.codeBlocks) != len(dst.codeBlocks) {
+		issues = append(issues, Issue{"代码块", fmt.Sprintf("原文 %d 个，译文 %d 个", len(src.codeBlocks), len(dst.codeBlocks))})
+	} else if i := firstDiff(src.codeBlocks, dst.codeBlocks); i >= 0 {
+		issues = append(issues, Issue{"代码块", fmt.Sprintf("第 %d 个代码块的内容被修改", i+1)})
+	}
+
+	issues = append(issues, compareSets("行内代码", src.codeSpans, dst.codeSpans)...)
+	issues = append(issues, compareSets("链接", src.links, dst.links)...)
+	issues = append(issues, compareSets("图片", src.images, dst.images)...)
+
+	if src.quoteLines != dst.quoteLines {
+		issues = append(issues, Issue{"引用", fmt.Sprintf("原文 %d 行，译文 %d 行", src.quoteLines, dst.quoteLines)})
+	}
+	return issues
+}
+
+// compareSets 按多重集合比较两组字符串 (不关心顺序)，报告译文中缺失和多出的项。
+func compareSets(check string, src, dst []string) []Issue {
+	counts := make(map[string]int)
+	for _, s := range src {
+		counts[s]++
+	}
+	for _, d := range dst {
+		counts[d]--
+	}
+	var missing, extra []string
+	for _, s := range src {
+		if counts[s] > 0 {
+			missing = append(missing, s)
+			counts[s]--
+		}
+	}
+	for _, d := range dst {
+		if counts[d] < 0 {
+			extra = append(extra, d)
+			counts[d]++
+		}
+	}
+
+	var issues []Issue
+	if len(missing) > 0 {
+		issues = append(issues, Issue{check, fmt.Sprintf("译文缺少 %d 个: %s", len(missing), preview(missing))})
+	}
+	if len(extra) > 0 {
+		issues = append(issues, Issue{check, fmt.Sprintf("译文多出 %d 个: %s", len(extra), preview(extra))})
+	}
+	return issues
+}
+
+// firstDiff 返回两个等长切片中第一个不同元素的下标，完全相同时返回 -1。
+func firstDiff[T comparable](a, b []T) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeCode 去除代码块每行首尾的空白，使列表中缩进不同的代码块也能正确比较。
+func normalizeCode(block string) string {
+	lines := strings.Split(block, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// preview 返回最多 3 项的列表预览，用于日志。
+func preview(items []string) string {
+	if len(items) > 3 {
+		return fmt.Sprintf("%q 等", items[:3])
+	}
+	return fmt.Sprintf("%q", items)
+}
@@ -0,0 +1,112 @@
+package validator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	const source = "# Title\n\n" +
+		"Intro with `code` and a [link](https://example.com/a \"Title\").\n\n" +
+		"## Section\n\n" +
+		"- one\n- two\n  - nested\n\n" +
+		"![Alt text](img/a.png)\n\n" +
+		"> quote 1\n> quote 2\n\n" +
+		"```go\nfmt.Println(\"hi\")\n```\n\n" +
+		"See <https://example.org> and [ref].\n\n" +
+		"[ref]: https://example.net/ref\n"
+
+	tests := []struct {
+		name       string
+		translated string
+		want       []string // 期望的检查项，按 Compare 返回的顺序
+	}{
+		{
+			name: "结构一致，链接和图片中的文字已翻译",
+			translated: "# 标题\n\n" +
+				"介绍 `code` 和一个[链接](https://example.com/a \"标题\")。\n\n" +
+				"## 小节\n\n" +
+				"- 一\n- 二\n  - 嵌套\n\n" +
+				"![替代文字](img/a.png)\n\n" +
+				"> 引用 1\n> 引用 2\n\n" +
+				"```go\nfmt.Println(\"hi\")\n```\n\n" +
+				"参见 <https://example.org> 和 [参考][ref]。\n\n" +
+				"[ref]: https://example.net/ref\n",
+		},
+		{
+			name: "标题级别不同",
+			translated: "# 标题\n\n介绍 `code` 和一个[链接](https://example.com/a)。\n\n### 小节\n\n" +
+				"- 一\n- 二\n  - 嵌套\n\n![替代文字](img/a.png)\n\n> 引用 1\n> 引用 2\n\n" +
+				"```go\nfmt.Println(\"hi\")\n```\n\n参见 <https://example.org>。\n\n[ref]: https://example.net/ref\n",
+			want: []string{"标题"},
+		},
+		{
+			name: "缺少标题、列表项和引用行",
+			translated: "# 标题\n\n介绍 `code` 和一个[链接](https://example.com/a)。\n\n" +
+				"- 一\n- 二\n\n![替代文字](img/a.png)\n\n> 引用 1 引用 2\n\n" +
+				"```go\nfmt.Println(\"hi\")\n```\n\n参见 <https://example.org>。\n\n[ref]: https://example.net/ref\n",
+			want: []string{"标题", "列表", "引用"},
+		},
+		{
+			name: "代码块、行内代码、链接和图片被修改",
+			translated: "# 标题\n\n介绍 `代码` 和一个[链接](https://example.com/b)。\n\n## 小节\n\n" +
+				"- 一\n- 二\n  - 嵌套\n\n![替代文字](img/b.png)\n\n> 引用 1\n> 引用 2\n\n" +
+				"```go\nfmt.Println(\"你好\")\n```\n\n参见 <https://example.org>。\n\n[ref]: https://example.net/ref\n",
+			want: []string{"代码块", "行内代码", "行内代码", "链接", "链接", "图片", "图片"},
+		},
+		{
+			name: "缺少代码块",
+			translated: "# 标题\n\n介绍 `code` 和一个[链接](https://example.com/a)。\n\n## 小节\n\n" +
+				"- 一\n- 二\n  - 嵌套\n\n![替代文字](img/a.png)\n\n> 引用 1\n> 引用 2\n\n" +
+				"参见 <https://example.org>。\n\n[ref]: https://example.net/ref\n",
+			want: []string{"代码块"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range Compare(source, tt.translated) {
+				got = append(got, issue.Check)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Compare() 检查项 = %q, 期望 %q\n%v", got, tt.want, Compare(source, tt.translated))
+			}
+		})
+	}
+}
+
+func TestCompareInline(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		wantIssues int
+	}{
+		{"链接顺序不同", "[a](x) [b](y)", "[乙](y) [甲](x)", 0},
+		{"链接文字中的方括号", "[a [b]](x)", "[甲 [乙]](x)", 0},
+		{"尖括号包裹的链接目标", "[a](<x y>)", "[甲](<x y>)", 0},
+		{"图片的标题被翻译", `![a](p.png "Photo")`, `![甲](p.png "照片")`, 0},
+		{"行内代码中的方括号不是链接", "`[a](x)`", "`[a](x)`", 0},
+		{"行内代码中的链接被修改", "`[a](x)`", "`[a](y)`", 2},
+		{"链接变为图片", "[a](x)", "![甲](x)", 2},
+		{"代码块缩进不同", "- a\n\n  ```\n  x\n  ```\n", "- 甲\n\n    ```\n    x\n    ```\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if issues := Compare(tt.source, tt.translated); len(issues) != tt.wantIssues {
+				t.Errorf("Compare() = %v, 期望 %d 处不一致", issues, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"off", "warn", " Fail ", "RETRY"} {
+		if _, err := ParsePolicy(name); err != nil {
+			t.Errorf("ParsePolicy(%q) 错误: %v", name, err)
+		}
+	}
+	if _, err := ParsePolicy("strict"); err == nil {
+		t.Error("ParsePolicy(\"strict\") 应返回错误")
+	}
+}